@@ -11,6 +11,8 @@ const BLANK_WHITESPACE string = " "
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // of the first character of the node
+	End() token.Position // just past the last character of the node
 }
 
 // marker interface$
@@ -51,6 +53,14 @@ func (self *Program) String() string {
 type Identifier struct {
 	Token token.Token // The token.IDENT token
 	Value string
+
+	// Set by the resolver when the name is bound in a scope: the binding
+	// is in the environment of Scope, Depth functions out from the one the
+	// identifier is in, at index Slot. Builtins and names bound nowhere
+	// keep a nil Scope and are looked up by name.
+	Scope *Scope
+	Depth int
+	Slot  int
 }
 
 func (self *Identifier) expressionNode() {}
@@ -234,6 +244,7 @@ func (self *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	Close      token.Token // the } token
 }
 
 func (self *BlockStatement) statementNode() {}
@@ -254,6 +265,7 @@ type FunctionLiteral struct {
 	Token      token.Token // the fn keyword
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Scope      *Scope // set by the resolver, holds the parameters and the lets of the body
 }
 
 func (self *FunctionLiteral) expressionNode() {}
@@ -284,6 +296,7 @@ type CallExpression struct {
 	Token     token.Token // the ( token
 	Function  Expression
 	Arguments []Expression
+	Close     token.Token // the ) token
 }
 
 func (self *CallExpression) expressionNode() {}
@@ -316,9 +329,36 @@ func (self *StringLiteral) expressionNode()      {}
 func (self *StringLiteral) TokenLiteral() string { return self.Token.Literal }
 func (self *StringLiteral) String() string       { return self.Token.Literal }
 
+// InterpolatedString is a string literal with ${...} interpolations. Its parts
+// are *StringLiteral nodes for the literal text and the embedded expressions.
+type InterpolatedString struct {
+	Token token.Token // the token.TEMPLATE token
+	Parts []Expression
+}
+
+func (self *InterpolatedString) expressionNode()      {}
+func (self *InterpolatedString) TokenLiteral() string { return self.Token.Literal }
+func (self *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range self.Parts {
+		if literal, ok := part.(*StringLiteral); ok {
+			out.WriteString(literal.Value)
+			continue
+		}
+
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	Close    token.Token // the ']' token
 }
 
 func (self *ArrayLiteral) expressionNode()      {}
@@ -342,6 +382,7 @@ type IndexExpression struct {
 	Token token.Token // the '[' token
 	Left  Expression
 	Index Expression
+	Close token.Token // the ']' token
 }
 
 func (self *IndexExpression) expressionNode()      {}
@@ -361,6 +402,7 @@ func (self *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Close token.Token // the '}' token
 }
 
 func (self *HashLiteral) expressionNode()      {}
@@ -0,0 +1,101 @@
+package ast
+
+import "github.com/Neal-C/interpreter-in-go/token"
+
+// The positions of a node come from its tokens. Nodes made up rather than
+// parsed, such as those of the optimizer, have zero positions.
+
+func (self *Program) Pos() token.Position {
+	if len(self.Statements) > 0 {
+		return self.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (self *Program) End() token.Position {
+	if len(self.Statements) > 0 {
+		return self.Statements[len(self.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
+func (self *Identifier) Pos() token.Position { return self.Token.Start }
+func (self *Identifier) End() token.Position { return self.Token.End }
+
+func (self *LetStatement) Pos() token.Position { return self.Token.Start }
+func (self *LetStatement) End() token.Position {
+	if self.Value != nil {
+		return self.Value.End()
+	}
+	return self.Name.End()
+}
+
+func (self *ReturnStatement) Pos() token.Position { return self.Token.Start }
+func (self *ReturnStatement) End() token.Position {
+	if self.ReturnValue != nil {
+		return self.ReturnValue.End()
+	}
+	return self.Token.End
+}
+
+func (self *ExpressionStatement) Pos() token.Position { return self.Token.Start }
+func (self *ExpressionStatement) End() token.Position {
+	if self.Expression != nil {
+		return self.Expression.End()
+	}
+	return self.Token.End
+}
+
+func (self *IntegerLiteral) Pos() token.Position { return self.Token.Start }
+func (self *IntegerLiteral) End() token.Position { return self.Token.End }
+
+func (self *PrefixExpression) Pos() token.Position { return self.Token.Start }
+func (self *PrefixExpression) End() token.Position {
+	if self.Right != nil {
+		return self.Right.End()
+	}
+	return self.Token.End
+}
+
+func (self *InfixExpression) Pos() token.Position { return self.Left.Pos() }
+func (self *InfixExpression) End() token.Position {
+	if self.Right != nil {
+		return self.Right.End()
+	}
+	return self.Token.End
+}
+
+func (self *Boolean) Pos() token.Position { return self.Token.Start }
+func (self *Boolean) End() token.Position { return self.Token.End }
+
+func (self *IfExpression) Pos() token.Position { return self.Token.Start }
+func (self *IfExpression) End() token.Position {
+	if self.Alternative != nil {
+		return self.Alternative.End()
+	}
+	return self.Consequence.End()
+}
+
+func (self *BlockStatement) Pos() token.Position { return self.Token.Start }
+func (self *BlockStatement) End() token.Position { return self.Close.End }
+
+func (self *FunctionLiteral) Pos() token.Position { return self.Token.Start }
+func (self *FunctionLiteral) End() token.Position { return self.Body.End() }
+
+func (self *CallExpression) Pos() token.Position { return self.Function.Pos() }
+func (self *CallExpression) End() token.Position { return self.Close.End }
+
+func (self *StringLiteral) Pos() token.Position { return self.Token.Start }
+func (self *StringLiteral) End() token.Position { return self.Token.End }
+
+func (self *InterpolatedString) Pos() token.Position { return self.Token.Start }
+func (self *InterpolatedString) End() token.Position { return self.Token.End }
+
+func (self *ArrayLiteral) Pos() token.Position { return self.Token.Start }
+func (self *ArrayLiteral) End() token.Position { return self.Close.End }
+
+func (self *IndexExpression) Pos() token.Position { return self.Left.Pos() }
+func (self *IndexExpression) End() token.Position { return self.Close.End }
+
+func (self *HashLiteral) Pos() token.Position { return self.Token.Start }
+func (self *HashLiteral) End() token.Position { return self.Close.End }
@@ -0,0 +1,42 @@
+package ast
+
+// Scope lists the names bound in a function body, or in the global scope,
+// giving each one the slot where environments of the scope keep its value.
+// Blocks do not have scopes of their own, their lets bind names in the
+// enclosing function like they do in the evaluator.
+type Scope struct {
+	Outer *Scope
+	names []string
+	slots map[string]int
+}
+
+func NewScope(outer *Scope) *Scope {
+	return &Scope{Outer: outer, slots: make(map[string]int)}
+}
+
+// Define gives name a slot, or returns the slot it already has.
+func (self *Scope) Define(name string) int {
+	if slot, ok := self.slots[name]; ok {
+		return slot
+	}
+
+	slot := len(self.names)
+	self.names = append(self.names, name)
+	self.slots[name] = slot
+
+	return slot
+}
+
+func (self *Scope) Lookup(name string) (int, bool) {
+	slot, ok := self.slots[name]
+	return slot, ok
+}
+
+// Names returns the names of the scope, in slot order.
+func (self *Scope) Names() []string {
+	return self.names
+}
+
+func (self *Scope) Len() int {
+	return len(self.names)
+}
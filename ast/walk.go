@@ -0,0 +1,199 @@
+package ast
+
+import "sort"
+
+// A Visitor's Visit method is called by Walk on each node. When it returns a
+// non-nil visitor w, Walk visits the children of the node with w, then calls
+// w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree of node depth-first, children in source order.
+func Walk(visitor Visitor, node Node) {
+	if visitor = visitor.Visit(node); visitor == nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		walkStatements(visitor, node.Statements)
+	case *LetStatement:
+		Walk(visitor, node.Name)
+		walkExpression(visitor, node.Value)
+	case *ReturnStatement:
+		walkExpression(visitor, node.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(visitor, node.Expression)
+	case *BlockStatement:
+		walkStatements(visitor, node.Statements)
+	case *PrefixExpression:
+		walkExpression(visitor, node.Right)
+	case *InfixExpression:
+		walkExpression(visitor, node.Left)
+		walkExpression(visitor, node.Right)
+	case *IfExpression:
+		walkExpression(visitor, node.Condition)
+		Walk(visitor, node.Consequence)
+		if node.Alternative != nil {
+			Walk(visitor, node.Alternative)
+		}
+	case *FunctionLiteral:
+		for _, parameter := range node.Parameters {
+			Walk(visitor, parameter)
+		}
+		Walk(visitor, node.Body)
+	case *CallExpression:
+		walkExpression(visitor, node.Function)
+		walkExpressions(visitor, node.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(visitor, node.Elements)
+	case *IndexExpression:
+		walkExpression(visitor, node.Left)
+		walkExpression(visitor, node.Index)
+	case *HashLiteral:
+		for _, key := range node.Keys() {
+			Walk(visitor, key)
+			walkExpression(visitor, node.Pairs[key])
+		}
+	case *InterpolatedString:
+		walkExpressions(visitor, node.Parts)
+	}
+
+	visitor.Visit(nil)
+}
+
+// The helpers skip the nil nodes left by parse errors.
+
+func walkStatements(visitor Visitor, statements []Statement) {
+	for _, statement := range statements {
+		if statement != nil {
+			Walk(visitor, statement)
+		}
+	}
+}
+
+func walkExpression(visitor Visitor, expression Expression) {
+	if expression != nil {
+		Walk(visitor, expression)
+	}
+}
+
+func walkExpressions(visitor Visitor, expressions []Expression) {
+	for _, expression := range expressions {
+		walkExpression(visitor, expression)
+	}
+}
+
+type inspector func(Node) bool
+
+func (self inspector) Visit(node Node) Visitor {
+	if self(node) {
+		return self
+	}
+	return nil
+}
+
+// Inspect traverses the tree of node like Walk, calling f on each node. The
+// children of a node are visited when f returns true for it, and are followed
+// by a call to f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Keys returns the keys of the pairs in source order.
+func (self *HashLiteral) Keys() []Expression {
+	keys := make([]Expression, 0, len(self.Pairs))
+	for key := range self.Pairs {
+		keys = append(keys, key)
+	}
+
+	// nodes made up have no position, their text orders them
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Pos().Offset != keys[j].Pos().Offset {
+			return keys[i].Pos().Offset < keys[j].Pos().Offset
+		}
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
+
+type ModifierFunc func(Node) Node
+
+// Modify rebuilds the tree of node bottom-up: the children of a node are
+// replaced by what Modify returns for them, then the node by what modifier
+// returns for it. A child replaced by a node of the wrong kind becomes nil.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		modifyStatements(node.Statements, modifier)
+	case *LetStatement:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+	case *BlockStatement:
+		modifyStatements(node.Statements, modifier)
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+		if node.Alternative != nil {
+			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		}
+	case *FunctionLiteral:
+		for i, parameter := range node.Parameters {
+			node.Parameters[i], _ = Modify(parameter, modifier).(*Identifier)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		modifyExpressions(node.Arguments, modifier)
+	case *ArrayLiteral:
+		modifyExpressions(node.Elements, modifier)
+	case *IndexExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Index = modifyExpression(node.Index, modifier)
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for _, key := range node.Keys() {
+			value := modifyExpression(node.Pairs[key], modifier)
+			pairs[modifyExpression(key, modifier)] = value
+		}
+		node.Pairs = pairs
+	case *InterpolatedString:
+		modifyExpressions(node.Parts, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) {
+	for i, statement := range statements {
+		if statement != nil {
+			statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+	}
+}
+
+func modifyExpression(expression Expression, modifier ModifierFunc) Expression {
+	if expression == nil {
+		return nil
+	}
+
+	modified, _ := Modify(expression, modifier).(Expression)
+	return modified
+}
+
+func modifyExpressions(expressions []Expression, modifier ModifierFunc) {
+	for i, expression := range expressions {
+		expressions[i] = modifyExpression(expression, modifier)
+	}
+}
@@ -0,0 +1,199 @@
+package ast
+
+import (
+	"github.com/Neal-C/interpreter-in-go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func integer(value int64) *IntegerLiteral {
+	return &IntegerLiteral{Value: value}
+}
+
+func identifier(name string) *Identifier {
+	return &Identifier{Value: name}
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return integer(1) }
+	two := func() Expression { return integer(2) }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&InfixExpression{Left: two(), Operator: "+", Right: one()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&PrefixExpression{Operator: "-", Right: one()}, &PrefixExpression{Operator: "-", Right: two()}},
+		{&IndexExpression{Left: one(), Index: one()}, &IndexExpression{Left: two(), Index: two()}},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		{&LetStatement{Name: identifier("x"), Value: one()}, &LetStatement{Name: identifier("x"), Value: two()}},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{identifier("x")},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{identifier("x")},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&CallExpression{Function: one(), Arguments: []Expression{one(), two()}}, &CallExpression{Function: two(), Arguments: []Expression{two(), two()}}},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		{&InterpolatedString{Parts: []Expression{one()}}, &InterpolatedString{Parts: []Expression{two()}}},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+
+	hashLiteral := &HashLiteral{Pairs: map[Expression]Expression{one(): one(), two(): one()}}
+	Modify(hashLiteral, turnOneIntoTwo)
+
+	for key, value := range hashLiteral.Pairs {
+		if key.(*IntegerLiteral).Value != 2 || value.(*IntegerLiteral).Value != 2 {
+			t.Errorf("pair not modified: %s: %s", key, value)
+		}
+	}
+}
+
+func TestModifyParameters(t *testing.T) {
+	function := &FunctionLiteral{
+		Parameters: []*Identifier{identifier("a"), identifier("b")},
+		Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: identifier("a")}}},
+	}
+
+	rename := func(node Node) Node {
+		if identifier, ok := node.(*Identifier); ok {
+			identifier.Value = strings.ToUpper(identifier.Value)
+		}
+		return node
+	}
+
+	Modify(function, rename)
+
+	if function.Parameters[0].Value != "A" || function.Parameters[1].Value != "B" || function.Body.String() != "A" {
+		t.Errorf("parameters not renamed: %s", function.String())
+	}
+}
+
+type recorder struct {
+	events *[]string
+}
+
+func (self recorder) Visit(node Node) Visitor {
+	if node == nil {
+		*self.events = append(*self.events, "end")
+		return nil
+	}
+
+	*self.events = append(*self.events, reflect.TypeOf(node).Elem().Name())
+	return self
+}
+
+func TestWalk(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: identifier("f"), Value: &FunctionLiteral{
+			Parameters: []*Identifier{identifier("x")},
+			Body:       &BlockStatement{},
+		}},
+		&ExpressionStatement{Expression: &HashLiteral{Pairs: map[Expression]Expression{
+			&StringLiteral{Token: token.Token{Start: token.Position{Offset: 9}}, Value: "b"}: integer(2),
+			&StringLiteral{Token: token.Token{Start: token.Position{Offset: 1}}, Value: "a"}: integer(1),
+		}}},
+	}}
+
+	var events []string
+	Walk(recorder{&events}, program)
+
+	expected := []string{
+		"Program",
+		"LetStatement", "Identifier", "end", "FunctionLiteral", "Identifier", "end", "BlockStatement", "end", "end", "end",
+		"ExpressionStatement", "HashLiteral",
+		"StringLiteral", "end", "IntegerLiteral", "end",
+		"StringLiteral", "end", "IntegerLiteral", "end",
+		"end", "end",
+		"end",
+	}
+
+	if strings.Join(events, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong walk.\nexpected=%v\ngot=%v", expected, events)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: identifier("a"), Value: integer(1)},
+		&ExpressionStatement{Expression: &FunctionLiteral{
+			Parameters: []*Identifier{identifier("b")},
+			Body: &BlockStatement{Statements: []Statement{
+				&LetStatement{Name: identifier("c"), Value: integer(2)},
+			}},
+		}},
+		&ExpressionStatement{Expression: &HashLiteral{Pairs: map[Expression]Expression{
+			identifier("d"): &IfExpression{
+				Condition:   identifier("e"),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: identifier("f")}}},
+			},
+		}}},
+	}}
+
+	var all []string
+	Inspect(program, func(node Node) bool {
+		if identifier, ok := node.(*Identifier); ok {
+			all = append(all, identifier.Value)
+		}
+		return true
+	})
+
+	if strings.Join(all, "") != "abcdef" {
+		t.Errorf("identifiers visited: %v", all)
+	}
+
+	var outside []string
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *FunctionLiteral:
+			return false
+		case *Identifier:
+			outside = append(outside, node.Value)
+		}
+		return true
+	})
+
+	if strings.Join(outside, "") != "adef" {
+		t.Errorf("identifiers visited outside functions: %v", outside)
+	}
+}
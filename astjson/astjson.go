@@ -0,0 +1,588 @@
+// Package astjson encodes Monkey syntax trees as JSON, for tools not written
+// in Go, and decodes them back.
+//
+// Each node is an object with its "type", the name of its Go type in package
+// ast, and its "span", the positions of its first character and of the one
+// just past its last. The other members are the fields of the node, named
+// after those of the Go type: its tokens, its operator or value, and its
+// children, null when missing. The pairs of a hash literal are a list of
+// {"key", "value"} objects in source order.
+//
+// Only the alternative of an if expression may be missing: decoding fails on
+// a tree missing another child, or holding null in a list, which the parser
+// never produces and the evaluator cannot run.
+//
+// Decoding an encoded tree gives back the tree that was encoded, except for
+// what the resolver and the evaluator store in it. Spans are ignored when
+// decoding, the tokens hold the positions.
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"reflect"
+)
+
+type span struct {
+	Start token.Position `json:"start"`
+	End   token.Position `json:"end"`
+}
+
+// Marshal returns the JSON encoding of program.
+func Marshal(program *ast.Program) ([]byte, error) {
+	return MarshalNode(program)
+}
+
+// MarshalNode returns the JSON encoding of node and its children.
+func MarshalNode(node ast.Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// Unmarshal decodes the program encoded by data.
+func Unmarshal(data []byte) (*ast.Program, error) {
+	node, err := UnmarshalNode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	program, ok := node.(*ast.Program)
+	if !ok {
+		return nil, fmt.Errorf("not a program: %s", typeName(node))
+	}
+
+	return program, nil
+}
+
+// UnmarshalNode decodes the node encoded by data.
+func UnmarshalNode(data []byte) (ast.Node, error) {
+	decoder := &decoder{}
+	node := decoder.node(data)
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("no node in %s", data)
+	}
+
+	return node, nil
+}
+
+func typeName(node ast.Node) string {
+	return reflect.TypeOf(node).Elem().Name()
+}
+
+// isNil reports whether node is missing. A failed parse may leave a nil
+// pointer in a statement.
+func isNil(node ast.Node) bool {
+	if node == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
+
+type member struct {
+	name  string
+	value any
+}
+
+// object is a JSON object keeping its members in order, the type of a node
+// first.
+type object []member
+
+func (self object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+
+	out.WriteString("{")
+	for i, member := range self {
+		if i > 0 {
+			out.WriteString(",")
+		}
+
+		name, err := json.Marshal(member.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+
+		out.Write(name)
+		out.WriteString(":")
+		out.Write(value)
+	}
+	out.WriteString("}")
+
+	return out.Bytes(), nil
+}
+
+// encode turns node into values encoding/json marshals as described above.
+func encode(node ast.Node) any {
+	if isNil(node) {
+		return nil
+	}
+
+	fields := object{
+		{"type", typeName(node)},
+		{"span", span{Start: node.Pos(), End: node.End()}},
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		fields = append(fields, member{"statements", encodeStatements(node.Statements)})
+	case *ast.LetStatement:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"name", encode(node.Name)})
+		fields = append(fields, member{"value", encode(node.Value)})
+	case *ast.ReturnStatement:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"returnValue", encode(node.ReturnValue)})
+	case *ast.ExpressionStatement:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"expression", encode(node.Expression)})
+	case *ast.BlockStatement:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"statements", encodeStatements(node.Statements)})
+		fields = append(fields, member{"close", node.Close})
+	case *ast.Identifier:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"value", node.Value})
+	case *ast.IntegerLiteral:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"value", node.Value})
+	case *ast.Boolean:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"value", node.Value})
+	case *ast.StringLiteral:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"value", node.Value})
+	case *ast.InterpolatedString:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"parts", encodeExpressions(node.Parts)})
+	case *ast.PrefixExpression:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"operator", node.Operator})
+		fields = append(fields, member{"right", encode(node.Right)})
+	case *ast.InfixExpression:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"left", encode(node.Left)})
+		fields = append(fields, member{"operator", node.Operator})
+		fields = append(fields, member{"right", encode(node.Right)})
+	case *ast.IfExpression:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"condition", encode(node.Condition)})
+		fields = append(fields, member{"consequence", encode(node.Consequence)})
+		fields = append(fields, member{"alternative", encode(node.Alternative)})
+	case *ast.FunctionLiteral:
+		fields = append(fields, member{"token", node.Token})
+		var parameters []any
+		if node.Parameters != nil {
+			parameters = make([]any, len(node.Parameters))
+		}
+		for i, parameter := range node.Parameters {
+			parameters[i] = encode(parameter)
+		}
+		fields = append(fields, member{"parameters", parameters})
+		fields = append(fields, member{"body", encode(node.Body)})
+	case *ast.CallExpression:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"function", encode(node.Function)})
+		fields = append(fields, member{"arguments", encodeExpressions(node.Arguments)})
+		fields = append(fields, member{"close", node.Close})
+	case *ast.ArrayLiteral:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"elements", encodeExpressions(node.Elements)})
+		fields = append(fields, member{"close", node.Close})
+	case *ast.IndexExpression:
+		fields = append(fields, member{"token", node.Token})
+		fields = append(fields, member{"left", encode(node.Left)})
+		fields = append(fields, member{"index", encode(node.Index)})
+		fields = append(fields, member{"close", node.Close})
+	case *ast.HashLiteral:
+		fields = append(fields, member{"token", node.Token})
+		var pairs []any
+		if node.Pairs != nil {
+			pairs = []any{}
+		}
+		for _, key := range node.Keys() {
+			pairs = append(pairs, object{{"key", encode(key)}, {"value", encode(node.Pairs[key])}})
+		}
+		fields = append(fields, member{"pairs", pairs})
+		fields = append(fields, member{"close", node.Close})
+	}
+
+	return fields
+}
+
+// The lists keep nil apart from empty, as null and [].
+
+func encodeStatements(statements []ast.Statement) []any {
+	if statements == nil {
+		return nil
+	}
+
+	encoded := make([]any, len(statements))
+	for i, statement := range statements {
+		encoded[i] = encode(statement)
+	}
+	return encoded
+}
+
+func encodeExpressions(expressions []ast.Expression) []any {
+	if expressions == nil {
+		return nil
+	}
+
+	encoded := make([]any, len(expressions))
+	for i, expression := range expressions {
+		encoded[i] = encode(expression)
+	}
+	return encoded
+}
+
+// decoder keeps the first error it meets, its methods return zero values once
+// it has one.
+type decoder struct {
+	err error
+}
+
+func (self *decoder) fail(format string, args ...any) {
+	if self.err == nil {
+		self.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (self *decoder) unmarshal(data json.RawMessage, value any) {
+	if self.err != nil {
+		return
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		self.fail("%v in %s", err, data)
+	}
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
+func (self *decoder) node(data json.RawMessage) ast.Node {
+	if self.err != nil || isNull(data) {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	self.unmarshal(data, &fields)
+
+	if self.err == nil && fields["type"] == nil {
+		self.fail("node without a type in %s", data)
+	}
+
+	var kind string
+	self.unmarshal(fields["type"], &kind)
+	if self.err != nil {
+		return nil
+	}
+
+	node := self.decode(kind, fields)
+	if self.err != nil {
+		return nil
+	}
+
+	self.check(node)
+	return node
+}
+
+func (self *decoder) decode(kind string, fields map[string]json.RawMessage) ast.Node {
+	switch kind {
+	case "Program":
+		return &ast.Program{Statements: self.statements(fields["statements"])}
+	case "LetStatement":
+		return &ast.LetStatement{
+			Token: self.token(fields["token"]),
+			Name:  self.identifier(fields["name"]),
+			Value: self.expression(fields["value"]),
+		}
+	case "ReturnStatement":
+		return &ast.ReturnStatement{
+			Token:       self.token(fields["token"]),
+			ReturnValue: self.expression(fields["returnValue"]),
+		}
+	case "ExpressionStatement":
+		return &ast.ExpressionStatement{
+			Token:      self.token(fields["token"]),
+			Expression: self.expression(fields["expression"]),
+		}
+	case "BlockStatement":
+		return &ast.BlockStatement{
+			Token:      self.token(fields["token"]),
+			Statements: self.statements(fields["statements"]),
+			Close:      self.token(fields["close"]),
+		}
+	case "Identifier":
+		identifier := &ast.Identifier{Token: self.token(fields["token"])}
+		self.unmarshal(fields["value"], &identifier.Value)
+		return identifier
+	case "IntegerLiteral":
+		integer := &ast.IntegerLiteral{Token: self.token(fields["token"])}
+		self.unmarshal(fields["value"], &integer.Value)
+		return integer
+	case "Boolean":
+		boolean := &ast.Boolean{Token: self.token(fields["token"])}
+		self.unmarshal(fields["value"], &boolean.Value)
+		return boolean
+	case "StringLiteral":
+		str := &ast.StringLiteral{Token: self.token(fields["token"])}
+		self.unmarshal(fields["value"], &str.Value)
+		return str
+	case "InterpolatedString":
+		return &ast.InterpolatedString{
+			Token: self.token(fields["token"]),
+			Parts: self.expressions(fields["parts"]),
+		}
+	case "PrefixExpression":
+		prefix := &ast.PrefixExpression{
+			Token: self.token(fields["token"]),
+			Right: self.expression(fields["right"]),
+		}
+		self.unmarshal(fields["operator"], &prefix.Operator)
+		return prefix
+	case "InfixExpression":
+		infix := &ast.InfixExpression{
+			Token: self.token(fields["token"]),
+			Left:  self.expression(fields["left"]),
+			Right: self.expression(fields["right"]),
+		}
+		self.unmarshal(fields["operator"], &infix.Operator)
+		return infix
+	case "IfExpression":
+		return &ast.IfExpression{
+			Token:       self.token(fields["token"]),
+			Condition:   self.expression(fields["condition"]),
+			Consequence: self.block(fields["consequence"]),
+			Alternative: self.block(fields["alternative"]),
+		}
+	case "FunctionLiteral":
+		return &ast.FunctionLiteral{
+			Token:      self.token(fields["token"]),
+			Parameters: self.identifiers(fields["parameters"]),
+			Body:       self.block(fields["body"]),
+		}
+	case "CallExpression":
+		return &ast.CallExpression{
+			Token:     self.token(fields["token"]),
+			Function:  self.expression(fields["function"]),
+			Arguments: self.expressions(fields["arguments"]),
+			Close:     self.token(fields["close"]),
+		}
+	case "ArrayLiteral":
+		return &ast.ArrayLiteral{
+			Token:    self.token(fields["token"]),
+			Elements: self.expressions(fields["elements"]),
+			Close:    self.token(fields["close"]),
+		}
+	case "IndexExpression":
+		return &ast.IndexExpression{
+			Token: self.token(fields["token"]),
+			Left:  self.expression(fields["left"]),
+			Index: self.expression(fields["index"]),
+			Close: self.token(fields["close"]),
+		}
+	case "HashLiteral":
+		return &ast.HashLiteral{
+			Token: self.token(fields["token"]),
+			Pairs: self.pairs(fields["pairs"]),
+			Close: self.token(fields["close"]),
+		}
+	default:
+		self.fail("unknown node type %q", kind)
+		return nil
+	}
+}
+
+// check fails on a node missing a child the parser always sets.
+func (self *decoder) check(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		self.require(node, "name", node.Name != nil)
+		self.require(node, "value", node.Value != nil)
+	case *ast.ReturnStatement:
+		self.require(node, "returnValue", node.ReturnValue != nil)
+	case *ast.ExpressionStatement:
+		self.require(node, "expression", node.Expression != nil)
+	case *ast.PrefixExpression:
+		self.require(node, "right", node.Right != nil)
+	case *ast.InfixExpression:
+		self.require(node, "left", node.Left != nil)
+		self.require(node, "right", node.Right != nil)
+	case *ast.IfExpression:
+		self.require(node, "condition", node.Condition != nil)
+		self.require(node, "consequence", node.Consequence != nil)
+	case *ast.FunctionLiteral:
+		self.require(node, "body", node.Body != nil)
+	case *ast.CallExpression:
+		self.require(node, "function", node.Function != nil)
+	case *ast.IndexExpression:
+		self.require(node, "left", node.Left != nil)
+		self.require(node, "index", node.Index != nil)
+	}
+}
+
+func (self *decoder) require(node ast.Node, field string, present bool) {
+	if !present {
+		self.fail("%s without %s", typeName(node), field)
+	}
+}
+
+func (self *decoder) token(data json.RawMessage) token.Token {
+	var tok token.Token
+	if !isNull(data) {
+		self.unmarshal(data, &tok)
+	}
+	return tok
+}
+
+func (self *decoder) list(data json.RawMessage) []json.RawMessage {
+	var list []json.RawMessage
+	if !isNull(data) {
+		self.unmarshal(data, &list)
+	}
+	return list
+}
+
+func (self *decoder) statement(data json.RawMessage) ast.Statement {
+	node := self.node(data)
+	if node == nil {
+		return nil
+	}
+
+	statement, ok := node.(ast.Statement)
+	if !ok {
+		self.fail("%s is not a statement", typeName(node))
+	}
+	return statement
+}
+
+func (self *decoder) expression(data json.RawMessage) ast.Expression {
+	node := self.node(data)
+	if node == nil {
+		return nil
+	}
+
+	expression, ok := node.(ast.Expression)
+	if !ok {
+		self.fail("%s is not an expression", typeName(node))
+	}
+	return expression
+}
+
+func (self *decoder) identifier(data json.RawMessage) *ast.Identifier {
+	node := self.node(data)
+	if node == nil {
+		return nil
+	}
+
+	identifier, ok := node.(*ast.Identifier)
+	if !ok {
+		self.fail("%s is not an Identifier", typeName(node))
+	}
+	return identifier
+}
+
+func (self *decoder) block(data json.RawMessage) *ast.BlockStatement {
+	node := self.node(data)
+	if node == nil {
+		return nil
+	}
+
+	block, ok := node.(*ast.BlockStatement)
+	if !ok {
+		self.fail("%s is not a BlockStatement", typeName(node))
+	}
+	return block
+}
+
+func (self *decoder) statements(data json.RawMessage) []ast.Statement {
+	list := self.list(data)
+	if list == nil {
+		return nil
+	}
+
+	statements := make([]ast.Statement, len(list))
+	for i, item := range list {
+		if isNull(item) {
+			self.fail("null statement in %s", data)
+			return nil
+		}
+		statements[i] = self.statement(item)
+	}
+	return statements
+}
+
+func (self *decoder) expressions(data json.RawMessage) []ast.Expression {
+	list := self.list(data)
+	if list == nil {
+		return nil
+	}
+
+	expressions := make([]ast.Expression, len(list))
+	for i, item := range list {
+		if isNull(item) {
+			self.fail("null expression in %s", data)
+			return nil
+		}
+		expressions[i] = self.expression(item)
+	}
+	return expressions
+}
+
+func (self *decoder) identifiers(data json.RawMessage) []*ast.Identifier {
+	list := self.list(data)
+	if list == nil {
+		return nil
+	}
+
+	identifiers := make([]*ast.Identifier, len(list))
+	for i, item := range list {
+		if isNull(item) {
+			self.fail("null identifier in %s", data)
+			return nil
+		}
+		identifiers[i] = self.identifier(item)
+	}
+	return identifiers
+}
+
+func (self *decoder) pairs(data json.RawMessage) map[ast.Expression]ast.Expression {
+	list := self.list(data)
+	if list == nil {
+		return nil
+	}
+
+	pairs := make(map[ast.Expression]ast.Expression, len(list))
+	for _, item := range list {
+		var pair struct {
+			Key   json.RawMessage `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		self.unmarshal(item, &pair)
+
+		key := self.expression(pair.Key)
+		if key == nil {
+			self.fail("hash pair without a key in %s", item)
+			return nil
+		}
+		value := self.expression(pair.Value)
+		if value == nil {
+			self.fail("hash pair without a value in %s", item)
+			return nil
+		}
+		pairs[key] = value
+	}
+	return pairs
+}
@@ -0,0 +1,205 @@
+package astjson
+
+import (
+	"encoding/json"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	monkeyParser := parser.New(lexer.New(input))
+	program := monkeyParser.ParseProgram()
+	if len(monkeyParser.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, monkeyParser.Errors())
+	}
+	return program
+}
+
+// equal compares trees like reflect.DeepEqual, but the keys of hash literals,
+// pointers, by the nodes they point to.
+func equal(a, b ast.Node) bool {
+	var aNodes, bNodes []ast.Node
+	ast.Inspect(a, func(node ast.Node) bool { aNodes = append(aNodes, node); return true })
+	ast.Inspect(b, func(node ast.Node) bool { bNodes = append(bNodes, node); return true })
+
+	if len(aNodes) != len(bNodes) {
+		return false
+	}
+
+	for i := range aNodes {
+		aHash, ok := aNodes[i].(*ast.HashLiteral)
+		if !ok {
+			continue
+		}
+		bHash, ok := bNodes[i].(*ast.HashLiteral)
+		if !ok || len(aHash.Pairs) != len(bHash.Pairs) || aHash.Token != bHash.Token || aHash.Close != bHash.Close {
+			return false
+		}
+
+		aKeys, bKeys := aHash.Keys(), bHash.Keys()
+		for j := range aKeys {
+			if !equal(aKeys[j], bKeys[j]) || !equal(aHash.Pairs[aKeys[j]], bHash.Pairs[bKeys[j]]) {
+				return false
+			}
+		}
+
+		// the pairs are equal, make them the same for DeepEqual
+		bHash.Pairs = aHash.Pairs
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"let x = 5; return x;",
+		"-a * b + !c == d[0] != e(1, 2)",
+		"if (x < y) { x } else { y }; if (true) {}",
+		`let add = fn(a, b) { a + b }; let noop = fn() {}; add(1, 2); noop()`,
+		`[]; [1, "two", [3]]; {}; {"a": 1, 2: fn(x) { x }, true: {"b": [c]}}`,
+		`let name = "monkey"; puts("hello ${name}, ${1 + len("${name}!")} times")`,
+		"let f = fn(x) {\n    // comment\n    return x * 2;\n};\nf(21)\n",
+	}
+
+	for _, input := range tests {
+		program := parse(t, input)
+
+		data, err := Marshal(program)
+		if err != nil {
+			t.Fatalf("Marshal(%q) failed: %s", input, err)
+		}
+
+		decoded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Unmarshal of %q failed: %s\n%s", input, err, data)
+		}
+
+		if !equal(decoded, program) {
+			t.Errorf("round trip of %q changed the program. got=%q, want=%q", input, decoded.String(), program.String())
+		}
+
+		again, err := Marshal(decoded)
+		if err != nil {
+			t.Fatalf("Marshal of decoded %q failed: %s", input, err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("encodings of %q differ.\nfirst=%s\nsecond=%s", input, data, again)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	data, err := Marshal(parse(t, "-x"))
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+
+	var program map[string]any
+	if err := json.Unmarshal(data, &program); err != nil {
+		t.Fatalf("not JSON: %s", err)
+	}
+
+	statement := program["statements"].([]any)[0].(map[string]any)
+	prefix := statement["expression"].(map[string]any)
+	right := prefix["right"].(map[string]any)
+
+	tests := []struct {
+		value    any
+		expected any
+	}{
+		{program["type"], "Program"},
+		{statement["type"], "ExpressionStatement"},
+		{prefix["type"], "PrefixExpression"},
+		{prefix["operator"], "-"},
+		{prefix["token"].(map[string]any)["type"], "-"},
+		{prefix["span"].(map[string]any)["end"].(map[string]any)["column"], 3.0},
+		{right["type"], "Identifier"},
+		{right["value"], "x"},
+		{right["token"].(map[string]any)["literal"], "x"},
+		{right["span"].(map[string]any)["start"].(map[string]any)["offset"], 1.0},
+	}
+
+	for i, tt := range tests {
+		if tt.value != tt.expected {
+			t.Errorf("tests[%d] - wrong value. got=%v, want=%v", i, tt.value, tt.expected)
+		}
+	}
+
+	if !strings.HasPrefix(string(data), `{"type":"Program","span":`) {
+		t.Errorf("type and span do not come first: %s", data)
+	}
+}
+
+func TestMissingChildren(t *testing.T) {
+	node := &ast.IfExpression{
+		Condition:   &ast.Boolean{Value: true},
+		Consequence: &ast.BlockStatement{},
+	}
+
+	data, err := MarshalNode(node)
+	if err != nil {
+		t.Fatalf("MarshalNode failed: %s", err)
+	}
+	if !strings.Contains(string(data), `"alternative":null`) {
+		t.Errorf("missing alternative not null: %s", data)
+	}
+
+	decoded, err := UnmarshalNode(data)
+	if err != nil {
+		t.Fatalf("UnmarshalNode failed: %s", err)
+	}
+	if !reflect.DeepEqual(decoded, node) {
+		t.Errorf("wrong node. got=%#v, want=%#v", decoded, node)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "cannot unmarshal array"},
+		{`null`, "no node in null"},
+		{`{"statements": []}`, "node without a type"},
+		{`{"type": "Loop"}`, `unknown node type "Loop"`},
+		{`{"type": "Identifier", "value": 1}`, "cannot unmarshal number"},
+		{`{"type": "Identifier", "value": "x"}`, "not a program: Identifier"},
+		{`{"type": "Program", "statements": [{"type": "Identifier", "value": "x"}]}`, "Identifier is not a statement"},
+		{`{"type": "LetStatement", "name": {"type": "Boolean", "value": true}}`, "Boolean is not an Identifier"},
+		{`{"type": "HashLiteral", "pairs": [{"value": {"type": "Boolean"}}]}`, "hash pair without a key"},
+		{`{"type": "HashLiteral", "pairs": [{"key": {"type": "Boolean", "value": true}}]}`, "hash pair without a value"},
+		{`{"type": "Program", "statements": [{"type": "LetStatement"}]}`, "LetStatement without name"},
+		{`{"type": "Program", "statements": [{"type": "LetStatement", "name": {"type": "Identifier", "value": "x"}}]}`, "LetStatement without value"},
+		{`{"type": "Program", "statements": [null]}`, "null statement"},
+		{`{"type": "Program", "statements": [{"type": "ReturnStatement"}]}`, "ReturnStatement without returnValue"},
+		{`{"type": "Program", "statements": [{"type": "ExpressionStatement"}]}`, "ExpressionStatement without expression"},
+		{`{"type": "PrefixExpression", "operator": "-"}`, "PrefixExpression without right"},
+		{`{"type": "InfixExpression", "operator": "+", "right": {"type": "Boolean", "value": true}}`, "InfixExpression without left"},
+		{`{"type": "InfixExpression", "operator": "+", "left": {"type": "Boolean", "value": true}}`, "InfixExpression without right"},
+		{`{"type": "IfExpression", "consequence": {"type": "BlockStatement"}}`, "IfExpression without condition"},
+		{`{"type": "IfExpression", "condition": {"type": "Boolean", "value": true}}`, "IfExpression without consequence"},
+		{`{"type": "FunctionLiteral", "parameters": []}`, "FunctionLiteral without body"},
+		{`{"type": "FunctionLiteral", "parameters": [null], "body": {"type": "BlockStatement"}}`, "null identifier"},
+		{`{"type": "CallExpression", "arguments": []}`, "CallExpression without function"},
+		{`{"type": "CallExpression", "function": {"type": "Identifier", "value": "f"}, "arguments": [null]}`, "null expression"},
+		{`{"type": "ArrayLiteral", "elements": [null]}`, "null expression"},
+		{`{"type": "IndexExpression", "index": {"type": "Boolean", "value": true}}`, "IndexExpression without left"},
+		{`{"type": "IndexExpression", "left": {"type": "Boolean", "value": true}}`, "IndexExpression without index"},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal([]byte(tt.input))
+		if err == nil {
+			t.Errorf("no error for %s", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %s. got=%q, want %q in it", tt.input, err, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.mk")
+	if err := os.WriteFile(broken, []byte("let = 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.mk")
+
+	commands := []struct {
+		name string
+		run  func(paths []string, out io.Writer, errOut io.Writer) int
+	}{
+		{"lint", runLint},
+		{"fmt", runFormat},
+		{"dot", runDot},
+		{"-dump-ast", func(paths []string, out io.Writer, errOut io.Writer) int {
+			return runDump(paths, false, out, errOut)
+		}},
+	}
+
+	tests := []struct {
+		paths          []string
+		expectedStatus int
+		expectedErr    string
+	}{
+		{[]string{missing}, 1, missing + ": open " + missing + ": no such file or directory\n"},
+		{[]string{broken}, 2, broken + ": expected next token to be IDENT, got = instead\n"},
+		{[]string{missing, broken}, 2, broken + ": expected next token to be IDENT, got = instead\n"},
+	}
+
+	for _, command := range commands {
+		for _, tt := range tests {
+			var out, errOut bytes.Buffer
+			status := command.run(tt.paths, &out, &errOut)
+
+			if status != tt.expectedStatus {
+				t.Errorf("%s %v exited with %d, want=%d", command.name, tt.paths, status, tt.expectedStatus)
+			}
+			if out.Len() != 0 {
+				t.Errorf("%s %v printed %q, want nothing", command.name, tt.paths, out.String())
+			}
+			if !strings.Contains(errOut.String(), tt.expectedErr) {
+				t.Errorf("%s %v reported %q, want it to contain %q", command.name, tt.paths, errOut.String(), tt.expectedErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,534 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/code"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"sort"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	builtins    map[string]*object.Builtin
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// err is the first operand that did not fit in its instruction, which
+	// ends the compilation.
+	err error
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	// GlobalNames maps global slots back to names, for runtime error messages
+	GlobalNames []string
+}
+
+// New creates a compiler that resolves names it cannot find in scope against builtins.
+func New(builtins map[string]*object.Builtin) *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		builtins:    builtins,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// NewWithState creates a compiler that carries on from a previous compilation,
+// as the REPL does from one line to the next.
+func NewWithState(symbolTable *SymbolTable, constants []object.Object, builtins map[string]*object.Builtin) *Compiler {
+	compiler := New(builtins)
+	compiler.symbolTable = symbolTable
+	compiler.constants = constants
+	return compiler
+}
+
+func (self *Compiler) Compile(node ast.Node) error {
+	if err := self.compile(node); err != nil {
+		return err
+	}
+
+	return self.err
+}
+
+func (self *Compiler) compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			if err := self.Compile(stmt); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := self.Compile(node.Expression); err != nil {
+			return err
+		}
+		self.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			if err := self.Compile(stmt); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		var err error
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+			err = self.compileFunction(fn, node.Name.Value)
+		} else {
+			err = self.Compile(node.Value)
+		}
+		if err != nil {
+			return err
+		}
+
+		symbol := self.symbolTable.Define(node.Name.Value)
+		if symbol.Scope == GlobalScope {
+			self.emit(code.OpSetGlobal, symbol.Index)
+		} else {
+			self.emit(code.OpSetLocal, symbol.Index)
+		}
+	case *ast.ReturnStatement:
+		if err := self.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		self.emit(code.OpReturnValue)
+	case *ast.Identifier:
+		self.loadSymbol(self.resolve(node.Value))
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		self.emit(code.OpConstant, self.addConstant(integer))
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		self.emit(code.OpConstant, self.addConstant(str))
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := self.Compile(part); err != nil {
+				return err
+			}
+		}
+		self.emit(code.OpInterpolate, len(node.Parts))
+	case *ast.Boolean:
+		if node.Value {
+			self.emit(code.OpTrue)
+		} else {
+			self.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := self.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			self.emit(code.OpBang)
+		case "-":
+			self.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		if err := self.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := self.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		self.emit(op)
+	case *ast.IfExpression:
+		return self.compileIfExpression(node)
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			if err := self.Compile(element); err != nil {
+				return err
+			}
+		}
+		self.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		keys := []ast.Expression{}
+		for key := range node.Pairs {
+			keys = append(keys, key)
+		}
+
+		// the literal's pairs live in a map, sort them so that the output is reproducible
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		for _, key := range keys {
+			if err := self.Compile(key); err != nil {
+				return err
+			}
+			if err := self.Compile(node.Pairs[key]); err != nil {
+				return err
+			}
+		}
+		self.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := self.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := self.Compile(node.Index); err != nil {
+			return err
+		}
+		self.emit(code.OpIndex)
+	case *ast.FunctionLiteral:
+		return self.compileFunction(node, "")
+	case *ast.CallExpression:
+		if err := self.Compile(node.Function); err != nil {
+			return err
+		}
+
+		for _, arg := range node.Arguments {
+			if err := self.Compile(arg); err != nil {
+				return err
+			}
+		}
+		self.emit(code.OpCall, len(node.Arguments))
+	default:
+		return fmt.Errorf("unable to compile %T", node)
+	}
+
+	return nil
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+}
+
+func (self *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := self.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// bogus offsets, patched once the branches are compiled
+	jumpNotTruthyPosition := self.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := self.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPosition := self.emit(code.OpJump, 9999)
+
+	self.changeOperand(jumpNotTruthyPosition, len(self.currentInstructions()))
+
+	if node.Alternative == nil {
+		self.emit(code.OpNull)
+	} else if err := self.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	self.changeOperand(jumpPosition, len(self.currentInstructions()))
+
+	return nil
+}
+
+// compileBlockValue compiles a block whose last value stays on the stack,
+// which is null when the block does not end with an expression.
+func (self *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := self.Compile(block); err != nil {
+		return err
+	}
+
+	if self.lastInstructionIs(code.OpPop) {
+		self.removeLastPop()
+	} else {
+		self.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+// compileFunction compiles a function literal into a closure. A non-empty
+// name is the let binding of the literal, which lets the function call itself.
+func (self *Compiler) compileFunction(node *ast.FunctionLiteral, name string) error {
+	self.enterScope()
+	self.symbolTable.declared = declaredNames(node.Body)
+
+	if name != "" {
+		self.symbolTable.DefineFunctionName(name)
+	}
+
+	for _, param := range node.Parameters {
+		self.symbolTable.DefineParameter(param.Value)
+	}
+
+	if err := self.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if self.lastInstructionIs(code.OpPop) {
+		self.replaceLastPopWithReturn()
+	}
+	if !self.lastInstructionIs(code.OpReturnValue) {
+		self.emit(code.OpReturn)
+	}
+
+	freeSymbols := self.symbolTable.FreeSymbols
+	numLocals := self.symbolTable.numDefinitions
+	localNames := self.symbolTable.names(LocalScope, numLocals)
+	instructions := self.leaveScope()
+
+	freeNames := make([]string, len(freeSymbols))
+	for i, symbol := range freeSymbols {
+		self.captureSymbol(symbol)
+		freeNames[i] = symbol.Name
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Literal:       node,
+		Name:          name,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+	}
+
+	self.emit(code.OpClosure, self.addConstant(compiledFn), len(freeSymbols))
+
+	return nil
+}
+
+// declaredNames returns the names the lets of body bind, those of blocks
+// nested in it included but not those of nested functions.
+func declaredNames(body *ast.BlockStatement) map[string]bool {
+	declared := map[string]bool{}
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			declared[node.Name.Value] = true
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return true
+	})
+
+	return declared
+}
+
+// resolve finds the symbol of a name. Builtins are compiled into constants.
+// A name that is not bound anywhere yet is taken to be a global defined later,
+// the vm reports it as not found if it is still unset when read.
+func (self *Compiler) resolve(name string) Symbol {
+	if symbol, ok := self.symbolTable.Resolve(name); ok {
+		return symbol
+	}
+
+	if builtin, ok := self.builtins[name]; ok {
+		return self.symbolTable.outermost().DefineBuiltin(self.addConstant(builtin), name)
+	}
+
+	return self.symbolTable.outermost().Define(name)
+}
+
+func (self *Compiler) loadSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		self.emit(code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		self.emit(code.OpGetLocal, symbol.Index)
+	case BuiltinScope:
+		self.emit(code.OpConstant, symbol.Index)
+	case FreeScope:
+		self.emit(code.OpGetFree, symbol.Index)
+	case FunctionScope:
+		self.emit(code.OpCurrentClosure)
+	}
+}
+
+// captureSymbol loads what a closure being made captures of symbol. Locals
+// and free variables are captured as cells, which the closure shares with the
+// function binding them.
+func (self *Compiler) captureSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case LocalScope:
+		self.emit(code.OpGetLocalCell, symbol.Index)
+	case FreeScope:
+		self.emit(code.OpGetFreeCell, symbol.Index)
+	default:
+		self.loadSymbol(symbol)
+	}
+}
+
+func (self *Compiler) Bytecode() *Bytecode {
+	globals := self.symbolTable.outermost()
+
+	return &Bytecode{
+		Instructions: self.currentInstructions(),
+		Constants:    self.constants,
+		GlobalNames:  globals.names(GlobalScope, globals.numDefinitions),
+	}
+}
+
+// SymbolTable returns the global symbol table, to carry it over to the next compilation.
+func (self *Compiler) SymbolTable() *SymbolTable {
+	return self.symbolTable.outermost()
+}
+
+func (self *Compiler) addConstant(obj object.Object) int {
+	self.constants = append(self.constants, obj)
+	return len(self.constants) - 1
+}
+
+// operandNames are what the operands of instructions count or index, for
+// the error reported when one does not fit in its width.
+var operandNames = map[code.Opcode][]string{
+	code.OpConstant:      {"constants"},
+	code.OpJumpNotTruthy: {"bytes of instructions in a function"},
+	code.OpJump:          {"bytes of instructions in a function"},
+	code.OpGetGlobal:     {"global bindings"},
+	code.OpSetGlobal:     {"global bindings"},
+	code.OpGetLocal:      {"local bindings in a function"},
+	code.OpSetLocal:      {"local bindings in a function"},
+	code.OpGetFree:       {"free variables in a function"},
+	code.OpGetLocalCell:  {"local bindings in a function"},
+	code.OpGetFreeCell:   {"free variables in a function"},
+	code.OpArray:         {"elements in an array literal"},
+	code.OpHash:          {"keys and values in a hash literal"},
+	code.OpInterpolate:   {"parts in a string"},
+	code.OpCall:          {"arguments in a call"},
+	code.OpClosure:       {"constants", "free variables in a function"},
+}
+
+// checkOperands records an error when an operand of op is too large for its
+// width, code.Make would wrap it around.
+func (self *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil || self.err != nil {
+		return
+	}
+
+	for i, width := range def.OperandWidths {
+		limit := 1 << (8 * width)
+		if i < len(operands) && operands[i] >= limit {
+			self.err = fmt.Errorf("too many %s, the limit is %d", operandNames[op][i], limit)
+			return
+		}
+	}
+}
+
+// emit appends an instruction to the current scope and returns its position.
+func (self *Compiler) emit(op code.Opcode, operands ...int) int {
+	self.checkOperands(op, operands)
+	instruction := code.Make(op, operands...)
+	position := self.addInstruction(instruction)
+
+	self.setLastInstruction(op, position)
+
+	return position
+}
+
+func (self *Compiler) addInstruction(instruction []byte) int {
+	position := len(self.currentInstructions())
+	self.scopes[self.scopeIndex].instructions = append(self.currentInstructions(), instruction...)
+	return position
+}
+
+func (self *Compiler) setLastInstruction(op code.Opcode, position int) {
+	previous := self.scopes[self.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: position}
+
+	self.scopes[self.scopeIndex].previousInstruction = previous
+	self.scopes[self.scopeIndex].lastInstruction = last
+}
+
+func (self *Compiler) currentInstructions() code.Instructions {
+	return self.scopes[self.scopeIndex].instructions
+}
+
+func (self *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(self.currentInstructions()) == 0 {
+		return false
+	}
+
+	return self.scopes[self.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (self *Compiler) removeLastPop() {
+	last := self.scopes[self.scopeIndex].lastInstruction
+	previous := self.scopes[self.scopeIndex].previousInstruction
+
+	self.scopes[self.scopeIndex].instructions = self.currentInstructions()[:last.Position]
+	self.scopes[self.scopeIndex].lastInstruction = previous
+}
+
+func (self *Compiler) replaceInstruction(position int, newInstruction []byte) {
+	instructions := self.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		instructions[position+i] = newInstruction[i]
+	}
+}
+
+func (self *Compiler) changeOperand(opPosition int, operand int) {
+	op := code.Opcode(self.currentInstructions()[opPosition])
+	self.checkOperands(op, []int{operand})
+	newInstruction := code.Make(op, operand)
+
+	self.replaceInstruction(opPosition, newInstruction)
+}
+
+func (self *Compiler) replaceLastPopWithReturn() {
+	lastPosition := self.scopes[self.scopeIndex].lastInstruction.Position
+	self.replaceInstruction(lastPosition, code.Make(code.OpReturnValue))
+
+	self.scopes[self.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (self *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	self.scopes = append(self.scopes, scope)
+	self.scopeIndex++
+
+	self.symbolTable = NewEnclosedSymbolTable(self.symbolTable)
+}
+
+func (self *Compiler) leaveScope() code.Instructions {
+	instructions := self.currentInstructions()
+
+	self.scopes = self.scopes[:len(self.scopes)-1]
+	self.scopeIndex--
+
+	self.symbolTable = self.symbolTable.Outer
+
+	return instructions
+}
@@ -0,0 +1,161 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+// Symbol is a resolved name. Its Index is a slot whose meaning depends on the scope:
+// a global or local slot, a free variable of the current closure, or, for
+// builtins, the constant holding the builtin.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	declared       map[string]bool   // the names the lets of the function bind
+	ahead          map[string]Symbol // those a nested function refers to before their let
+
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:       make(map[string]Symbol),
+		FreeSymbols: []Symbol{},
+	}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	symbolTable := NewSymbolTable()
+	symbolTable.Outer = outer
+	return symbolTable
+}
+
+// Define binds name in this table. Redefining a name of the same table reuses
+// its slot, the way a second let rebinds a name in the evaluator's environment.
+func (self *SymbolTable) Define(name string) Symbol {
+	scope := LocalScope
+	if self.Outer == nil {
+		scope = GlobalScope
+	}
+
+	if existing, ok := self.store[name]; ok && existing.Scope == scope {
+		return existing
+	}
+
+	symbol, ok := self.ahead[name]
+	if ok {
+		delete(self.ahead, name)
+	} else {
+		symbol = Symbol{Name: name, Scope: scope, Index: self.numDefinitions}
+		self.numDefinitions++
+	}
+	self.store[name] = symbol
+
+	return symbol
+}
+
+// DefineParameter binds name to the slot its argument is passed in. When two
+// parameters have the same name, the last one binds it as in the evaluator.
+func (self *SymbolTable) DefineParameter(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: LocalScope, Index: self.numDefinitions}
+	self.store[name] = symbol
+	self.numDefinitions++
+
+	return symbol
+}
+
+func (self *SymbolTable) DefineBuiltin(constantIndex int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BuiltinScope, Index: constantIndex}
+	self.store[name] = symbol
+	return symbol
+}
+
+// DefineFunctionName lets a function literal bound by let refer to itself.
+func (self *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: FunctionScope, Index: 0}
+	self.store[name] = symbol
+	return symbol
+}
+
+func (self *SymbolTable) defineFree(original Symbol) Symbol {
+	self.FreeSymbols = append(self.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(self.FreeSymbols) - 1}
+	self.store[original.Name] = symbol
+
+	return symbol
+}
+
+// Resolve looks name up through the enclosing tables. Locals of an enclosing
+// function become free variables of this one.
+func (self *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := self.store[name]
+	if ok || self.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = self.Outer.resolveEnclosing(name)
+	if !ok {
+		return symbol, ok
+	}
+
+	if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
+	}
+
+	return self.defineFree(symbol), true
+}
+
+// resolveEnclosing resolves name for a function nested in this one, which
+// refers to a let of this one even when it follows it, as in the evaluator.
+// The slot of such a let is set aside until it is defined: this function
+// itself refers to the binding further out until then.
+func (self *SymbolTable) resolveEnclosing(name string) (Symbol, bool) {
+	if symbol, ok := self.store[name]; (!ok || symbol.Scope != LocalScope) && self.declared[name] {
+		symbol, ok := self.ahead[name]
+		if !ok {
+			symbol = Symbol{Name: name, Scope: LocalScope, Index: self.numDefinitions}
+			self.numDefinitions++
+
+			if self.ahead == nil {
+				self.ahead = make(map[string]Symbol)
+			}
+			self.ahead[name] = symbol
+		}
+		return symbol, true
+	}
+
+	return self.Resolve(name)
+}
+
+// names returns the names of the symbols of scope in this table, by index.
+func (self *SymbolTable) names(scope SymbolScope, count int) []string {
+	names := make([]string, count)
+	for name, symbol := range self.store {
+		if symbol.Scope == scope {
+			names[symbol.Index] = name
+		}
+	}
+	return names
+}
+
+func (self *SymbolTable) outermost() *SymbolTable {
+	if self.Outer == nil {
+		return self
+	}
+
+	return self.Outer.outermost()
+}
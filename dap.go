@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/dap"
+	"io"
+)
+
+// runDebugAdapter serves the Debug Adapter Protocol on in and out until the
+// editor disconnects. The exit status is 1 when the connection broke first.
+func runDebugAdapter(in io.Reader, out io.Writer, errOut io.Writer) int {
+	if err := dap.NewServer(in, out).Serve(); err != nil {
+		fmt.Fprintf(errOut, "dap: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
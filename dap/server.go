@@ -0,0 +1,427 @@
+// Package dap is a debug adapter for Monkey: it speaks the Debug Adapter
+// Protocol with an editor over a pair of streams, running a program in the
+// debugger of the debug package. The editor sets breakpoints, steps through
+// the program, inspects its call stack and variables, expanding arrays and
+// hashes, and evaluates expressions while it is paused.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/debug"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// THREAD_ID is the thread of the program, which has a single one.
+const THREAD_ID = 1
+
+// ErrNotPaused is the error of the requests about a program that is not
+// paused.
+var ErrNotPaused = errors.New("the program is not paused")
+
+type handler func(self *Server, arguments json.RawMessage) (any, error)
+
+// handlers answer the requests of the client by their command.
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"initialize":        (*Server).initialize,
+		"launch":            (*Server).launch,
+		"setBreakpoints":    (*Server).setBreakpoints,
+		"configurationDone": (*Server).configurationDone,
+		"threads":           (*Server).threads,
+		"continue":          (*Server).continueRunning,
+		"next":              (*Server).next,
+		"stepIn":            (*Server).stepIn,
+		"stepOut":           (*Server).stepOut,
+		"stackTrace":        (*Server).stackTrace,
+		"scopes":            (*Server).scopes,
+		"variables":         (*Server).variables,
+		"evaluate":          (*Server).evaluate,
+		"disconnect":        (*Server).disconnect,
+	}
+}
+
+// Server is a debug adapter talking with a client over a reader and a
+// writer. The program it launches runs in a goroutine of its own.
+type Server struct {
+	reader *bufio.Reader
+
+	// the program writes events too
+	writing sync.Mutex
+	out     io.Writer
+	seq     int
+
+	path     string
+	debugger *debug.Debugger
+	env      *object.Environment
+	noDebug  bool
+	started  bool
+
+	// resume hands what to do next to the program while it is paused,
+	// exited is closed once it is over
+	resume chan func(debugger *debug.Debugger)
+	exited chan struct{}
+
+	// stack is set by the program while it is paused, nil otherwise
+	mutex sync.Mutex
+	stack []*debug.Frame
+
+	// references are what the variables references handed to the client
+	// while the program is paused refer to, reference n at n-1: an
+	// environment, an array or a hash
+	references []any
+
+	// then runs once the response to the current request is written
+	then func()
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		reader: bufio.NewReader(in),
+		out:    out,
+		resume: make(chan func(debugger *debug.Debugger)),
+		exited: make(chan struct{}),
+	}
+}
+
+// Serve answers the requests of the client until it disconnects, or its
+// input ends. It returns nil when the client disconnected.
+func (self *Server) Serve() error {
+	for {
+		body, err := readMessage(self.reader)
+		if err != nil {
+			return err
+		}
+
+		var request message
+		if err := json.Unmarshal(body, &request); err != nil || request.Type != "request" {
+			// without a request to answer, there is nothing to tell
+			continue
+		}
+
+		var result any
+		handle, ok := handlers[request.Command]
+		if ok {
+			result, err = handle(self, request.Arguments)
+		} else {
+			err = fmt.Errorf("unknown command %s", request.Command)
+		}
+
+		if err := self.respond(request, result, err); err != nil {
+			return err
+		}
+
+		if self.then != nil {
+			then := self.then
+			self.then = nil
+			then()
+		}
+
+		if request.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+func (self *Server) respond(request message, body any, err error) error {
+	answer := response{Type: "response", RequestSeq: request.Seq, Success: err == nil, Command: request.Command, Body: body}
+	if err != nil {
+		answer.Message = err.Error()
+	}
+
+	self.writing.Lock()
+	defer self.writing.Unlock()
+
+	self.seq++
+	answer.Seq = self.seq
+	return writeMessage(self.out, answer)
+}
+
+func (self *Server) send(name string, body any) error {
+	self.writing.Lock()
+	defer self.writing.Unlock()
+
+	self.seq++
+	return writeMessage(self.out, event{Seq: self.seq, Type: "event", Event: name, Body: body})
+}
+
+// decode unmarshals the arguments of a request into arguments.
+func decode(raw json.RawMessage, arguments any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, arguments); err != nil {
+		return fmt.Errorf("bad arguments: %w", err)
+	}
+	return nil
+}
+
+func (self *Server) initialize(raw json.RawMessage) (any, error) {
+	return Capabilities{SupportsConfigurationDoneRequest: true, SupportsEvaluateForHovers: true}, nil
+}
+
+// launch loads the program, which starts once the client is done setting
+// breakpoints: it is asked to once the program is loaded.
+func (self *Server) launch(raw json.RawMessage) (any, error) {
+	var arguments LaunchArguments
+	if err := decode(raw, &arguments); err != nil {
+		return nil, err
+	}
+	if self.debugger != nil {
+		return nil, fmt.Errorf("a program was launched already")
+	}
+
+	source, err := os.ReadFile(arguments.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	monkeyParser := parser.New(lexer.New(string(source)))
+	program := monkeyParser.ParseProgram()
+	if len(monkeyParser.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: %s", arguments.Program, strings.Join(monkeyParser.Errors(), "\n"))
+	}
+
+	elements := make([]object.Object, len(arguments.Args))
+	for i, arg := range arguments.Args {
+		elements[i] = &object.String{Value: arg}
+	}
+
+	self.env = object.NewEnvironment()
+	self.env.Set("args", object.NewArray(elements))
+
+	self.path = arguments.Program
+	self.noDebug = arguments.NoDebug
+	self.debugger = debug.New(program, arguments.StopOnEntry && !arguments.NoDebug, self.paused)
+
+	self.then = func() { self.send("initialized", nil) }
+	return nil, nil
+}
+
+func (self *Server) setBreakpoints(raw json.RawMessage) (any, error) {
+	var arguments SetBreakpointsArguments
+	if err := decode(raw, &arguments); err != nil {
+		return nil, err
+	}
+	if self.debugger == nil {
+		return nil, fmt.Errorf("no program was launched")
+	}
+
+	// the breakpoints of the source replace those it had
+	ours := self.isProgram(arguments.Source.Path)
+	if ours {
+		for _, line := range self.debugger.Breakpoints() {
+			self.debugger.ClearBreakpoint(line)
+		}
+	}
+
+	breakpoints := []Breakpoint{}
+	for _, requested := range arguments.Breakpoints {
+		breakpoint := Breakpoint{Line: requested.Line, Source: arguments.Source}
+		switch {
+		case !ours:
+			breakpoint.Message = "not the program being debugged"
+		case self.noDebug:
+			breakpoint.Message = "the program runs without debugging"
+		case !self.debugger.SetBreakpoint(requested.Line):
+			breakpoint.Message = "no statement starts on this line"
+		default:
+			breakpoint.Verified = true
+		}
+		breakpoints = append(breakpoints, breakpoint)
+	}
+
+	return SetBreakpointsResponseBody{Breakpoints: breakpoints}, nil
+}
+
+// isProgram reports whether path is the program being debugged.
+func (self *Server) isProgram(path string) bool {
+	if path == self.path {
+		return true
+	}
+
+	left, leftErr := filepath.Abs(path)
+	right, rightErr := filepath.Abs(self.path)
+	return leftErr == nil && rightErr == nil && left == right
+}
+
+func (self *Server) configurationDone(raw json.RawMessage) (any, error) {
+	if self.debugger == nil {
+		return nil, fmt.Errorf("no program was launched")
+	}
+
+	if !self.started {
+		self.started = true
+		self.then = self.run
+	}
+	return nil, nil
+}
+
+// run runs the program in a goroutine, telling the client what it prints and
+// when it is over.
+func (self *Server) run() {
+	go func() {
+		evaluated := self.debugger.Run(self.env, output{self, "stdout"})
+
+		exitCode := 0
+		if err, ok := evaluated.(*object.Error); ok && evaluated != debug.ErrStopped {
+			self.send("output", OutputEventBody{Category: "stderr", Output: fmt.Sprintf("%s: %s\n", self.path, err.Message)})
+			exitCode = 1
+		}
+
+		self.send("exited", ExitedEventBody{ExitCode: exitCode})
+		self.send("terminated", nil)
+		close(self.exited)
+	}()
+}
+
+// output is what puts writes to while the program runs: it is sent to the
+// client.
+type output struct {
+	server   *Server
+	category string
+}
+
+func (self output) Write(p []byte) (int, error) {
+	if err := self.server.send("output", OutputEventBody{Category: self.category, Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// paused is called by the debugger, in the goroutine of the program, when
+// it pauses. The program waits for what to do next.
+func (self *Server) paused(debugger *debug.Debugger, reason debug.Reason) {
+	self.mutex.Lock()
+	self.stack = debugger.Stack()
+	self.mutex.Unlock()
+
+	self.send("stopped", StoppedEventBody{Reason: string(reason), ThreadID: THREAD_ID, AllThreadsStopped: true})
+
+	next := <-self.resume
+	next(debugger)
+}
+
+// pausedStack returns the call stack of the program, the innermost frame
+// first, when it is paused.
+func (self *Server) pausedStack() ([]*debug.Frame, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.stack == nil {
+		return nil, ErrNotPaused
+	}
+	return self.stack, nil
+}
+
+// resumeWith makes the paused program do next, once the current request is
+// answered.
+func (self *Server) resumeWith(next func(debugger *debug.Debugger)) (any, error) {
+	if _, err := self.pausedStack(); err != nil {
+		return nil, err
+	}
+
+	self.then = func() {
+		self.mutex.Lock()
+		self.stack = nil
+		self.mutex.Unlock()
+		self.references = nil
+
+		self.resume <- next
+	}
+	return nil, nil
+}
+
+func (self *Server) threads(raw json.RawMessage) (any, error) {
+	return ThreadsResponseBody{Threads: []Thread{{ID: THREAD_ID, Name: "main"}}}, nil
+}
+
+func (self *Server) continueRunning(raw json.RawMessage) (any, error) {
+	if _, err := self.resumeWith((*debug.Debugger).Continue); err != nil {
+		return nil, err
+	}
+	return ContinueResponseBody{AllThreadsContinued: true}, nil
+}
+
+func (self *Server) next(raw json.RawMessage) (any, error) {
+	return self.resumeWith((*debug.Debugger).StepOver)
+}
+
+func (self *Server) stepIn(raw json.RawMessage) (any, error) {
+	return self.resumeWith((*debug.Debugger).StepIn)
+}
+
+func (self *Server) stepOut(raw json.RawMessage) (any, error) {
+	return self.resumeWith((*debug.Debugger).StepOut)
+}
+
+// disconnect stops the program if it is paused, and waits for it to be
+// over. A program that is running goes on until it is over.
+func (self *Server) disconnect(raw json.RawMessage) (any, error) {
+	if _, err := self.pausedStack(); err == nil {
+		self.resumeWith((*debug.Debugger).Stop)
+
+		resume := self.then
+		self.then = func() {
+			resume()
+			<-self.exited
+		}
+	}
+	return nil, nil
+}
+
+func (self *Server) stackTrace(raw json.RawMessage) (any, error) {
+	var arguments StackTraceArguments
+	if err := decode(raw, &arguments); err != nil {
+		return nil, err
+	}
+
+	stack, err := self.pausedStack()
+	if err != nil {
+		return nil, err
+	}
+
+	source := Source{Name: filepath.Base(self.path), Path: self.path}
+	frames := []StackFrame{}
+	for i, frame := range stack {
+		if i < arguments.StartFrame || arguments.Levels > 0 && len(frames) == arguments.Levels {
+			continue
+		}
+
+		column := 1
+		if frame.Statement != nil {
+			column = frame.Statement.Pos().Column
+		}
+		// frame ids start at 1, 0 is no frame
+		frames = append(frames, StackFrame{ID: i + 1, Name: frame.Name(), Source: source, Line: frame.Line(), Column: column})
+	}
+
+	return StackTraceResponseBody{StackFrames: frames, TotalFrames: len(stack)}, nil
+}
+
+// frame returns the frame of id, the innermost one for 0.
+func (self *Server) frame(id int) (*debug.Frame, error) {
+	stack, err := self.pausedStack()
+	if err != nil {
+		return nil, err
+	}
+
+	if id == 0 {
+		return stack[0], nil
+	}
+	if id < 1 || id > len(stack) {
+		return nil, fmt.Errorf("no frame %d", id)
+	}
+	return stack[id-1], nil
+}
@@ -0,0 +1,468 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const PROGRAM = `let fact = fn(n) {
+  if (n < 2) {
+    return 1;
+  }
+  n * fact(n - 1)
+};
+let people = [{"name": "Alice", "age": 24}, {"name": "Bob", "age": 42}];
+puts("start");
+let result = fact(3);
+puts(result);
+`
+
+// client talks with a Server running in a goroutine, the way an editor
+// does.
+type client struct {
+	t        *testing.T
+	out      *io.PipeWriter
+	messages chan message
+	pending  []message // the events read while waiting for a response
+	seq      int
+	done     chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	self := &client{t: t, out: clientOut, messages: make(chan message, 100), done: make(chan error, 1)}
+
+	go func() {
+		self.done <- NewServer(serverIn, serverOut).Serve()
+	}()
+
+	go func() {
+		reader := bufio.NewReader(clientIn)
+		for {
+			body, err := readMessage(reader)
+			if err != nil {
+				close(self.messages)
+				return
+			}
+
+			var received message
+			if err := json.Unmarshal(body, &received); err != nil {
+				t.Errorf("the server sent %q: %s", body, err)
+			}
+			self.messages <- received
+		}
+	}()
+
+	t.Cleanup(func() {
+		clientOut.Close()
+		clientIn.Close()
+	})
+
+	return self
+}
+
+// launched returns a client debugging the program source, with breakpoints
+// at lines, past configurationDone.
+func launched(t *testing.T, source string, stopOnEntry bool, lines ...int) (*client, string) {
+	path := filepath.Join(t.TempDir(), "program.mk")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	self := newClient(t)
+	self.request("initialize", map[string]any{"adapterID": "monkey"}, nil)
+	self.request("launch", LaunchArguments{Program: path, Args: []string{"a"}, StopOnEntry: stopOnEntry}, nil)
+	self.event("initialized", nil)
+
+	if len(lines) > 0 {
+		self.request("setBreakpoints", setBreakpointsAt(path, lines...), nil)
+	}
+	self.request("configurationDone", nil, nil)
+
+	return self, path
+}
+
+func setBreakpointsAt(path string, lines ...int) SetBreakpointsArguments {
+	arguments := SetBreakpointsArguments{Source: Source{Path: path}, Breakpoints: []SourceBreakpoint{}}
+	for _, line := range lines {
+		arguments.Breakpoints = append(arguments.Breakpoints, SourceBreakpoint{Line: line})
+	}
+	return arguments
+}
+
+func (self *client) receive() message {
+	select {
+	case received, ok := <-self.messages:
+		if !ok {
+			self.t.Fatalf("the server closed the connection")
+		}
+		return received
+	case <-time.After(5 * time.Second):
+		self.t.Fatalf("the server did not answer")
+	}
+	return message{}
+}
+
+// send sends a request and returns its response.
+func (self *client) send(command string, arguments any) message {
+	self.t.Helper()
+
+	self.seq++
+	request := map[string]any{"seq": self.seq, "type": "request", "command": command}
+	if arguments != nil {
+		request["arguments"] = arguments
+	}
+	if err := writeMessage(self.out, request); err != nil {
+		self.t.Fatalf("writing to the server: %s", err)
+	}
+
+	for {
+		received := self.receive()
+		if received.Type == "event" {
+			self.pending = append(self.pending, received)
+			continue
+		}
+
+		if received.RequestSeq != self.seq || received.Command != command {
+			self.t.Fatalf("answer to %s %d while waiting for %s %d", received.Command, received.RequestSeq, command, self.seq)
+		}
+		return received
+	}
+}
+
+// request sends a request that must succeed, and unmarshals the body of its
+// response into body.
+func (self *client) request(command string, arguments any, body any) {
+	self.t.Helper()
+
+	received := self.send(command, arguments)
+	if !received.Success {
+		self.t.Fatalf("%s failed: %s", command, received.Message)
+	}
+	if body != nil {
+		if err := json.Unmarshal(received.Body, body); err != nil {
+			self.t.Fatalf("%s: unmarshaling %s: %s", command, received.Body, err)
+		}
+	}
+}
+
+// failure sends a request that must fail, and returns its message.
+func (self *client) failure(command string, arguments any) string {
+	self.t.Helper()
+
+	received := self.send(command, arguments)
+	if received.Success {
+		self.t.Fatalf("%s succeeded", command)
+	}
+	return received.Message
+}
+
+// event waits for the next event called name, unmarshaling its body into
+// body, and returns the events before it.
+func (self *client) event(name string, body any) []message {
+	self.t.Helper()
+
+	var before []message
+	for {
+		var received message
+		if len(self.pending) > 0 {
+			received, self.pending = self.pending[0], self.pending[1:]
+		} else {
+			received = self.receive()
+		}
+
+		if received.Event != name {
+			before = append(before, received)
+			continue
+		}
+
+		if body != nil {
+			if err := json.Unmarshal(received.Body, body); err != nil {
+				self.t.Fatalf("%s: unmarshaling %s: %s", name, received.Body, err)
+			}
+		}
+		return before
+	}
+}
+
+// stopped waits for the program to pause, and returns why and where: the
+// name and line of each frame.
+func (self *client) stopped() (string, []string) {
+	self.t.Helper()
+
+	var stopped StoppedEventBody
+	self.event("stopped", &stopped)
+
+	var trace StackTraceResponseBody
+	self.request("stackTrace", map[string]any{"threadId": THREAD_ID}, &trace)
+
+	var frames []string
+	for _, frame := range trace.StackFrames {
+		frames = append(frames, frame.Name+":"+strconv.Itoa(frame.Line))
+	}
+	return stopped.Reason, frames
+}
+
+// printedIn returns what the program printed in the events, by category.
+func printedIn(events []message) map[string]string {
+	printed := make(map[string]string)
+	for _, received := range events {
+		if received.Event == "output" {
+			var body OutputEventBody
+			json.Unmarshal(received.Body, &body)
+			printed[body.Category] += body.Output
+		}
+	}
+	return printed
+}
+
+func TestRunWithoutBreakpoints(t *testing.T) {
+	self, _ := launched(t, PROGRAM, false)
+
+	var exited ExitedEventBody
+	before := self.event("exited", &exited)
+	self.event("terminated", nil)
+
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code wrong. got=%d, want=0", exited.ExitCode)
+	}
+	if printed := printedIn(before); printed["stdout"] != "start\n6\n" {
+		t.Errorf("output wrong. got=%q", printed)
+	}
+
+	self.request("disconnect", nil, nil)
+	if err := <-self.done; err != nil {
+		t.Errorf("Serve returned %s", err)
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	self, path := launched(t, "puts(1);\n1 + true", false)
+
+	var exited ExitedEventBody
+	before := self.event("exited", &exited)
+
+	if exited.ExitCode != 1 {
+		t.Errorf("exit code wrong. got=%d, want=1", exited.ExitCode)
+	}
+	printed := printedIn(before)
+	if printed["stdout"] != "1\n" || printed["stderr"] != path+": type mismatch: INTEGER + BOOLEAN\n" {
+		t.Errorf("output wrong. got=%q", printed)
+	}
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	self, path := launched(t, PROGRAM, true)
+
+	reason, frames := self.stopped()
+	if reason != "entry" || !reflect.DeepEqual(frames, []string{"<program>:1"}) {
+		t.Errorf("entry wrong. got=%s %v", reason, frames)
+	}
+
+	var set SetBreakpointsResponseBody
+	self.request("setBreakpoints", setBreakpointsAt(path, 3, 6), &set)
+	if len(set.Breakpoints) != 2 || !set.Breakpoints[0].Verified || set.Breakpoints[1].Verified {
+		t.Errorf("breakpoints wrong. got=%+v", set.Breakpoints)
+	}
+
+	self.request("setBreakpoints", setBreakpointsAt("elsewhere.mk", 1), &set)
+	if set.Breakpoints[0].Verified {
+		t.Errorf("a breakpoint in another file was verified")
+	}
+
+	steps := []struct {
+		command        string
+		expectedReason string
+		expectedFrames []string
+	}{
+		{"continue", "breakpoint", []string{"fact:3", "fact:5", "fact:5", "<program>:9"}},
+		{"stepOut", "step", []string{"fact:5", "fact:5", "<program>:9"}},
+		{"next", "step", []string{"fact:5", "<program>:9"}},
+		{"next", "step", []string{"<program>:9"}},
+		{"next", "step", []string{"<program>:10"}},
+	}
+
+	for _, step := range steps {
+		self.request(step.command, map[string]any{"threadId": THREAD_ID}, nil)
+
+		reason, frames := self.stopped()
+		if reason != step.expectedReason || !reflect.DeepEqual(frames, step.expectedFrames) {
+			t.Errorf("%s: got=%s %v, want=%s %v", step.command, reason, frames, step.expectedReason, step.expectedFrames)
+		}
+	}
+
+	self.request("setBreakpoints", setBreakpointsAt(path), nil)
+	self.request("continue", map[string]any{"threadId": THREAD_ID}, nil)
+	before := self.event("terminated", nil)
+	if printed := printedIn(before); printed["stdout"] != "6\n" {
+		t.Errorf("output wrong. got=%q", printed)
+	}
+}
+
+func TestStepIn(t *testing.T) {
+	self, _ := launched(t, PROGRAM, false, 9)
+
+	self.stopped()
+	self.request("stepIn", map[string]any{"threadId": THREAD_ID}, nil)
+
+	reason, frames := self.stopped()
+	if reason != "step" || !reflect.DeepEqual(frames, []string{"fact:2", "<program>:9"}) {
+		t.Errorf("stepIn wrong. got=%s %v", reason, frames)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	self, _ := launched(t, PROGRAM, false, 3)
+	self.stopped()
+
+	var scopes ScopesResponseBody
+	self.request("scopes", map[string]any{"frameId": 2}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("scopes wrong. got=%+v", scopes.Scopes)
+	}
+
+	variables := func(reference int) []Variable {
+		var body VariablesResponseBody
+		self.request("variables", map[string]any{"variablesReference": reference}, &body)
+		return body.Variables
+	}
+	describe := func(variables []Variable) []string {
+		var described []string
+		for _, variable := range variables {
+			described = append(described, variable.Name+" "+variable.Type+" = "+variable.Value)
+		}
+		return described
+	}
+
+	if locals := describe(variables(scopes.Scopes[0].VariablesReference)); !reflect.DeepEqual(locals, []string{"n INTEGER = 2"}) {
+		t.Errorf("locals wrong. got=%q", locals)
+	}
+
+	globals := variables(scopes.Scopes[1].VariablesReference)
+	expected := []string{
+		`args ARRAY = ["a"]`,
+		`fact FUNCTION = fn(n) {...}`,
+		`people ARRAY = [{"age": 24, "name": "Alice"}, {"age": 42, "name": "Bob"}]`,
+	}
+	if !reflect.DeepEqual(describe(globals), expected) {
+		t.Errorf("globals wrong.\ngot=%q\nwant=%q", describe(globals), expected)
+	}
+
+	people := variables(globals[2].VariablesReference)
+	if described := describe(people); !reflect.DeepEqual(described, []string{`0 HASH = {"age": 24, "name": "Alice"}`, `1 HASH = {"age": 42, "name": "Bob"}`}) {
+		t.Errorf("array elements wrong. got=%q", described)
+	}
+
+	alice := variables(people[0].VariablesReference)
+	if described := describe(alice); !reflect.DeepEqual(described, []string{`"age" INTEGER = 24`, `"name" STRING = "Alice"`}) {
+		t.Errorf("hash pairs wrong. got=%q", described)
+	}
+	if alice[0].VariablesReference != 0 {
+		t.Errorf("an integer can be expanded")
+	}
+
+	if message := self.failure("variables", map[string]any{"variablesReference": 99}); message != "no variables reference 99" {
+		t.Errorf("message wrong. got=%q", message)
+	}
+
+	// references do not outlive the pause
+	self.request("continue", map[string]any{"threadId": THREAD_ID}, nil)
+	self.event("terminated", nil)
+	if message := self.failure("variables", map[string]any{"variablesReference": 1}); message != ErrNotPaused.Error() {
+		t.Errorf("message wrong. got=%q", message)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	self, _ := launched(t, PROGRAM, false, 3)
+	self.stopped()
+
+	tests := []struct {
+		expression       string
+		frameID          int
+		expectedResult   string
+		expectedExpanded bool
+		expectedError    string
+	}{
+		{"n", 0, "1", false, ""},
+		{"n", 3, "3", false, ""},
+		{"fact(n + 2)", 2, "24", false, ""},
+		{"people[1]", 0, `{"age": 42, "name": "Bob"}`, true, ""},
+		{"missing", 0, "", false, "identifier not found: missing"},
+		{"let x = 1", 0, "", false, "expected an expression, got a let statement"},
+		{"n", 9, "", false, "no frame 9"},
+	}
+
+	for _, tt := range tests {
+		arguments := EvaluateArguments{Expression: tt.expression, FrameID: tt.frameID, Context: "repl"}
+		if tt.expectedError != "" {
+			if message := self.failure("evaluate", arguments); message != tt.expectedError {
+				t.Errorf("%q: message wrong. got=%q, want=%q", tt.expression, message, tt.expectedError)
+			}
+			continue
+		}
+
+		var body EvaluateResponseBody
+		self.request("evaluate", arguments, &body)
+		if body.Result != tt.expectedResult || (body.VariablesReference != 0) != tt.expectedExpanded {
+			t.Errorf("%q: got=%+v, want=%q", tt.expression, body, tt.expectedResult)
+		}
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	self := newClient(t)
+	self.request("initialize", map[string]any{"adapterID": "monkey"}, nil)
+
+	if message := self.failure("setBreakpoints", setBreakpointsAt("program.mk", 1)); message != "no program was launched" {
+		t.Errorf("setBreakpoints before launch: %q", message)
+	}
+	if message := self.failure("launch", LaunchArguments{Program: filepath.Join(t.TempDir(), "missing.mk")}); !strings.Contains(message, "no such file") {
+		t.Errorf("launch of a missing file: %q", message)
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.mk")
+	os.WriteFile(path, []byte("let = 1"), 0o644)
+	if message := self.failure("launch", LaunchArguments{Program: path}); !strings.HasPrefix(message, path+": expected next token to be IDENT") {
+		t.Errorf("launch of a broken file: %q", message)
+	}
+
+	if message := self.failure("stackTrace", map[string]any{"threadId": THREAD_ID}); message != ErrNotPaused.Error() {
+		t.Errorf("stackTrace while not paused: %q", message)
+	}
+	if message := self.failure("restart", nil); message != "unknown command restart" {
+		t.Errorf("unknown command: %q", message)
+	}
+
+	var threads ThreadsResponseBody
+	self.request("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0].ID != THREAD_ID {
+		t.Errorf("threads wrong. got=%+v", threads)
+	}
+}
+
+func TestDisconnectWhilePaused(t *testing.T) {
+	self, _ := launched(t, PROGRAM, true)
+	self.stopped()
+
+	self.request("disconnect", map[string]any{}, nil)
+
+	var exited ExitedEventBody
+	before := self.event("exited", &exited)
+	if exited.ExitCode != 0 || printedIn(before)["stdout"] != "" {
+		t.Errorf("the program went on: exit code %d, %q", exited.ExitCode, printedIn(before))
+	}
+	if err := <-self.done; err != nil {
+		t.Errorf("Serve returned %s", err)
+	}
+}
@@ -0,0 +1,126 @@
+package dap
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/pretty"
+	"strconv"
+)
+
+// reference returns a variables reference to value, whose variables the
+// client may then ask for while the program is paused.
+func (self *Server) reference(value any) int {
+	self.references = append(self.references, value)
+	return len(self.references)
+}
+
+// variable is value as the client shows it, with a reference to its elements
+// when it is an array or a hash that has some.
+func (self *Server) variable(name string, value object.Object) Variable {
+	variable := Variable{Name: name, Value: pretty.Summary(value), Type: string(value.Type())}
+
+	switch value := value.(type) {
+	case *object.Array:
+		if value.Len() > 0 {
+			variable.VariablesReference = self.reference(value)
+			variable.IndexedVariables = value.Len()
+		}
+	case *object.Hash:
+		if value.Len() > 0 {
+			variable.VariablesReference = self.reference(value)
+			variable.NamedVariables = value.Len()
+		}
+	}
+
+	return variable
+}
+
+// scopes are the environment of a frame and those enclosing it, up to the
+// global one.
+func (self *Server) scopes(raw json.RawMessage) (any, error) {
+	var arguments ScopesArguments
+	if err := decode(raw, &arguments); err != nil {
+		return nil, err
+	}
+
+	frame, err := self.frame(arguments.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	for env := frame.Env; env != nil; env = env.Outer() {
+		scope := Scope{Name: "Closure", VariablesReference: self.reference(env)}
+		switch {
+		case env.Outer() == nil:
+			scope.Name = "Globals"
+		case env == frame.Env:
+			scope.Name = "Locals"
+			scope.PresentationHint = "locals"
+		}
+		scopes = append(scopes, scope)
+	}
+
+	return ScopesResponseBody{Scopes: scopes}, nil
+}
+
+func (self *Server) variables(raw json.RawMessage) (any, error) {
+	var arguments VariablesArguments
+	if err := decode(raw, &arguments); err != nil {
+		return nil, err
+	}
+
+	if _, err := self.pausedStack(); err != nil {
+		return nil, err
+	}
+	if arguments.VariablesReference < 1 || arguments.VariablesReference > len(self.references) {
+		return nil, fmt.Errorf("no variables reference %d", arguments.VariablesReference)
+	}
+
+	variables := []Variable{}
+	switch referred := self.references[arguments.VariablesReference-1].(type) {
+	case *object.Environment:
+		for _, binding := range referred.Bindings() {
+			variables = append(variables, self.variable(binding.Name, binding.Value))
+		}
+	case *object.Array:
+		for i := 0; i < referred.Len(); i++ {
+			variables = append(variables, self.variable(strconv.Itoa(i), referred.Get(i)))
+		}
+	case *object.Hash:
+		for _, pair := range pretty.SortedPairs(referred) {
+			variables = append(variables, self.variable(pretty.Summary(pair.Key), pair.Value))
+		}
+	}
+
+	return VariablesResponseBody{Variables: variables}, nil
+}
+
+// evaluate evaluates an expression in the environment of a frame, the
+// innermost one by default.
+func (self *Server) evaluate(raw json.RawMessage) (any, error) {
+	var arguments EvaluateArguments
+	if err := decode(raw, &arguments); err != nil {
+		return nil, err
+	}
+
+	frame, err := self.frame(arguments.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluated, err := self.debugger.Evaluate(arguments.Expression, frame)
+	if err != nil {
+		return nil, err
+	}
+	if evaluated == nil {
+		evaluated = object.NULL
+	}
+	if err, ok := evaluated.(*object.Error); ok {
+		return nil, fmt.Errorf("%s", err.Message)
+	}
+
+	variable := self.variable("", evaluated)
+	return EvaluateResponseBody{Result: variable.Value, Type: variable.Type, VariablesReference: variable.VariablesReference}, nil
+}
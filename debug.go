@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/debug"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"io"
+	"os"
+)
+
+// runDebug runs the script at args[0] with the rest of args, pausing before
+// its first statement for the commands read from in. The exit status is that
+// of running the script, 0 when it was stopped from the debugger.
+func runDebug(args []string, in io.Reader, out io.Writer, errOut io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(errOut, "debug: no script given")
+		return 2
+	}
+
+	path := args[0]
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s: %s\n", path, err)
+		return 2
+	}
+
+	program, ok := parse(path, string(source), errOut)
+	if !ok {
+		return 2
+	}
+
+	env := scriptEnvironment(args[1:])
+
+	terminal := debug.NewTerminal(path, string(source), in, out)
+	debugger := debug.New(program, true, terminal.Paused)
+
+	evaluated := debugger.Run(env, out)
+
+	if evaluated == debug.ErrStopped {
+		return 0
+	}
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintf(errOut, "%s: %s\n", path, err.Message)
+		return 1
+	}
+
+	return 0
+}
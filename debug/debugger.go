@@ -0,0 +1,284 @@
+// Package debug pauses Monkey programs run by the evaluator: at breakpoints
+// set on lines, and statement by statement, stepping into, over or out of
+// function calls. While a program is paused, its call stack and environments
+// can be inspected and expressions evaluated in them.
+//
+// A Debugger does the bookkeeping; what happens while the program is paused
+// is up to the function it calls then, such as the command line of Terminal.
+package debug
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Reason tells why the program paused.
+type Reason string
+
+const (
+	ENTRY      Reason = "entry"      // before its first statement
+	BREAKPOINT Reason = "breakpoint" // at a line with a breakpoint
+	STEP       Reason = "step"       // once a step is over
+)
+
+// mode is what the program is running until.
+type mode int
+
+const (
+	CONTINUE  mode = iota // the next breakpoint
+	STEP_IN               // the next statement
+	STEP_OVER             // the next statement of the same call, or of a caller
+	STEP_OUT              // the next statement of a caller
+	STOP                  // stopping as soon as possible
+)
+
+// ErrStopped is what the program evaluates to when the debugger stopped it.
+var ErrStopped = &object.Error{Message: "stopped by the debugger"}
+
+// Frame is the program, or a call of a function, being evaluated.
+type Frame struct {
+	Function  *object.Function // nil for the program
+	Env       *object.Environment
+	Statement ast.Statement // the statement being evaluated
+
+	line int // the line of the statement, 0 before the first one
+}
+
+// Name is the name of the function of the frame, after its let.
+func (self *Frame) Name() string {
+	switch {
+	case self.Function == nil:
+		return "<program>"
+	case self.Function.Name == "":
+		return "<anonymous>"
+	default:
+		return self.Function.Name
+	}
+}
+
+// Line is the line of the statement being evaluated, 0 before the first one.
+func (self *Frame) Line() int {
+	return self.line
+}
+
+// Debugger is the evaluator.Debugger of a program, which calls paused each
+// time the program pauses. The program resumes when paused returns, unless it
+// called Stop, running until what the last of Continue, StepIn, StepOver or
+// StepOut called asks for, the next breakpoint when none was.
+type Debugger struct {
+	program *ast.Program
+	paused  func(self *Debugger, reason Reason)
+
+	// breakpoints may be set from another goroutine while the program runs
+	breakpointsMutex sync.Mutex
+	breakpoints      map[int]bool
+
+	lines  map[int]bool // the lines statements start on
+	frames []*Frame     // the outermost first
+	mode   mode
+	depth  int       // the number of frames when stepping started
+	entry  bool      // pausing before the first statement
+	output io.Writer // where puts prints, in the program and in the expressions evaluated
+}
+
+// New creates a debugger for program. It pauses before the first statement
+// when stopOnEntry is set.
+func New(program *ast.Program, stopOnEntry bool, paused func(self *Debugger, reason Reason)) *Debugger {
+	self := &Debugger{
+		program:     program,
+		paused:      paused,
+		breakpoints: make(map[int]bool),
+		lines:       make(map[int]bool),
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if statement, ok := node.(ast.Statement); ok {
+			self.lines[statement.Pos().Line] = true
+		}
+		return true
+	})
+
+	self.entry = stopOnEntry
+	return self
+}
+
+// Run evaluates the program in env, telling the debugger about it. puts
+// prints to out.
+func (self *Debugger) Run(env *object.Environment, out io.Writer) object.Object {
+	self.frames = []*Frame{{Env: env}}
+	self.output = out
+	defer func() { self.frames = nil }()
+
+	evaluator.Resolve(self.program, env)
+	return evaluator.EvalWithOptions(self.program, env, evaluator.Options{Output: out, Debugger: self})
+}
+
+// SetBreakpoint makes the program pause at line, and reports whether a
+// statement starts on it. Without one, the program never pauses there.
+func (self *Debugger) SetBreakpoint(line int) bool {
+	self.breakpointsMutex.Lock()
+	defer self.breakpointsMutex.Unlock()
+
+	self.breakpoints[line] = true
+	return self.lines[line]
+}
+
+func (self *Debugger) ClearBreakpoint(line int) {
+	self.breakpointsMutex.Lock()
+	defer self.breakpointsMutex.Unlock()
+
+	delete(self.breakpoints, line)
+}
+
+// Breakpoints returns the lines with a breakpoint, in order.
+func (self *Debugger) Breakpoints() []int {
+	self.breakpointsMutex.Lock()
+	defer self.breakpointsMutex.Unlock()
+
+	lines := make([]int, 0, len(self.breakpoints))
+	for line := range self.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Continue makes the program run until the next breakpoint.
+func (self *Debugger) Continue() {
+	self.mode = CONTINUE
+}
+
+// StepIn makes the program pause at the next statement, in a function it
+// calls if it calls one.
+func (self *Debugger) StepIn() {
+	self.start(STEP_IN)
+}
+
+// StepOver makes the program pause at the next statement, once the
+// functions called by the current one returned.
+func (self *Debugger) StepOver() {
+	self.start(STEP_OVER)
+}
+
+// StepOut makes the program pause once the current function returned.
+func (self *Debugger) StepOut() {
+	self.start(STEP_OUT)
+}
+
+// Stop makes the program evaluate to ErrStopped instead of going on.
+func (self *Debugger) Stop() {
+	self.mode = STOP
+}
+
+func (self *Debugger) start(stepping mode) {
+	self.mode = stepping
+	self.depth = len(self.frames)
+}
+
+// Stack returns the frames being evaluated, the innermost first.
+func (self *Debugger) Stack() []*Frame {
+	stack := make([]*Frame, len(self.frames))
+	for i, frame := range self.frames {
+		stack[len(stack)-1-i] = frame
+	}
+	return stack
+}
+
+// Evaluate evaluates the expression source in the environment of frame,
+// without pausing at breakpoints.
+func (self *Debugger) Evaluate(source string, frame *Frame) (object.Object, error) {
+	monkeyParser := parser.New(lexer.New(source))
+	program := monkeyParser.ParseProgram()
+	if len(monkeyParser.Errors()) != 0 {
+		return nil, fmt.Errorf("%s", strings.Join(monkeyParser.Errors(), "\n"))
+	}
+
+	if len(program.Statements) != 1 {
+		return nil, fmt.Errorf("expected a single expression, got %d statements", len(program.Statements))
+	}
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil, fmt.Errorf("expected an expression, got a %s statement", program.Statements[0].TokenLiteral())
+	}
+
+	// left unresolved, the identifiers of the expression are looked up by
+	// name in the environments of the frame
+	return evaluator.EvalWithOptions(statement.Expression, frame.Env, evaluator.Options{Output: self.output}), nil
+}
+
+func (self *Debugger) Statement(statement ast.Statement, env *object.Environment) *object.Error {
+	if self.mode == STOP {
+		return ErrStopped
+	}
+
+	frame := self.frames[len(self.frames)-1]
+	frame.Statement = statement
+	line := statement.Pos().Line
+
+	// a breakpoint pauses the program once when it gets to its line, not at
+	// each statement on it
+	reason := Reason("")
+	switch {
+	case self.entry:
+		self.entry = false
+		reason = ENTRY
+	case self.mode == STEP_IN,
+		self.mode == STEP_OVER && len(self.frames) <= self.depth,
+		self.mode == STEP_OUT && len(self.frames) < self.depth:
+		reason = STEP
+	case self.hasBreakpoint(line) && line != frame.line:
+		reason = BREAKPOINT
+	}
+	frame.line = line
+
+	if reason == "" {
+		return nil
+	}
+
+	self.mode = CONTINUE
+	self.paused(self, reason)
+
+	if self.mode == STOP {
+		return ErrStopped
+	}
+	return nil
+}
+
+func (self *Debugger) hasBreakpoint(line int) bool {
+	self.breakpointsMutex.Lock()
+	defer self.breakpointsMutex.Unlock()
+
+	return self.breakpoints[line]
+}
+
+func (self *Debugger) Call(fn *object.Function, env *object.Environment) {
+	self.frames = append(self.frames, &Frame{Function: fn, Env: env})
+}
+
+// Return pauses a step that ends with the call, in the caller: its
+// statement is not over yet, it may not have another one to pause at.
+func (self *Debugger) Return(fn *object.Function, result object.Object) *object.Error {
+	self.frames = self.frames[:len(self.frames)-1]
+
+	if self.stepping() && len(self.frames) < self.depth {
+		self.mode = CONTINUE
+		self.paused(self, STEP)
+	}
+
+	if self.mode == STOP {
+		return ErrStopped
+	}
+	return nil
+}
+
+func (self *Debugger) stepping() bool {
+	return self.mode == STEP_IN || self.mode == STEP_OVER || self.mode == STEP_OUT
+}
@@ -0,0 +1,215 @@
+package debug
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"io"
+	"reflect"
+	"testing"
+)
+
+const FACTORIAL = `let fact = fn(n) {
+  if (n < 2) {
+    return 1;
+  }
+  n * fact(n - 1)
+};
+let double = fn(x) { x * 2 };
+let doubled = map([1, 2], double);
+fact(3)`
+
+func parse(t *testing.T, source string) *ast.Program {
+	t.Helper()
+
+	monkeyParser := parser.New(lexer.New(source))
+	program := monkeyParser.ParseProgram()
+	if len(monkeyParser.Errors()) != 0 {
+		t.Fatalf("parse errors: %v", monkeyParser.Errors())
+	}
+	return program
+}
+
+// script is what a test does each time the program pauses, after
+// recording where.
+type script struct {
+	actions []func(debugger *Debugger)
+	pauses  []string
+}
+
+func (self *script) paused(debugger *Debugger, reason Reason) {
+	var depths []string
+	for _, frame := range debugger.Stack() {
+		depths = append(depths, fmt.Sprintf("%s:%d", frame.Name(), frame.Line()))
+	}
+	self.pauses = append(self.pauses, fmt.Sprintf("%s %v", reason, depths))
+
+	if len(self.actions) == 0 {
+		debugger.Stop()
+		return
+	}
+	self.actions[0](debugger)
+	self.actions = self.actions[1:]
+}
+
+func breakAt(lines ...int) func(debugger *Debugger) {
+	return func(debugger *Debugger) {
+		for _, line := range lines {
+			debugger.SetBreakpoint(line)
+		}
+	}
+}
+
+func TestStepping(t *testing.T) {
+	stepIn := (*Debugger).StepIn
+	stepOver := (*Debugger).StepOver
+	stepOut := (*Debugger).StepOut
+	resume := (*Debugger).Continue
+
+	tests := []struct {
+		name     string
+		actions  []func(debugger *Debugger)
+		expected []string
+	}{
+		{
+			"step over the program",
+			[]func(debugger *Debugger){stepOver, stepOver, stepOver, stepOver},
+			[]string{
+				"entry [<program>:1]",
+				"step [<program>:7]",
+				"step [<program>:8]",
+				"step [<program>:9]",
+			},
+		},
+		{
+			"step into the callbacks of builtins",
+			[]func(debugger *Debugger){stepOver, stepOver, stepIn, stepIn, stepIn, stepOut, resume},
+			[]string{
+				"entry [<program>:1]",
+				"step [<program>:7]",
+				"step [<program>:8]",
+				"step [double:7 <program>:8]",
+				"step [<program>:8]",
+				"step [double:7 <program>:8]",
+				"step [<program>:8]",
+			},
+		},
+		{
+			"breakpoints pause each call once",
+			[]func(debugger *Debugger){breakAt(2), resume, resume, resume},
+			[]string{
+				"entry [<program>:1]",
+				"breakpoint [fact:2 <program>:9]",
+				"breakpoint [fact:2 fact:5 <program>:9]",
+				"breakpoint [fact:2 fact:5 fact:5 <program>:9]",
+			},
+		},
+		{
+			"step out to the caller",
+			[]func(debugger *Debugger){breakAt(3), stepOut, stepOut, stepOut},
+			[]string{
+				"entry [<program>:1]",
+				"breakpoint [fact:3 fact:5 fact:5 <program>:9]",
+				"step [fact:5 fact:5 <program>:9]",
+				"step [fact:5 <program>:9]",
+				"step [<program>:9]",
+			},
+		},
+		{
+			"step over a return",
+			[]func(debugger *Debugger){breakAt(3), stepOver, stepOver},
+			[]string{
+				"entry [<program>:1]",
+				"breakpoint [fact:3 fact:5 fact:5 <program>:9]",
+				"step [fact:5 fact:5 <program>:9]",
+				"step [fact:5 <program>:9]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		recorded := &script{actions: tt.actions}
+		debugger := New(parse(t, FACTORIAL), true, recorded.paused)
+
+		evaluated := debugger.Run(object.NewEnvironment(), io.Discard)
+
+		if !reflect.DeepEqual(recorded.pauses, tt.expected) {
+			t.Errorf("%s: pauses wrong.\ngot=%q\nwant=%q", tt.name, recorded.pauses, tt.expected)
+		}
+		// the program is stopped when it pauses once more than there are
+		// actions
+		if stopped := len(recorded.pauses) > len(tt.actions); stopped != (evaluated == ErrStopped) {
+			t.Errorf("%s: stopped=%t, got=%v", tt.name, stopped, evaluated)
+		}
+	}
+}
+
+func TestRunWithoutPausing(t *testing.T) {
+	debugger := New(parse(t, FACTORIAL), false, func(debugger *Debugger, reason Reason) {
+		t.Errorf("the program paused at %s", reason)
+	})
+
+	evaluated := debugger.Run(object.NewEnvironment(), io.Discard)
+	if integer, ok := evaluated.(*object.Integer); !ok || integer.Value != 6 {
+		t.Errorf("the program evaluated to %v, want=6", evaluated)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	debugger := New(parse(t, FACTORIAL), false, nil)
+
+	if !debugger.SetBreakpoint(5) {
+		t.Errorf("no statement found on line 5")
+	}
+	if debugger.SetBreakpoint(6) {
+		t.Errorf("a statement was found on line 6")
+	}
+	debugger.SetBreakpoint(1)
+	debugger.ClearBreakpoint(6)
+
+	if lines := debugger.Breakpoints(); !reflect.DeepEqual(lines, []int{1, 5}) {
+		t.Errorf("breakpoints wrong. got=%v, want=[1 5]", lines)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		source   string
+		frame    int
+		expected string
+	}{
+		{"n", 0, "1"},
+		{"n", 1, "2"},
+		{"n * 10 + len(doubled)", 2, "32"},
+		{"fact(4)", 0, "24"},
+		{"missing", 0, "ERROR: identifier not found: missing"},
+		{"let x = 1", 0, "expected an expression, got a let statement"},
+		{"1; 2", 0, "expected a single expression, got 2 statements"},
+		{"(", 0, "no prefix parse function found for EOF found\nexpected next token to be ), got EOF instead"},
+	}
+
+	var results []string
+	debugger := New(parse(t, FACTORIAL), false, func(debugger *Debugger, reason Reason) {
+		for _, tt := range tests {
+			evaluated, err := debugger.Evaluate(tt.source, debugger.Stack()[tt.frame])
+			if err != nil {
+				results = append(results, err.Error())
+			} else {
+				results = append(results, evaluated.Inspect())
+			}
+		}
+	})
+	debugger.SetBreakpoint(3)
+	debugger.Run(object.NewEnvironment(), io.Discard)
+
+	if len(results) != len(tests) {
+		t.Fatalf("the program paused %d times, want=1", len(results)/len(tests))
+	}
+	for i, tt := range tests {
+		if results[i] != tt.expected {
+			t.Errorf("%q in frame %d: got=%q, want=%q", tt.source, tt.frame, results[i], tt.expected)
+		}
+	}
+}
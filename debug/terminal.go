@@ -0,0 +1,288 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/pretty"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const PROMPT = "(debug) "
+
+// LIST_CONTEXT is the number of lines list shows around the current one.
+const LIST_CONTEXT = 5
+
+type command struct {
+	name     string
+	short    string
+	argument string // what the command expects after its name, if anything
+	help     string
+	// run runs the command, and reports whether the program resumes
+	run func(self *Terminal, debugger *Debugger, argument string) bool
+}
+
+// commands is filled in by init, help listing them refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"help", "h", "", "list the commands", (*Terminal).help},
+		{"break", "b", "[line]", "pause at line, or list the breakpoints", (*Terminal).setBreakpoint},
+		{"clear", "", "line", "remove the breakpoint at line", (*Terminal).clearBreakpoint},
+		{"continue", "c", "", "run until the next breakpoint", (*Terminal).continueRunning},
+		{"step", "s", "", "run until the next statement, into the functions called", (*Terminal).stepIn},
+		{"next", "n", "", "run until the next statement, over the functions called", (*Terminal).stepOver},
+		{"out", "o", "", "run until the current function returns", (*Terminal).stepOut},
+		{"print", "p", "expr", "print the value of expr in the selected frame", (*Terminal).print},
+		{"env", "e", "", "list the bindings of the environments of the selected frame", (*Terminal).listBindings},
+		{"stack", "bt", "", "print the call stack", (*Terminal).printStack},
+		{"frame", "f", "n", "select frame n of the call stack", (*Terminal).selectFrame},
+		{"list", "l", "", "print the source around the statement of the selected frame", (*Terminal).list},
+		{"quit", "q", "", "stop the program", (*Terminal).quit},
+	}
+}
+
+// Terminal is a command line debugger, reading commands from a reader
+// while the program is paused and writing to a writer.
+type Terminal struct {
+	name    string   // the file of the program, in positions
+	lines   []string // of the source of the program
+	scanner *bufio.Scanner
+	out     io.Writer
+
+	selected int    // the frame print and env look into, 0 for the innermost
+	last     string // the command an empty line repeats
+}
+
+// NewTerminal creates a debugger reading commands from in and writing to
+// out, for the program source read from the file name.
+func NewTerminal(name string, source string, in io.Reader, out io.Writer) *Terminal {
+	return &Terminal{
+		name:    name,
+		lines:   strings.Split(source, "\n"),
+		scanner: bufio.NewScanner(in),
+		out:     out,
+	}
+}
+
+// Paused is the function a Debugger calls when the program pauses: it tells
+// where the program is, then runs the commands read until one resumes it.
+// At the end of input, the program is stopped.
+func (self *Terminal) Paused(debugger *Debugger, reason Reason) {
+	self.selected = 0
+	self.printLocation(debugger.Stack()[0], reason)
+
+	for {
+		fmt.Fprint(self.out, PROMPT)
+		if !self.scanner.Scan() {
+			fmt.Fprintln(self.out)
+			debugger.Stop()
+			return
+		}
+
+		input := strings.TrimSpace(self.scanner.Text())
+		if input == "" {
+			input = self.last
+		}
+		if input == "" {
+			continue
+		}
+		self.last = input
+
+		if self.command(debugger, input) {
+			return
+		}
+	}
+}
+
+// command runs the command input starts with, and reports whether the
+// program resumes.
+func (self *Terminal) command(debugger *Debugger, input string) bool {
+	name, argument := input, ""
+	if i := strings.IndexFunc(input, unicode.IsSpace); i >= 0 {
+		name, argument = input[:i], strings.TrimSpace(input[i:])
+	}
+
+	for _, command := range commands {
+		if command.name != name && command.short != name {
+			continue
+		}
+
+		if command.argument != "" && !strings.HasPrefix(command.argument, "[") && argument == "" {
+			fmt.Fprintf(self.out, "usage: %s %s\n", command.name, command.argument)
+			return false
+		}
+
+		return command.run(self, debugger, argument)
+	}
+
+	fmt.Fprintf(self.out, "unknown command %s, help lists the commands\n", name)
+	return false
+}
+
+func (self *Terminal) help(debugger *Debugger, argument string) bool {
+	for _, command := range commands {
+		name := command.name
+		if command.short != "" {
+			name += ", " + command.short
+		}
+		fmt.Fprintf(self.out, "%-16s %s\n", strings.TrimSpace(name+" "+command.argument), command.help)
+	}
+	return false
+}
+
+func (self *Terminal) setBreakpoint(debugger *Debugger, argument string) bool {
+	if argument == "" {
+		for _, line := range debugger.Breakpoints() {
+			fmt.Fprintf(self.out, "%s:%d\n", self.name, line)
+		}
+		return false
+	}
+
+	line, ok := self.line(argument)
+	if !ok {
+		return false
+	}
+
+	if !debugger.SetBreakpoint(line) {
+		fmt.Fprintf(self.out, "no statement starts on line %d, the program will not pause there\n", line)
+		return false
+	}
+	fmt.Fprintf(self.out, "breakpoint at %s:%d\n", self.name, line)
+	return false
+}
+
+func (self *Terminal) clearBreakpoint(debugger *Debugger, argument string) bool {
+	if line, ok := self.line(argument); ok {
+		debugger.ClearBreakpoint(line)
+	}
+	return false
+}
+
+// line parses the line number argument, telling when it is not one.
+func (self *Terminal) line(argument string) (int, bool) {
+	line, err := strconv.Atoi(argument)
+	if err != nil || line < 1 {
+		fmt.Fprintf(self.out, "%q is not a line number\n", argument)
+		return 0, false
+	}
+	return line, true
+}
+
+func (self *Terminal) continueRunning(debugger *Debugger, argument string) bool {
+	debugger.Continue()
+	return true
+}
+
+func (self *Terminal) stepIn(debugger *Debugger, argument string) bool {
+	debugger.StepIn()
+	return true
+}
+
+func (self *Terminal) stepOver(debugger *Debugger, argument string) bool {
+	debugger.StepOver()
+	return true
+}
+
+func (self *Terminal) stepOut(debugger *Debugger, argument string) bool {
+	debugger.StepOut()
+	return true
+}
+
+func (self *Terminal) quit(debugger *Debugger, argument string) bool {
+	debugger.Stop()
+	return true
+}
+
+func (self *Terminal) print(debugger *Debugger, argument string) bool {
+	evaluated, err := debugger.Evaluate(argument, debugger.Stack()[self.selected])
+	if err != nil {
+		fmt.Fprintln(self.out, err)
+		return false
+	}
+
+	if evaluated != nil {
+		fmt.Fprintln(self.out, pretty.Sprint(evaluated, pretty.Options{}))
+	}
+	return false
+}
+
+// listBindings lists the bindings of the environment of the selected frame,
+// then of those enclosing it up to the global one.
+func (self *Terminal) listBindings(debugger *Debugger, argument string) bool {
+	for env := debugger.Stack()[self.selected].Env; env != nil; env = env.Outer() {
+		switch {
+		case env.Outer() == nil:
+			fmt.Fprintln(self.out, "globals:")
+		case env == debugger.Stack()[self.selected].Env:
+			fmt.Fprintln(self.out, "locals:")
+		default:
+			fmt.Fprintln(self.out, "closure:")
+		}
+
+		for _, binding := range env.Bindings() {
+			fmt.Fprintf(self.out, "  %s = %s\n", binding.Name, pretty.Summary(binding.Value))
+		}
+	}
+	return false
+}
+
+func (self *Terminal) printStack(debugger *Debugger, argument string) bool {
+	for i, frame := range debugger.Stack() {
+		marker := " "
+		if i == self.selected {
+			marker = ">"
+		}
+		fmt.Fprintf(self.out, "%s #%d %s\n", marker, i, self.location(frame))
+	}
+	return false
+}
+
+func (self *Terminal) selectFrame(debugger *Debugger, argument string) bool {
+	stack := debugger.Stack()
+
+	i, err := strconv.Atoi(argument)
+	if err != nil || i < 0 || i >= len(stack) {
+		fmt.Fprintf(self.out, "no frame %s, the frames go from 0 to %d\n", argument, len(stack)-1)
+		return false
+	}
+
+	self.selected = i
+	self.printLocation(stack[i], "")
+	return false
+}
+
+func (self *Terminal) list(debugger *Debugger, argument string) bool {
+	current := debugger.Stack()[self.selected].Line()
+
+	first, last := max(1, current-LIST_CONTEXT), min(len(self.lines), current+LIST_CONTEXT)
+	for line := first; line <= last; line++ {
+		marker := " "
+		if line == current {
+			marker = ">"
+		}
+		fmt.Fprintf(self.out, "%s %4d  %s\n", marker, line, self.lines[line-1])
+	}
+	return false
+}
+
+// printLocation tells where frame is, and why it paused if it did, followed
+// by the line of its statement.
+func (self *Terminal) printLocation(frame *Frame, reason Reason) {
+	if reason != "" {
+		fmt.Fprintf(self.out, "%s (%s)\n", self.location(frame), reason)
+	} else {
+		fmt.Fprintln(self.out, self.location(frame))
+	}
+
+	if line := frame.Line(); line >= 1 && line <= len(self.lines) {
+		fmt.Fprintf(self.out, "%4d  %s\n", line, self.lines[line-1])
+	}
+}
+
+func (self *Terminal) location(frame *Frame) string {
+	return fmt.Sprintf("%s:%d in %s", self.name, frame.Line(), frame.Name())
+}
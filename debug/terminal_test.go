@@ -0,0 +1,115 @@
+package debug
+
+import (
+	"bytes"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"strings"
+	"testing"
+)
+
+func TestTerminal(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		expected string
+	}{
+		{
+			"breakpoints",
+			[]string{"b 3", "b 6", "b x", "b", "clear 6", "b", "c"},
+			`fact.mk:1 in <program> (entry)
+   1  let fact = fn(n) {
+(debug) breakpoint at fact.mk:3
+(debug) no statement starts on line 6, the program will not pause there
+(debug) "x" is not a line number
+(debug) fact.mk:3
+fact.mk:6
+(debug) (debug) fact.mk:3
+(debug) fact.mk:3 in fact (breakpoint)
+   3      return 1;
+(debug) ` + "\n",
+		},
+		{
+			"stack, frames and environments",
+			[]string{"b 3", "c", "bt", "env", "p n * 10", "f 2", "p n", "bt", "f 9", "p (", "q"},
+			`fact.mk:1 in <program> (entry)
+   1  let fact = fn(n) {
+(debug) breakpoint at fact.mk:3
+(debug) fact.mk:3 in fact (breakpoint)
+   3      return 1;
+(debug) > #0 fact.mk:3 in fact
+  #1 fact.mk:5 in fact
+  #2 fact.mk:5 in fact
+  #3 fact.mk:9 in <program>
+(debug) locals:
+  n = 1
+globals:
+  args = ["a"]
+  fact = fn(n) {...}
+  double = fn(x) {...}
+  doubled = [2, 4]
+(debug) 10
+(debug) fact.mk:5 in fact
+   5    n * fact(n - 1)
+(debug) 3
+(debug)   #0 fact.mk:3 in fact
+  #1 fact.mk:5 in fact
+> #2 fact.mk:5 in fact
+  #3 fact.mk:9 in <program>
+(debug) no frame 9, the frames go from 0 to 3
+(debug) no prefix parse function found for EOF found
+expected next token to be ), got EOF instead
+(debug) `,
+		},
+		{
+			"stepping, an empty line repeating the last command",
+			[]string{"n", "", "s", "list", "o", "huh", "print", "q"},
+			`fact.mk:1 in <program> (entry)
+   1  let fact = fn(n) {
+(debug) fact.mk:7 in <program> (step)
+   7  let double = fn(x) { x * 2 };
+(debug) fact.mk:8 in <program> (step)
+   8  let doubled = map([1, 2], double);
+(debug) fact.mk:7 in double (step)
+   7  let double = fn(x) { x * 2 };
+(debug)      2    if (n < 2) {
+     3      return 1;
+     4    }
+     5    n * fact(n - 1)
+     6  };
+>    7  let double = fn(x) { x * 2 };
+     8  let doubled = map([1, 2], double);
+     9  fact(3)
+(debug) fact.mk:8 in <program> (step)
+   8  let doubled = map([1, 2], double);
+(debug) unknown command huh, help lists the commands
+(debug) usage: print expr
+(debug) `,
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		terminal := NewTerminal("fact.mk", FACTORIAL, strings.NewReader(strings.Join(tt.commands, "\n")+"\n"), &out)
+		debugger := New(parse(t, FACTORIAL), true, terminal.Paused)
+
+		env := object.NewEnvironment()
+		env.Set("args", object.NewArray([]object.Object{&object.String{Value: "a"}}))
+		debugger.Run(env, &out)
+
+		if out.String() != tt.expected {
+			t.Errorf("%s: transcript wrong.\ngot:\n%s\nwant:\n%s", tt.name, out.String(), tt.expected)
+		}
+	}
+}
+
+func TestTerminalHelp(t *testing.T) {
+	var out bytes.Buffer
+	terminal := NewTerminal("help.mk", "1", strings.NewReader("help\n"), &out)
+	New(parse(t, "1"), true, terminal.Paused).Run(object.NewEnvironment(), &out)
+
+	for _, command := range commands {
+		if !strings.Contains(out.String(), command.name) {
+			t.Errorf("help does not list %s", command.name)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/Neal-C/interpreter-in-go/dot"
+	"io"
+)
+
+// runDot prints the syntax tree of each file at paths, standard input when
+// there are none, as a Graphviz graph. Errors go to errOut. The exit status
+// is 2 when a file does not parse, 1 when one could not be read.
+func runDot(paths []string, out io.Writer, errOut io.Writer) int {
+	sources, ok := readSources(paths, errOut)
+	status := 0
+	if !ok {
+		status = 1
+	}
+
+	for _, source := range sources {
+		program, ok := parse(source.path, source.text, errOut)
+		if !ok {
+			status = 2
+			continue
+		}
+
+		io.WriteString(out, dot.AST(program))
+	}
+
+	return status
+}
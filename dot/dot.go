@@ -0,0 +1,238 @@
+// Package dot draws syntax trees and environments as Graphviz graphs, in the
+// DOT language:
+//
+//	go run . dot script.mk | dot -Tsvg > script.svg
+package dot
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"sort"
+	"strings"
+)
+
+// MAX_VALUE_WIDTH is the number of characters of a value shown in the graph
+// of an environment, longer values are cut.
+const MAX_VALUE_WIDTH = 40
+
+// AST returns the graph of the tree of node. Each node is labelled with its
+// type, what it holds besides its children, and its position.
+func AST(node ast.Node) string {
+	var out bytes.Buffer
+
+	out.WriteString("digraph AST {\n")
+	out.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	ast.Walk(&treeWriter{out: &out}, node)
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+// treeWriter is the visitor writing each node and the edge from its parent.
+type treeWriter struct {
+	out     *bytes.Buffer
+	count   int
+	parents []int
+}
+
+func (self *treeWriter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		self.parents = self.parents[:len(self.parents)-1]
+		return nil
+	}
+
+	id := self.count
+	self.count++
+
+	fmt.Fprintf(self.out, "\tn%d [label=\"%s\"];\n", id, escape(nodeLabel(node)))
+	if len(self.parents) > 0 {
+		fmt.Fprintf(self.out, "\tn%d -> n%d;\n", self.parents[len(self.parents)-1], id)
+	}
+
+	self.parents = append(self.parents, id)
+	return self
+}
+
+func nodeLabel(node ast.Node) string {
+	label := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+
+	switch node := node.(type) {
+	case *ast.Identifier:
+		label += "\n" + node.Value
+	case *ast.IntegerLiteral:
+		label += "\n" + node.Token.Literal
+	case *ast.Boolean:
+		label += "\n" + node.Token.Literal
+	case *ast.StringLiteral:
+		label += "\n" + fmt.Sprintf("%q", node.Value)
+	case *ast.PrefixExpression:
+		label += "\n" + node.Operator
+	case *ast.InfixExpression:
+		label += "\n" + node.Operator
+	}
+
+	if node.Pos().IsValid() {
+		label += "\n" + node.Pos().String()
+	}
+
+	return label
+}
+
+// Environment returns the graph of env and the environments it leads to: the
+// ones enclosing it, and those captured by the functions bound in them, found
+// in arrays and hashes too.
+func Environment(env *object.Environment) string {
+	var out bytes.Buffer
+
+	out.WriteString("digraph Environment {\n")
+	out.WriteString("\trankdir=LR;\n")
+	out.WriteString("\tnode [shape=record, fontname=\"monospace\"];\n")
+
+	writer := &environmentWriter{
+		out:          &out,
+		environments: make(map[*object.Environment]int),
+		functions:    make(map[*object.Function]int),
+	}
+	writer.environment(env)
+
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+// environmentWriter writes each environment and function once, numbering them
+// in the order it meets them.
+type environmentWriter struct {
+	out          *bytes.Buffer
+	environments map[*object.Environment]int
+	functions    map[*object.Function]int
+}
+
+func (self *environmentWriter) environment(env *object.Environment) int {
+	if id, ok := self.environments[env]; ok {
+		return id
+	}
+
+	id := len(self.environments)
+	self.environments[env] = id
+
+	title := "environment"
+	if env.Outer() == nil {
+		title = "global"
+	}
+
+	bindings := env.Bindings()
+
+	fields := []string{"<title> " + title}
+	for i, binding := range bindings {
+		fields = append(fields, fmt.Sprintf("<b%d> %s = %s", i, escapeRecord(binding.Name), escapeRecord(valueLabel(binding.Value))))
+	}
+	fmt.Fprintf(self.out, "\tenv%d [label=\"{%s}\"];\n", id, strings.Join(fields, "|"))
+
+	if outer := env.Outer(); outer != nil {
+		outerId := self.environment(outer)
+		fmt.Fprintf(self.out, "\tenv%d:title -> env%d:title [label=\"outer\", style=dashed];\n", id, outerId)
+	}
+
+	for i, binding := range bindings {
+		for _, function := range functionsIn(binding.Value) {
+			functionId := self.function(function)
+			fmt.Fprintf(self.out, "\tenv%d:b%d -> fn%d;\n", id, i, functionId)
+		}
+	}
+
+	return id
+}
+
+func (self *environmentWriter) function(function *object.Function) int {
+	if id, ok := self.functions[function]; ok {
+		return id
+	}
+
+	id := len(self.functions)
+	self.functions[function] = id
+
+	fmt.Fprintf(self.out, "\tfn%d [label=\"%s\", shape=ellipse];\n", id, escape(functionLabel(function)))
+
+	if function.Env != nil {
+		envId := self.environment(function.Env)
+		fmt.Fprintf(self.out, "\tfn%d -> env%d:title [label=\"Env\"];\n", id, envId)
+	}
+
+	return id
+}
+
+// functionsIn returns the functions of value: value itself, or the functions
+// in it when it is an array or a hash.
+func functionsIn(value object.Object) []*object.Function {
+	switch value := value.(type) {
+	case *object.Function:
+		return []*object.Function{value}
+	case *object.Array:
+		var functions []*object.Function
+		for _, element := range value.Elements() {
+			functions = append(functions, functionsIn(element)...)
+		}
+		return functions
+	case *object.Hash:
+		pairs := value.Pairs()
+		sort.Slice(pairs, func(i, j int) bool {
+			return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+		})
+
+		var functions []*object.Function
+		for _, pair := range pairs {
+			functions = append(functions, functionsIn(pair.Value)...)
+		}
+		return functions
+	default:
+		return nil
+	}
+}
+
+func functionLabel(function *object.Function) string {
+	parameters := make([]string, len(function.Parameters))
+	for i, parameter := range function.Parameters {
+		parameters[i] = parameter.Value
+	}
+
+	return "fn(" + strings.Join(parameters, ", ") + ")"
+}
+
+func valueLabel(value object.Object) string {
+	var label string
+	switch value := value.(type) {
+	case *object.Function:
+		label = functionLabel(value)
+	case *object.String:
+		label = fmt.Sprintf("%q", value.Value)
+	default:
+		label = value.Inspect()
+	}
+
+	label = strings.Join(strings.Fields(label), " ")
+	if runes := []rune(label); len(runes) > MAX_VALUE_WIDTH {
+		label = string(runes[:MAX_VALUE_WIDTH-3]) + "..."
+	}
+
+	return label
+}
+
+// escape makes text fit in a quoted DOT string, keeping its line breaks.
+func escape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"`, `\"`)
+	return strings.ReplaceAll(text, "\n", `\n`)
+}
+
+// escapeRecord escapes text for a field of a record label, where braces, bars
+// and angle brackets have a meaning.
+func escapeRecord(text string) string {
+	text = escape(text)
+	for _, special := range []string{"{", "}", "|", "<", ">"} {
+		text = strings.ReplaceAll(text, special, `\`+special)
+	}
+	return text
+}
@@ -0,0 +1,95 @@
+package dot
+
+import (
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"strings"
+	"testing"
+)
+
+func TestAST(t *testing.T) {
+	program := parser.New(lexer.New(`let x = -1;
+puts("ab")`)).ParseProgram()
+
+	expected := `digraph AST {
+	node [shape=box, fontname="monospace"];
+	n0 [label="Program\n1:1"];
+	n1 [label="LetStatement\n1:1"];
+	n0 -> n1;
+	n2 [label="Identifier\nx\n1:5"];
+	n1 -> n2;
+	n3 [label="PrefixExpression\n-\n1:9"];
+	n1 -> n3;
+	n4 [label="IntegerLiteral\n1\n1:10"];
+	n3 -> n4;
+	n5 [label="ExpressionStatement\n2:1"];
+	n0 -> n5;
+	n6 [label="CallExpression\n2:1"];
+	n5 -> n6;
+	n7 [label="Identifier\nputs\n2:1"];
+	n6 -> n7;
+	n8 [label="StringLiteral\n\"ab\"\n2:6"];
+	n6 -> n8;
+}
+`
+
+	if got := AST(program); got != expected {
+		t.Errorf("wrong graph.\nexpected=%s\ngot=%s", expected, got)
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New(`
+let adder = fn(x) { fn(y) { x + y } };
+let addTwo = adder(2);
+let list = [addTwo, "<|>"];
+`)).ParseProgram()
+	evaluator.Eval(program, env)
+
+	got := Environment(env)
+
+	expected := []string{
+		`env0 [label="{<title> global|<b0> adder = fn(x)|<b1> addTwo = fn(y)|<b2> list = [fn(y) \{ (x + y) \}, \<\|\>]}"];`,
+		`fn0 [label="fn(x)", shape=ellipse];`,
+		`fn0 -> env0:title [label="Env"];`,
+		`env0:b0 -> fn0;`,
+		`fn1 [label="fn(y)", shape=ellipse];`,
+		`env1 [label="{<title> environment|<b0> x = 2}"];`,
+		`env1:title -> env0:title [label="outer", style=dashed];`,
+		`fn1 -> env1:title [label="Env"];`,
+		`env0:b1 -> fn1;`,
+		`env0:b2 -> fn1;`,
+	}
+
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != len(expected)+4 {
+		t.Fatalf("wrong number of lines. got=%d, want=%d\n%s", len(lines), len(expected)+4, got)
+	}
+
+	for i, line := range expected {
+		if strings.TrimSpace(lines[i+3]) != line {
+			t.Errorf("wrong line %d.\nexpected=%s\ngot=%s", i, line, strings.TrimSpace(lines[i+3]))
+		}
+	}
+}
+
+func TestValueLabel(t *testing.T) {
+	tests := []struct {
+		value    object.Object
+		expected string
+	}{
+		{object.NewInteger(5), "5"},
+		{&object.String{Value: "a\nb"}, `"a\nb"`},
+		{&object.String{Value: strings.Repeat("é", 50)}, `"` + strings.Repeat("é", 36) + "..."},
+		{object.NULL, "null"},
+	}
+
+	for _, tt := range tests {
+		if got := valueLabel(tt.value); got != tt.expected {
+			t.Errorf("wrong label. got=%s, want=%s", got, tt.expected)
+		}
+	}
+}
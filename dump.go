@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/astjson"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"io"
+	"sort"
+)
+
+// runDump prints, for each file at paths or standard input when there are
+// none, a JSON document: the list of its tokens, comments included, when
+// tokens is set, its AST otherwise. Errors go to errOut. The exit status is
+// 2 when a file does not parse, 1 when one could not be read or encoded.
+func runDump(paths []string, tokens bool, out io.Writer, errOut io.Writer) int {
+	sources, ok := readSources(paths, errOut)
+	status := 0
+	if !ok {
+		status = 1
+	}
+
+	for _, source := range sources {
+		var document []byte
+		var err error
+		if tokens {
+			document, err = json.MarshalIndent(lex(source.text), "", "  ")
+		} else {
+			program, ok := parse(source.path, source.text, errOut)
+			if !ok {
+				status = 2
+				continue
+			}
+
+			document, err = astjson.Marshal(program)
+			if err == nil {
+				document, err = json.MarshalIndent(json.RawMessage(document), "", "  ")
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(errOut, "%s: %s\n", source.path, err)
+			status = max(status, 1)
+			continue
+		}
+
+		fmt.Fprintf(out, "%s\n", document)
+	}
+
+	return status
+}
+
+// lex returns the tokens of source up to and including the EOF token, with
+// its comments among them.
+func lex(source string) []token.Token {
+	monkeyLexer := lexer.New(source)
+
+	var tokens []token.Token
+	for {
+		tok := monkeyLexer.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	tokens = append(tokens, monkeyLexer.Comments()...)
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].Start.Offset < tokens[j].Start.Offset
+	})
+
+	return tokens
+}
@@ -0,0 +1,49 @@
+package evaluator
+
+import (
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"testing"
+)
+
+// Run with -benchmem, or compare allocs/op before and after a change with
+// go test ./evaluator -run NONE -bench . -count 10 | benchstat.
+
+func BenchmarkLoop(b *testing.B) {
+	benchmarkProgram(b, `
+		let loop = fn(i, total) { if (i == 0) { total } else { loop(i - 1, total + i) } };
+		loop(500, 0);
+	`)
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	benchmarkProgram(b, `
+		let fibonacci = fn(x) { if (x < 2) { return x; } fibonacci(x - 1) + fibonacci(x - 2) };
+		fibonacci(20);
+	`)
+}
+
+func BenchmarkClosures(b *testing.B) {
+	benchmarkProgram(b, `
+		let adder = fn(n) { fn(x) { x + n } };
+		let addTwo = adder(2);
+		reduce(map(range(300), fn(x) { addTwo(x) }), fn(total, x) { total + x }, 0);
+	`)
+}
+
+func benchmarkProgram(b *testing.B, input string) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	Resolve(program, env)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		result := Eval(program, env)
+		if errObj, ok := result.(*object.Error); ok {
+			b.Fatalf("%s", errObj.Message)
+		}
+	}
+}
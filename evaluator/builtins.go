@@ -3,20 +3,39 @@ package evaluator
 import (
 	"fmt"
 	"github.com/Neal-C/interpreter-in-go/object"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Builtins returns the builtin functions by name, so that other backends such
+// as the compiler resolve the same builtins as the evaluator.
+func Builtins() map[string]*object.Builtin {
+	return builtins
+}
+
+// MAX_RANGE_LENGTH is the number of elements of the longest array range
+// builds.
+const MAX_RANGE_LENGTH = 1 << 26
+
 var builtins = map[string]*object.Builtin{
+	// len counts the bytes of a string, as Go does, while indexing, substr,
+	// chars and index_of count its characters: len("héllo") is 6 but
+	// len(chars("héllo")) is 5.
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return object.NewInteger(int64(len(arg.Value)))
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return object.NewInteger(int64(arg.Len()))
+			case *object.Hash:
+				return object.NewInteger(int64(arg.Len()))
 			default:
 				return newError("argument to len not supported, got %s", args[0].Type())
 			}
@@ -24,7 +43,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -35,15 +54,15 @@ var builtins = map[string]*object.Builtin{
 
 			myArray := args[0].(*object.Array)
 
-			if len(myArray.Elements) > 0 {
-				return myArray.Elements[0]
+			if myArray.Len() > 0 {
+				return myArray.Get(0)
 			}
 
 			return NULL
 		},
 	},
 	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -53,16 +72,16 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			myArray := args[0].(*object.Array)
-			length := len(myArray.Elements)
-			if len(myArray.Elements) > 0 {
-				return myArray.Elements[length-1]
+			length := myArray.Len()
+			if length > 0 {
+				return myArray.Get(length - 1)
 			}
 
 			return NULL
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -72,18 +91,16 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			myArray := args[0].(*object.Array)
-			length := len(myArray.Elements)
-			if len(myArray.Elements) > 0 {
-				newElements := make([]object.Object, length-1)
-				copy(newElements, myArray.Elements[1:length])
-				return &object.Array{Elements: newElements}
+			length := myArray.Len()
+			if length > 0 {
+				return myArray.Slice(1, length)
 			}
 
 			return NULL
 		},
 	},
 	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2", len(args))
 			}
@@ -93,21 +110,1199 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			myArray := args[0].(*object.Array)
-			length := len(myArray.Elements)
+			return myArray.Push(args[1])
+		},
+	},
+	"puts": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(runtime.Output(), arg.Inspect())
+			}
+			return NULL
+		},
+	},
+	"keys": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to keys must be a HASH, got %s", args[0].Type())
+			}
 
-			newElements := make([]object.Object, length+1)
-			copy(newElements, myArray.Elements)
-			newElements[length] = args[1]
-			return &object.Array{Elements: newElements}
+			pairs := sortedPairs(args[0].(*object.Hash))
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
 
+			return object.NewArray(elements)
 		},
 	},
-	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+	"values": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to values must be a HASH, got %s", args[0].Type())
+			}
+
+			pairs := sortedPairs(args[0].(*object.Hash))
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+
+			return object.NewArray(elements)
+		},
+	},
+	"entries": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to entries must be a HASH, got %s", args[0].Type())
+			}
+
+			pairs := sortedPairs(args[0].(*object.Hash))
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = object.NewArray([]object.Object{pair.Key, pair.Value})
+			}
+
+			return object.NewArray(elements)
+		},
+	},
+	"has": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to has must be a HASH, got %s", args[0].Type())
+			}
+
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			_, ok = args[0].(*object.Hash).Get(key.HashKey())
+			return nativeNodeToBooleanObject(ok)
+		},
+	},
+	"delete": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.HASH_OBJ {
+				return newError("argument to delete must be a HASH, got %s", args[0].Type())
+			}
+
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			return args[0].(*object.Hash).Delete(key.HashKey())
+		},
+	},
+	"merge": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+
+			var merged *object.Hash
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return newError("argument to merge must be a HASH, got %s", arg.Type())
+				}
+
+				if merged == nil {
+					merged = hash
+					continue
+				}
+
+				for _, pair := range hash.Pairs() {
+					merged = merged.Set(pair.Key.(object.Hashable).HashKey(), pair)
+				}
+			}
+
+			return merged
+		},
+	},
+	"concat": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+
+			elements := []object.Object{}
+			for _, arg := range args {
+				array, ok := arg.(*object.Array)
+				if !ok {
+					return newError("argument to concat must be an ARRAY, got %s", arg.Type())
+				}
+
+				elements = append(elements, array.Elements()...)
+			}
+
+			return object.NewArray(elements)
+		},
+	},
+	"slice": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to slice must be an ARRAY, got %s", args[0].Type())
+			}
+
+			myArray := args[0].(*object.Array)
+			start, end, errObj := sliceBounds("slice", args[1:], myArray.Len())
+			if errObj != nil {
+				return errObj
+			}
+
+			return myArray.Slice(start, end)
+		},
+	},
+	"reverse": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to reverse must be an ARRAY, got %s", args[0].Type())
+			}
+
+			myArray := args[0].(*object.Array)
+			length := myArray.Len()
+			newElements := make([]object.Object, length)
+			for i, element := range myArray.Elements() {
+				newElements[length-1-i] = element
+			}
+
+			return object.NewArray(newElements)
+		},
+	},
+	"contains": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
 			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				return nativeNodeToBooleanObject(indexOf(arg, args[1]) != -1)
+			case *object.String:
+				substring, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to contains must be a STRING, got %s", args[1].Type())
+				}
+				return nativeNodeToBooleanObject(strings.Contains(arg.Value, substring.Value))
+			default:
+				return newError("argument to contains must be an ARRAY or a STRING, got %s", args[0].Type())
+			}
+		},
+	},
+	"index_of": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				return object.NewInteger(int64(indexOf(arg, args[1])))
+			case *object.String:
+				substring, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to index_of must be a STRING, got %s", args[1].Type())
+				}
+				return object.NewInteger(int64(runeIndex(arg.Value, substring.Value)))
+			default:
+				return newError("argument to index_of must be an ARRAY or a STRING, got %s", args[0].Type())
+			}
+		},
+	},
+	"flatten": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to flatten must be an ARRAY, got %s", args[0].Type())
+			}
+
+			depth := int64(1)
+			if len(args) == 2 {
+				integer, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("depth argument to flatten must be an INTEGER, got %s", args[1].Type())
+				}
+				depth = integer.Value
+			}
+
+			return object.NewArray(flattenElements(args[0].(*object.Array).Elements(), depth))
+		},
+	},
+	"zip": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+
+			arrays := make([]*object.Array, len(args))
+			length := -1
+			for i, arg := range args {
+				array, ok := arg.(*object.Array)
+				if !ok {
+					return newError("argument to zip must be an ARRAY, got %s", arg.Type())
+				}
+
+				arrays[i] = array
+				if length == -1 || array.Len() < length {
+					length = array.Len()
+				}
+			}
+
+			tuples := make([]object.Object, length)
+			for i := 0; i < length; i++ {
+				tuple := make([]object.Object, len(arrays))
+				for j, array := range arrays {
+					tuple[j] = array.Get(i)
+				}
+				tuples[i] = object.NewArray(tuple)
+			}
+
+			return object.NewArray(tuples)
+		},
+	},
+	"range": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("argument to range must be an INTEGER, got %s", arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+
+			start, end, step := int64(0), bounds[0], int64(1)
+			if len(bounds) > 1 {
+				start, end = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				step = bounds[2]
+			}
+
+			if step == 0 {
+				return newError("step argument to range must not be 0")
+			}
+
+			length := rangeLength(start, end, step)
+			if length > MAX_RANGE_LENGTH {
+				return newError("range too long: %d elements, want at most %d", length, MAX_RANGE_LENGTH)
+			}
+
+			elements := make([]object.Object, length)
+			for i := range elements {
+				elements[i] = object.NewInteger(start + int64(i)*step)
+			}
+
+			return object.NewArray(elements)
+		},
+	},
+	"map": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			myArray, fn, errObj := arrayAndFunctionArgs("map", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			newElements := make([]object.Object, myArray.Len())
+			for i, element := range myArray.Elements() {
+				result := runtime.Call(fn, element)
+				if isError(result) {
+					return result
+				}
+				newElements[i] = result
+			}
+
+			return object.NewArray(newElements)
+		},
+	},
+	"filter": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			myArray, fn, errObj := arrayAndFunctionArgs("filter", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			newElements := []object.Object{}
+			for _, element := range myArray.Elements() {
+				result := runtime.Call(fn, element)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					newElements = append(newElements, element)
+				}
+			}
+
+			return object.NewArray(newElements)
+		},
+	},
+	"reduce": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+
+			myArray, fn, errObj := arrayAndFunctionArgs("reduce", args[:2])
+			if errObj != nil {
+				return errObj
+			}
+
+			elements := myArray.Elements()
+			var accumulator object.Object
+			if len(args) == 3 {
+				accumulator = args[2]
+			} else {
+				if len(elements) == 0 {
+					return newError("reduce of empty ARRAY with no initial value")
+				}
+				accumulator, elements = elements[0], elements[1:]
+			}
+
+			for _, element := range elements {
+				accumulator = runtime.Call(fn, accumulator, element)
+				if isError(accumulator) {
+					return accumulator
+				}
+			}
+
+			return accumulator
+		},
+	},
+	"each": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			myArray, fn, errObj := arrayAndFunctionArgs("each", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			for _, element := range myArray.Elements() {
+				result := runtime.Call(fn, element)
+				if isError(result) {
+					return result
+				}
+			}
+
+			return NULL
+		},
+	},
+	"find": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			myArray, fn, errObj := arrayAndFunctionArgs("find", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			for _, element := range myArray.Elements() {
+				result := runtime.Call(fn, element)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return element
+				}
+			}
+
 			return NULL
 		},
 	},
+	"any": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			myArray, fn, errObj := arrayAndFunctionArgs("any", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			for _, element := range myArray.Elements() {
+				result := runtime.Call(fn, element)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return TRUE
+				}
+			}
+
+			return FALSE
+		},
+	},
+	"all": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			myArray, fn, errObj := arrayAndFunctionArgs("all", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			for _, element := range myArray.Elements() {
+				result := runtime.Call(fn, element)
+				if isError(result) {
+					return result
+				}
+				if !isTruthy(result) {
+					return FALSE
+				}
+			}
+
+			return TRUE
+		},
+	},
+	"sort": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			if len(args) == 1 {
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to sort must be an ARRAY, got %s", args[0].Type())
+				}
+
+				myArray := args[0].(*object.Array)
+				elements := myArray.Elements()
+				return sortElements(elements, elements, compareObjectsOrError)
+			}
+
+			myArray, fn, errObj := arrayAndFunctionArgs("sort", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			elements := myArray.Elements()
+			return sortElements(elements, elements, func(left object.Object, right object.Object) (int, *object.Error) {
+				result := runtime.Call(fn, left, right)
+				if errObj, ok := result.(*object.Error); ok {
+					return 0, errObj
+				}
+
+				integer, ok := result.(*object.Integer)
+				if !ok {
+					return 0, newError("comparator given to sort must return an INTEGER, got %s", result.Type())
+				}
+
+				return int(integer.Value), nil
+			})
+		},
+	},
+	"sort_by": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			myArray, fn, errObj := arrayAndFunctionArgs("sort_by", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			elements := myArray.Elements()
+			sortKeys := make([]object.Object, len(elements))
+			for i, element := range elements {
+				result := runtime.Call(fn, element)
+				if isError(result) {
+					return result
+				}
+				sortKeys[i] = result
+			}
+
+			return sortElements(elements, sortKeys, compareObjectsOrError)
+		},
+	},
+	"group_by": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			myArray, fn, errObj := arrayAndFunctionArgs("group_by", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			groups := &object.Hash{}
+			for _, element := range myArray.Elements() {
+				result := runtime.Call(fn, element)
+				if isError(result) {
+					return result
+				}
+
+				key, ok := result.(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", result.Type())
+				}
+
+				hashKey := key.HashKey()
+				group, ok := groups.Get(hashKey)
+				if !ok {
+					group = object.HashPair{Key: result, Value: &object.Array{}}
+				}
+				members := group.Value.(*object.Array)
+				groups = groups.Set(hashKey, object.HashPair{Key: group.Key, Value: members.Push(element)})
+			}
+
+			return groups
+		},
+	},
+	"split": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			strs, errObj := stringArgs("split", args, 2)
+			if errObj != nil {
+				return errObj
+			}
+
+			parts := strings.Split(strs[0], strs[1])
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+
+			return object.NewArray(elements)
+		},
+	},
+	"join": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			myArray, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to join must be an ARRAY, got %s", args[0].Type())
+			}
+
+			separator := ""
+			if len(args) == 2 {
+				str, ok := args[1].(*object.String)
+				if !ok {
+					return newError("separator given to join must be a STRING, got %s", args[1].Type())
+				}
+				separator = str.Value
+			}
+
+			parts := make([]string, myArray.Len())
+			for i, element := range myArray.Elements() {
+				str, ok := element.(*object.String)
+				if !ok {
+					return newError("elements given to join must be STRING, got %s", element.Type())
+				}
+				parts[i] = str.Value
+			}
+
+			return &object.String{Value: strings.Join(parts, separator)}
+		},
+	},
+	"trim": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			return trimBuiltin("trim", args, strings.TrimSpace, strings.Trim)
+		},
+	},
+	"trim_left": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			trimSpace := func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) }
+			return trimBuiltin("trim_left", args, trimSpace, strings.TrimLeft)
+		},
+	},
+	"trim_right": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			trimSpace := func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }
+			return trimBuiltin("trim_right", args, trimSpace, strings.TrimRight)
+		},
+	},
+	"upper": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			strs, errObj := stringArgs("upper", args, 1)
+			if errObj != nil {
+				return errObj
+			}
+
+			return &object.String{Value: strings.ToUpper(strs[0])}
+		},
+	},
+	"lower": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			strs, errObj := stringArgs("lower", args, 1)
+			if errObj != nil {
+				return errObj
+			}
+
+			return &object.String{Value: strings.ToLower(strs[0])}
+		},
+	},
+	"replace": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 3 && len(args) != 4 {
+				return newError("wrong number of arguments. got=%d, want=3 or 4", len(args))
+			}
+
+			strs, errObj := stringArgs("replace", args[:3], 3)
+			if errObj != nil {
+				return errObj
+			}
+
+			count := -1
+			if len(args) == 4 {
+				integer, ok := args[3].(*object.Integer)
+				if !ok {
+					return newError("count argument to replace must be an INTEGER, got %s", args[3].Type())
+				}
+				count = int(integer.Value)
+			}
+
+			return &object.String{Value: strings.Replace(strs[0], strs[1], strs[2], count)}
+		},
+	},
+	"starts_with": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			strs, errObj := stringArgs("starts_with", args, 2)
+			if errObj != nil {
+				return errObj
+			}
+
+			return nativeNodeToBooleanObject(strings.HasPrefix(strs[0], strs[1]))
+		},
+	},
+	"ends_with": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			strs, errObj := stringArgs("ends_with", args, 2)
+			if errObj != nil {
+				return errObj
+			}
+
+			return nativeNodeToBooleanObject(strings.HasSuffix(strs[0], strs[1]))
+		},
+	},
+	"repeat": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to repeat must be a STRING, got %s", args[0].Type())
+			}
+
+			if args[1].Type() != object.INTEGER_OBJ {
+				return newError("count argument to repeat must be an INTEGER, got %s", args[1].Type())
+			}
+
+			return repeatString(args[0].(*object.String), args[1].(*object.Integer))
+		},
+	},
+	"pad_left": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			return padBuiltin("pad_left", args, func(s string, padding string) string { return padding + s })
+		},
+	},
+	"pad_right": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			return padBuiltin("pad_right", args, func(s string, padding string) string { return s + padding })
+		},
+	},
+	"chars": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			strs, errObj := stringArgs("chars", args, 1)
+			if errObj != nil {
+				return errObj
+			}
+
+			runes := []rune(strs[0])
+			elements := make([]object.Object, len(runes))
+			for i, r := range runes {
+				elements[i] = &object.String{Value: string(r)}
+			}
+
+			return object.NewArray(elements)
+		},
+	},
+	"substr": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to substr must be a STRING, got %s", args[0].Type())
+			}
+
+			runes := []rune(args[0].(*object.String).Value)
+			start, end, errObj := sliceBounds("substr", args[1:2], len(runes))
+			if errObj != nil {
+				return errObj
+			}
+
+			if len(args) == 3 {
+				length, ok := args[2].(*object.Integer)
+				if !ok {
+					return newError("length argument to substr must be an INTEGER, got %s", args[2].Type())
+				}
+				end = start + int(max(0, min(length.Value, int64(len(runes)-start))))
+			}
+
+			return &object.String{Value: string(runes[start:end])}
+		},
+	},
+	"format": &object.Builtin{
+		Fn: formatBuiltin,
+	},
+	"sprintf": &object.Builtin{
+		Fn: formatBuiltin,
+	},
+	"str": &object.Builtin{
+		Fn: func(runtime object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
+}
+
+// formatBuiltin implements format and sprintf, a printf-style formatting of its
+// arguments. Verbs accept the flags, width and precision of Go's fmt package:
+// %d %b %o %x %X for integers, %f %e %g for integers printed as decimals,
+// %s and %q for strings, %t for booleans and %v for any value's Inspect().
+func formatBuiltin(runtime object.Runtime, args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+
+	formatStr, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to format must be a STRING, got %s", args[0].Type())
+	}
+
+	var out strings.Builder
+	format := formatStr.Value
+	values := args[1:]
+	used := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0123456789.", format[i]) != -1 {
+			i++
+		}
+
+		if i >= len(format) {
+			return newError("format string ends with an incomplete verb %q", format[start:])
+		}
+
+		spec := format[start : i+1]
+		if format[i] == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		if used >= len(values) {
+			return newError("missing argument for %s in format string", spec)
+		}
+
+		formatted, errObj := formatVerb(spec, values[used])
+		if errObj != nil {
+			return errObj
+		}
+		out.WriteString(formatted)
+		used++
+	}
+
+	if used != len(values) {
+		return newError("too many arguments for format string. got=%d, want=%d", len(values), used)
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func formatVerb(spec string, value object.Object) (string, *object.Error) {
+	verb := spec[len(spec)-1]
+
+	switch verb {
+	case 'd', 'b', 'o', 'x', 'X':
+		integer, ok := value.(*object.Integer)
+		if !ok {
+			return "", newError("%s in format string needs an INTEGER, got %s", spec, value.Type())
+		}
+		return fmt.Sprintf(spec, integer.Value), nil
+	case 'f', 'e', 'g':
+		integer, ok := value.(*object.Integer)
+		if !ok {
+			return "", newError("%s in format string needs an INTEGER, got %s", spec, value.Type())
+		}
+		return fmt.Sprintf(spec, float64(integer.Value)), nil
+	case 's', 'q':
+		str, ok := value.(*object.String)
+		if !ok {
+			return "", newError("%s in format string needs a STRING, got %s", spec, value.Type())
+		}
+		return fmt.Sprintf(spec, str.Value), nil
+	case 't':
+		boolean, ok := value.(*object.Boolean)
+		if !ok {
+			return "", newError("%s in format string needs a BOOLEAN, got %s", spec, value.Type())
+		}
+		return fmt.Sprintf(spec, boolean.Value), nil
+	case 'v':
+		return fmt.Sprintf(spec[:len(spec)-1]+"s", value.Inspect()), nil
+	default:
+		return "", newError("unknown verb %s in format string", spec)
+	}
+}
+
+// stringArgs checks that args holds exactly count strings and unwraps them.
+func stringArgs(name string, args []object.Object, count int) ([]string, *object.Error) {
+	if len(args) != count {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), count)
+	}
+
+	strs := make([]string, count)
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError("argument to %s must be a STRING, got %s", name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+
+	return strs, nil
+}
+
+// trimBuiltin implements the trim family: whitespace is trimmed unless a
+// second argument gives the set of characters to trim.
+func trimBuiltin(name string, args []object.Object, trimSpace func(string) string, trimCutset func(string, string) string) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	strs, errObj := stringArgs(name, args, len(args))
+	if errObj != nil {
+		return errObj
+	}
+
+	if len(strs) == 2 {
+		return &object.String{Value: trimCutset(strs[0], strs[1])}
+	}
+
+	return &object.String{Value: trimSpace(strs[0])}
+}
+
+// padBuiltin implements pad_left and pad_right: the string is padded up to width
+// characters with an optional padding string that defaults to a blank.
+func padBuiltin(name string, args []object.Object, pad func(string, string) string) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to %s must be a STRING, got %s", name, args[0].Type())
+	}
+
+	width, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError("width argument to %s must be an INTEGER, got %s", name, args[1].Type())
+	}
+	if width.Value > object.MAX_STRING_LENGTH {
+		return newError("width argument to %s too large: %d", name, width.Value)
+	}
+
+	padding := []rune(" ")
+	if len(args) == 3 {
+		padStr, ok := args[2].(*object.String)
+		if !ok || padStr.Value == "" {
+			return newError("padding argument to %s must be a non-empty STRING, got %s", name, args[2].Inspect())
+		}
+		padding = []rune(padStr.Value)
+	}
+
+	missing := int(width.Value) - utf8.RuneCountInString(str.Value)
+	if missing <= 0 {
+		return str
+	}
+
+	fill := make([]rune, missing)
+	for i := range fill {
+		fill[i] = padding[i%len(padding)]
+	}
+
+	return &object.String{Value: pad(str.Value, string(fill))}
+}
+
+func repeatString(str *object.String, count *object.Integer) object.Object {
+	if count.Value < 0 {
+		return newError("negative repeat count: %d", count.Value)
+	}
+	if len(str.Value) > 0 && count.Value > int64(object.MAX_STRING_LENGTH/len(str.Value)) {
+		return newError("repeat count too large: %d", count.Value)
+	}
+
+	return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+}
+
+// rangeLength is the number of elements from start to end, end excluded, by
+// step, counted without overflowing.
+func rangeLength(start int64, end int64, step int64) uint64 {
+	var distance, stride uint64
+	switch {
+	case step > 0 && start < end:
+		distance, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		distance, stride = uint64(start)-uint64(end), -uint64(step)
+	default:
+		return 0
+	}
+
+	return (distance-1)/stride + 1
+}
+
+// runeIndex is strings.Index counted in characters rather than bytes.
+func runeIndex(s string, substring string) int {
+	index := strings.Index(s, substring)
+	if index == -1 {
+		return -1
+	}
+
+	return utf8.RuneCountInString(s[:index])
+}
+
+// arrayAndFunctionArgs validates the (array, function) arguments shared by the
+// higher-order builtins.
+func arrayAndFunctionArgs(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	myArray, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, nil, newError("argument to %s must be an ARRAY, got %s", name, args[0].Type())
+	}
+
+	switch args[1].Type() {
+	case object.FUNCTION_OBJ, object.BUILTIN_OBJ:
+		return myArray, args[1], nil
+	default:
+		return nil, nil, newError("argument to %s must be a FUNCTION, got %s", name, args[1].Type())
+	}
+}
+
+// sortElements returns a new array holding elements stably sorted by their
+// matching entry in sortKeys. The first error reported by compare aborts the sort.
+func sortElements(elements []object.Object, sortKeys []object.Object, compare func(object.Object, object.Object) (int, *object.Error)) object.Object {
+	indices := make([]int, len(elements))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	var sortErr *object.Error
+	sort.SliceStable(indices, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+
+		comparison, errObj := compare(sortKeys[indices[i]], sortKeys[indices[j]])
+		if errObj != nil {
+			sortErr = errObj
+			return false
+		}
+
+		return comparison < 0
+	})
+
+	if sortErr != nil {
+		return sortErr
+	}
+
+	sorted := make([]object.Object, len(elements))
+	for i, index := range indices {
+		sorted[i] = elements[index]
+	}
+
+	return object.NewArray(sorted)
+}
+
+func compareObjectsOrError(left object.Object, right object.Object) (int, *object.Error) {
+	comparison, ok := compareObjects(left, right)
+	if !ok {
+		return 0, newError("unable to compare %s with %s", left.Type(), right.Type())
+	}
+
+	return comparison, nil
+}
+
+// sortedPairs returns the pairs of a hash grouped by key type and then in the
+// natural order of the keys, so that keys, values and entries are reproducible.
+func sortedPairs(hash *object.Hash) []object.HashPair {
+	pairs := hash.Pairs()
+
+	sort.Slice(pairs, func(i, j int) bool {
+		left, right := pairs[i].Key, pairs[j].Key
+		if left.Type() != right.Type() {
+			return left.Type() < right.Type()
+		}
+		comparison, _ := compareObjects(left, right)
+		return comparison < 0
+	})
+
+	return pairs
+}
+
+// compareObjects orders two objects of the same comparable type.
+// ok is false when the objects cannot be ordered against each other.
+func compareObjects(left object.Object, right object.Object) (comparison int, ok bool) {
+	switch left := left.(type) {
+	case *object.Integer:
+		if right, ok := right.(*object.Integer); ok {
+			switch {
+			case left.Value < right.Value:
+				return -1, true
+			case left.Value > right.Value:
+				return 1, true
+			}
+			return 0, true
+		}
+	case *object.String:
+		if right, ok := right.(*object.String); ok {
+			return strings.Compare(left.Value, right.Value), true
+		}
+	case *object.Boolean:
+		if right, ok := right.(*object.Boolean); ok {
+			switch {
+			case left.Value == right.Value:
+				return 0, true
+			case !left.Value:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// objectsEqual compares two objects by value, descending into arrays and hashes.
+func objectsEqual(left object.Object, right object.Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	switch left := left.(type) {
+	case *object.Integer:
+		return left.Value == right.(*object.Integer).Value
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+	case *object.Boolean:
+		return left.Value == right.(*object.Boolean).Value
+	case *object.Null:
+		return true
+	case *object.Array:
+		rightArray := right.(*object.Array)
+		if left.Len() != rightArray.Len() {
+			return false
+		}
+		for i, element := range left.Elements() {
+			if !objectsEqual(element, rightArray.Get(i)) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		rightHash := right.(*object.Hash)
+		if left.Len() != rightHash.Len() {
+			return false
+		}
+		for _, pair := range left.Pairs() {
+			other, ok := rightHash.Get(pair.Key.(object.Hashable).HashKey())
+			if !ok || !objectsEqual(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left == right
+	}
+}
+
+func indexOf(array *object.Array, value object.Object) int {
+	for i, element := range array.Elements() {
+		if objectsEqual(element, value) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func flattenElements(elements []object.Object, depth int64) []object.Object {
+	flattened := []object.Object{}
+
+	for _, element := range elements {
+		if nested, ok := element.(*object.Array); ok && depth > 0 {
+			flattened = append(flattened, flattenElements(nested.Elements(), depth-1)...)
+			continue
+		}
+		flattened = append(flattened, element)
+	}
+
+	return flattened
+}
+
+// sliceBounds resolves the start and optional end arguments of a slicing builtin
+// against a sequence of the given length. Negative indices count from the end
+// and out of range indices are clamped.
+func sliceBounds(name string, args []object.Object, length int) (int, int, *object.Error) {
+	bounds := []int{0, length}
+
+	for i, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return 0, 0, newError("index argument to %s must be an INTEGER, got %s", name, arg.Type())
+		}
+
+		index := int(integer.Value)
+		if index < 0 {
+			index += length
+		}
+		bounds[i] = max(0, min(index, length))
+	}
+
+	if bounds[1] < bounds[0] {
+		bounds[1] = bounds[0]
+	}
+
+	return bounds[0], bounds[1], nil
 }
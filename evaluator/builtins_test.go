@@ -0,0 +1,297 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"sync/atomic"
+	"testing"
+)
+
+// inspected is the expected Inspect() output of a result that is not worth
+// checking field by field, such as a nested array.
+type inspected string
+
+func TestHashBuiltins(t *testing.T) {
+	tableTests := []struct {
+		input    string
+		expected any
+	}{
+		{`len({})`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`keys({"b": 2, "a": 1, 3: 3, true: 4})`, inspected(`[true, 3, a, b]`)},
+		{`values({"b": 2, "a": 1})`, []int64{1, 2}},
+		{`entries({"b": 2, "a": 1})`, inspected(`[[a, 1], [b, 2]]`)},
+		{`keys([])`, "argument to keys must be a HASH, got ARRAY"},
+		{`has({"a": 1}, "a")`, true},
+		{`has({"a": 1}, "b")`, false},
+		{`has({"a": 1}, [])`, "unusable as hash key: ARRAY"},
+		{`has({"a": 1})`, "wrong number of arguments. got=1, want=2"},
+		{`keys(delete({"a": 1, "b": 2}, "a"))`, inspected(`[b]`)},
+		{`let h = {"a": 1}; delete(h, "a"); len(h)`, 1},
+		{`merge({"a": 1, "b": 2}, {"b": 3}, {"c": 4})["b"]`, 3},
+		{`len(merge({"a": 1, "b": 2}, {"b": 3}, {"c": 4}))`, 3},
+		{`merge({}, [])`, "argument to merge must be a HASH, got ARRAY"},
+		{`merge()`, "wrong number of arguments. got=0, want at least 1"},
+	}
+
+	for _, tt := range tableTests {
+		testBuiltinResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayBuiltins(t *testing.T) {
+	tableTests := []struct {
+		input    string
+		expected any
+	}{
+		{`concat([1], [], [2, 3])`, []int64{1, 2, 3}},
+		{`concat([1], 2)`, "argument to concat must be an ARRAY, got INTEGER"},
+		{`slice([1, 2, 3, 4], 1)`, []int64{2, 3, 4}},
+		{`slice([1, 2, 3, 4], 1, 3)`, []int64{2, 3}},
+		{`slice([1, 2, 3, 4], -2)`, []int64{3, 4}},
+		{`slice([1, 2, 3, 4], 3, 1)`, []int64{}},
+		{`slice([1, 2, 3, 4], 0, 100)`, []int64{1, 2, 3, 4}},
+		{`slice([1, 2], "a")`, "index argument to slice must be an INTEGER, got STRING"},
+		{`slice([1, 2])`, "wrong number of arguments. got=1, want=2 or 3"},
+		{`reverse([1, 2, 3])`, []int64{3, 2, 1}},
+		{`let a = [1, 2]; reverse(a); a`, []int64{1, 2}},
+		{`contains([1, "a", [2]], [2])`, true},
+		{`contains([1, "a"], "b")`, false},
+		{`contains(1, 1)`, "argument to contains must be an ARRAY or a STRING, got INTEGER"},
+		{`index_of([1, 2, 3], 3)`, 2},
+		{`index_of([1, 2, 3], 4)`, -1},
+		{`index_of([{"a": 1}], {"a": 1})`, 0},
+		{`flatten([1, [2, [3]], []])`, inspected(`[1, 2, [3]]`)},
+		{`flatten([1, [2, [3]]], 2)`, []int64{1, 2, 3}},
+		{`flatten([1], "a")`, "depth argument to flatten must be an INTEGER, got STRING"},
+		{`zip([1, 2, 3], ["a", "b"])`, inspected(`[[1, a], [2, b]]`)},
+		{`zip([1], {})`, "argument to zip must be an ARRAY, got HASH"},
+		{`range(3)`, []int64{0, 1, 2}},
+		{`range(2, 5)`, []int64{2, 3, 4}},
+		{`range(5, 0, -2)`, []int64{5, 3, 1}},
+		{`range(0)`, []int64{}},
+		{`range(1, 2, 0)`, "step argument to range must not be 0"},
+		{`range(0, 9223372036854775807, 4611686018427387904)`, []int64{0, 4611686018427387904}},
+		{`range(9223372036854775807, -9223372036854775807 - 1, -9223372036854775807 - 1)`, []int64{9223372036854775807, -1}},
+		{`range(0, 9223372036854775807)`, "range too long: 9223372036854775807 elements, want at most 67108864"},
+		{`range("a")`, "argument to range must be an INTEGER, got STRING"},
+		{`range()`, "wrong number of arguments. got=0, want=1 to 3"},
+		{`let a = [1, 2]; let b = push(a, 3); a`, []int64{1, 2}},
+		{`let a = [1, 2, 3]; let r = rest(a); push(r, 4); push(a, 5)`, []int64{1, 2, 3, 5}},
+		{`let a = [1, 2, 3]; let s = slice(a, 0, 1); push(s, 9); a`, []int64{1, 2, 3}},
+		{`len(reduce(range(2000), fn(acc, x) { push(acc, x) }, []))`, 2000},
+		{`let big = reduce(range(2000), fn(acc, x) { push(acc, x) }, []); big[1234]`, 1234},
+	}
+
+	for _, tt := range tableTests {
+		testBuiltinResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tableTests := []struct {
+		input    string
+		expected any
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, []int64{2, 4, 6}},
+		{`map([[1], [1, 2]], len)`, []int64{1, 2}},
+		{`map([1], 2)`, "argument to map must be a FUNCTION, got INTEGER"},
+		{`map(1, fn(x) { x })`, "argument to map must be an ARRAY, got INTEGER"},
+		{`map([1, 2], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`map([1], fn(x, y) { x })`, "wrong number of arguments. got=1, want=2"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, []int64{3, 4}},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x })`, 6},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, 16},
+		{`reduce([], fn(acc, x) { acc + x }, 10)`, 10},
+		{`reduce([], fn(acc, x) { acc + x })`, "reduce of empty ARRAY with no initial value"},
+		{`each([1, 2], fn(x) { x })`, nil},
+		{`find([1, 2, 3], fn(x) { x > 1 })`, 2},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, nil},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, true},
+		{`any([], fn(x) { true })`, false},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, true},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, false},
+		{`sort([3, 1, 2])`, []int64{1, 2, 3}},
+		{`sort(["b", "c", "a"])`, inspected(`[a, b, c]`)},
+		{`sort([3, 1, 2], fn(a, b) { b - a })`, []int64{3, 2, 1}},
+		{`let a = [2, 1]; sort(a); a`, []int64{2, 1}},
+		{`sort([1, "a"])`, "unable to compare STRING with INTEGER"},
+		{`sort([1, 2], fn(a, b) { true })`, "comparator given to sort must return an INTEGER, got BOOLEAN"},
+		{`sort_by(["ccc", "a", "bb"], len)`, inspected(`[a, bb, ccc]`)},
+		{`sort_by([{"n": 2}, {"n": 1}], fn(h) { h["n"] })`, inspected(`[{n: 1}, {n: 2}]`)},
+		{`group_by([1, 2, 3, 4, 5], fn(x) { x > 2 })[true]`, []int64{3, 4, 5}},
+		{`group_by(["a", "bb", "cc"], len)[2]`, inspected(`[bb, cc]`)},
+		{`group_by([1], fn(x) { [x] })`, "unusable as hash key: ARRAY"},
+		{`len(map(range(10000), fn(x) { x + 1 }))`, 10000},
+	}
+
+	for _, tt := range tableTests {
+		testBuiltinResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tableTests := []struct {
+		input    string
+		expected any
+	}{
+		{`len("héllo")`, 6},
+		{`len(chars("héllo"))`, 5},
+		{`"héllo"[4]`, inspected(`o`)},
+		{`"héllo"[5]`, nil},
+		{`split("a,b,,c", ",")`, inspected(`[a, b, , c]`)},
+		{`len(split("abc", ""))`, 3},
+		{`split("a", 1)`, "argument to split must be a STRING, got INTEGER"},
+		{`join(["a", "b", "c"], "-")`, inspected(`a-b-c`)},
+		{`join(["a", "b"])`, inspected(`ab`)},
+		{`join(["a", 1], "-")`, "elements given to join must be STRING, got INTEGER"},
+		{`trim("  hi  ")`, inspected(`hi`)},
+		{`trim("xxhixx", "x")`, inspected(`hi`)},
+		{`trim_left("  hi  ")`, inspected(`hi  `)},
+		{`trim_right("  hi  ")`, inspected(`  hi`)},
+		{`trim_right("hi!!", "!")`, inspected(`hi`)},
+		{`upper("Hello")`, inspected(`HELLO`)},
+		{`lower("Hello")`, inspected(`hello`)},
+		{`replace("a-b-c", "-", "+")`, inspected(`a+b+c`)},
+		{`replace("a-b-c", "-", "+", 1)`, inspected(`a+b-c`)},
+		{`replace("a", "b")`, "wrong number of arguments. got=2, want=3 or 4"},
+		{`contains("monkey", "key")`, true},
+		{`contains("monkey", "donkey")`, false},
+		{`contains("monkey", 1)`, "second argument to contains must be a STRING, got INTEGER"},
+		{`starts_with("monkey", "mon")`, true},
+		{`ends_with("monkey", "mon")`, false},
+		{`index_of("héllo", "l")`, 2},
+		{`index_of("hello", "z")`, -1},
+		{`repeat("ab", 3)`, inspected(`ababab`)},
+		{`repeat("ab", -1)`, "negative repeat count: -1"},
+		{`repeat("ab", 9223372036854775807)`, "repeat count too large: 9223372036854775807"},
+		{`pad_left("7", 3, "0")`, inspected(`007`)},
+		{`pad_right("ab", 5, "-=")`, inspected(`ab-=-`)},
+		{`pad_left("abcd", 2)`, inspected(`abcd`)},
+		{`pad_left("x", 4611686018427387904)`, "width argument to pad_left too large: 4611686018427387904"},
+		{`pad_right("x", 100000000000)`, "width argument to pad_right too large: 100000000000"},
+		{`pad_left("a", 2, "")`, "padding argument to pad_left must be a non-empty STRING, got "},
+		{`chars("héy")`, inspected(`[h, é, y]`)},
+		{`substr("monkey", 3)`, inspected(`key`)},
+		{`substr("monkey", 0, 3)`, inspected(`mon`)},
+		{`substr("monkey", -3, 2)`, inspected(`ke`)},
+		{`substr("monkey", 2, 100)`, inspected(`nkey`)},
+		{`substr("hello", 1, 9223372036854775807)`, inspected(`ello`)},
+		{`substr("hello", 1, -9223372036854775807)`, inspected(``)},
+		{`substr(1, 2)`, "argument to substr must be a STRING, got INTEGER"},
+	}
+
+	for _, tt := range tableTests {
+		testBuiltinResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFormatBuiltins(t *testing.T) {
+	tableTests := []struct {
+		input    string
+		expected any
+	}{
+		{`format("n=%d", 42)`, inspected(`n=42`)},
+		{`sprintf("%s has %d items", "cart", 3)`, inspected(`cart has 3 items`)},
+		{`format("[%5d|%-5d|%05d]", 42, 42, 42)`, inspected(`[   42|42   |00042]`)},
+		{`format("%x %X %b %o", 255, 255, 5, 8)`, inspected(`ff FF 101 10`)},
+		{`format("%.2f %e", 3, 1000)`, inspected(`3.00 1.000000e+03`)},
+		{`format("[%-6s|%6s|%.2s]", "ab", "ab", "abc")`, inspected(`[ab    |    ab|ab]`)},
+		{`format("%q", "hi")`, inspected(`"hi"`)},
+		{`format("%t", 1 < 2)`, inspected(`true`)},
+		{`format("%v and %v", [1, "a"], {"k": true})`, inspected(`[1, a] and {k: true}`)},
+		{`format("100%%")`, inspected(`100%`)},
+		{`format("plain")`, inspected(`plain`)},
+		{`format("%d", "a")`, "%d in format string needs an INTEGER, got STRING"},
+		{`format("%s", 1)`, "%s in format string needs a STRING, got INTEGER"},
+		{`format("%d %d", 1)`, "missing argument for %d in format string"},
+		{`format("%d", 1, 2)`, "too many arguments for format string. got=2, want=1"},
+		{`format("%z", 1)`, "unknown verb %z in format string"},
+		{`format("50%")`, `format string ends with an incomplete verb "%"`},
+		{`format(1)`, "argument to format must be a STRING, got INTEGER"},
+		{`str(1)`, inspected(`1`)},
+		{`str("a") + str(true) + str([1, 2]) + str(fn(x) { x })`, inspected("atrue[1, 2]fn(x) {\nx\n}")},
+		{`"n=" + str(1)`, inspected(`n=1`)},
+		{`str()`, "wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tableTests {
+		testBuiltinResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestPutsOutput(t *testing.T) {
+	var out bytes.Buffer
+
+	evaluated := testEvalPrinting(`puts(1, "two", [3]); puts()`, &out)
+	testNullObject(t, evaluated)
+
+	expected := "1\ntwo\n[3]\n"
+	if out.String() != expected {
+		t.Errorf("wrong output of puts. got=%q, want=%q", out.String(), expected)
+	}
+}
+
+func TestPutsOutputOfEachEvaluation(t *testing.T) {
+	outs := make([]bytes.Buffer, 8)
+	var next atomic.Int64
+
+	concurrently(len(outs), func() object.Object {
+		i := next.Add(1) - 1
+		return testEvalPrinting(fmt.Sprintf(`map([1, 2, 3], fn(x) { puts(%d * x) })`, i), &outs[i])
+	})
+
+	for i := range outs {
+		expected := fmt.Sprintf("%d\n%d\n%d\n", i, 2*i, 3*i)
+		if outs[i].String() != expected {
+			t.Errorf("wrong output of evaluation %d. got=%q, want=%q", i, outs[i].String(), expected)
+		}
+	}
+}
+
+func testBuiltinResult(t *testing.T, input string, evaluated object.Object, expected any) bool {
+	switch expected := expected.(type) {
+	case int:
+		return testIntegerObject(t, evaluated, int64(expected))
+	case bool:
+		return testBooleanObject(t, evaluated, expected)
+	case nil:
+		return testNullObject(t, evaluated)
+	case []int64:
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			t.Errorf("%s: evaluated is not *object.Array, got = %T (%v)", input, evaluated, evaluated)
+			return false
+		}
+
+		if array.Len() != len(expected) {
+			t.Errorf("%s: wrong number of elements, got = %d, want = %d", input, array.Len(), len(expected))
+			return false
+		}
+
+		for i, element := range expected {
+			if !testIntegerObject(t, array.Get(i), element) {
+				return false
+			}
+		}
+	case inspected:
+		if evaluated == nil || evaluated.Inspect() != string(expected) {
+			t.Errorf("%s: wrong result, got = %v, want = %s", input, evaluated, expected)
+			return false
+		}
+	case string:
+		errorObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: errorObj is not *object.Error, got = %T (%v)", input, evaluated, evaluated)
+			return false
+		}
+
+		if errorObj.Message != expected {
+			t.Errorf("%s: wrong error message. expected = %q, got = %q", input, expected, errorObj.Message)
+			return false
+		}
+	}
+
+	return true
+}
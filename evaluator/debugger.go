@@ -0,0 +1,20 @@
+package evaluator
+
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/object"
+)
+
+// Debugger is told by the evaluator of the statements it runs and of the
+// functions it calls. It pauses the evaluation by not returning.
+type Debugger interface {
+	// Statement is called before statement is evaluated in env. A non-nil
+	// error stops the evaluation, as if the statement evaluated to it.
+	Statement(statement ast.Statement, env *object.Environment) *object.Error
+	// Call is called before the body of fn is evaluated in env, which binds
+	// its parameters.
+	Call(fn *object.Function, env *object.Environment)
+	// Return is called once the call of fn evaluated to result. A non-nil
+	// error stops the evaluation, as if the call evaluated to it.
+	Return(fn *object.Function, result object.Object) *object.Error
+}
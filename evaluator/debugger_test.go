@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"reflect"
+	"testing"
+)
+
+// recordingDebugger records what the evaluator tells it, stopping the
+// evaluation at the statement of line stopAt.
+type recordingDebugger struct {
+	events []string
+	stopAt int
+}
+
+func (self *recordingDebugger) Statement(statement ast.Statement, env *object.Environment) *object.Error {
+	self.events = append(self.events, fmt.Sprintf("statement %d:%d", statement.Pos().Line, statement.Pos().Column))
+	if statement.Pos().Line == self.stopAt {
+		return &object.Error{Message: "stopped"}
+	}
+	return nil
+}
+
+func (self *recordingDebugger) Call(fn *object.Function, env *object.Environment) {
+	self.events = append(self.events, fmt.Sprintf("call %s %d", fn.Name, len(env.Bindings())))
+}
+
+func (self *recordingDebugger) Return(fn *object.Function, result object.Object) *object.Error {
+	self.events = append(self.events, fmt.Sprintf("return %s %s", fn.Name, result.Inspect()))
+	return nil
+}
+
+func TestDebugger(t *testing.T) {
+	tests := []struct {
+		input    string
+		stopAt   int
+		expected []string
+	}{
+		{
+			"let a = 1;\na + 1",
+			0,
+			[]string{"statement 1:1", "statement 2:1"},
+		},
+		{
+			"let double = fn(x) {\n  x * 2\n};\ndouble(2)",
+			0,
+			[]string{"statement 1:1", "statement 4:1", "call double 1", "statement 2:3", "return double 4"},
+		},
+		{
+			"let f = fn(x) { if (x) { return 1; } 2 };\nf(true)",
+			0,
+			[]string{"statement 1:1", "statement 2:1", "call f 1", "statement 1:17", "statement 1:26", "return f 1"},
+		},
+		{
+			"map([1], fn(x) { x })",
+			0,
+			[]string{"statement 1:1", "call  1", "statement 1:18", "return  1"},
+		},
+		{
+			"let a = 1;\nlet b = 2;\nlet c = 3;",
+			2,
+			[]string{"statement 1:1", "statement 2:1"},
+		},
+	}
+
+	for _, tt := range tests {
+		recorder := &recordingDebugger{stopAt: tt.stopAt}
+		evaluated := testEvalWithOptions(tt.input, Options{Debugger: recorder})
+
+		if !reflect.DeepEqual(recorder.events, tt.expected) {
+			t.Errorf("%q: events wrong.\ngot=%q\nwant=%q", tt.input, recorder.events, tt.expected)
+		}
+
+		if tt.stopAt != 0 {
+			if err, ok := evaluated.(*object.Error); !ok || err.Message != "stopped" {
+				t.Errorf("%q: the evaluation was not stopped, got=%v", tt.input, evaluated)
+			}
+		}
+	}
+}
+
+func TestFunctionsAreNamedAfterTheirLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(a, b) { a + b }; add", "add"},
+		{"let add = fn(a, b) { a + b }; let plus = add; plus", "add"},
+		{"fn() { 1 }", ""},
+		{"let make = fn() { fn() { 1 } }; make()", ""},
+	}
+
+	for _, tt := range tests {
+		var name string
+		switch fn := testEval(tt.input).(type) {
+		case *object.Function:
+			name = fn.Name
+		case *object.Closure:
+			name = fn.Fn.Name
+		default:
+			t.Errorf("%q: not a function", tt.input)
+			continue
+		}
+		if name != tt.expected {
+			t.Errorf("%q: name wrong. got=%q, want=%q", tt.input, name, tt.expected)
+		}
+	}
+}
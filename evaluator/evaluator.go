@@ -1,98 +1,167 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/Neal-C/interpreter-in-go/ast"
 	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/resolver"
+	"io"
+	"os"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
+// Resolve resolves program against the scope of env, the environment it will
+// be evaluated in, and returns the identifiers it found bound nowhere, such as
+// "identifier not found: x". Resolving writes the slots into the syntax tree:
+// a program is resolved once, then evaluated any number of times, possibly at
+// the same time. Unresolved identifiers still evaluate, by looking up their
+// names, as they do in a program that was never resolved.
+func Resolve(program *ast.Program, env *object.Environment) []string {
+	return resolver.Resolve(program, env.Scope(), builtins)
+}
+
+// Options are what an evaluation prints to and tells of its progress.
+type Options struct {
+	Output   io.Writer // where puts prints, standard output when nil
+	Tracer   Tracer    // told of each node evaluated, none when nil
+	Debugger Debugger  // told of each statement run and function called, none when nil
+}
+
+// evaluation is a call of EvalWithOptions and what it evaluates in turn, functions
+// called back by builtins included. Evaluations running at the same time share
+// none of their state.
+type evaluation struct {
+	output   io.Writer
+	tracer   Tracer
+	debugger Debugger
+
+	// traced is the node traceEval told the tracer of, which eval then
+	// evaluates without telling it again
+	traced ast.Node
+	// reported is the last error the tracer was told of, which the nodes and
+	// calls it propagates through do not tell it again
+	reported *object.Error
+}
+
+// Eval evaluates node in env, puts printing to standard output. The
+// identifiers of a program resolved against the scope of env find their
+// values by slot, the others by name.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return EvalWithOptions(node, env, Options{})
+}
+
+// EvalWithOptions evaluates node in env as Eval does, puts printing to the
+// output of options, its tracer and debugger told of the evaluation.
+func EvalWithOptions(node ast.Node, env *object.Environment, options Options) object.Object {
+	self := &evaluation{output: options.Output, tracer: options.Tracer, debugger: options.Debugger}
+	if self.output == nil {
+		self.output = os.Stdout
+	}
+
+	return self.eval(node, env)
+}
+
+func (self *evaluation) eval(node ast.Node, env *object.Environment) object.Object {
+	if self.tracer != nil {
+		if node != self.traced {
+			return self.traceEval(node, env)
+		}
+		self.traced = nil
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return self.evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return self.eval(node.Expression, env)
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return object.NewInteger(node.Value)
 	case *ast.Boolean:
 		return nativeNodeToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		rightHandSign := Eval(node.Right, env)
+		rightHandSign := self.eval(node.Right, env)
 		if isError(rightHandSign) {
 			return rightHandSign
 		}
 		return evalPrefixExpression(node.Operator, rightHandSign)
 	case *ast.InfixExpression:
-		leftHandSign := Eval(node.Left, env)
+		leftHandSign := self.eval(node.Left, env)
 		if isError(leftHandSign) {
 			return leftHandSign
 		}
-		rightHandSign := Eval(node.Right, env)
+		rightHandSign := self.eval(node.Right, env)
 		if isError(rightHandSign) {
 			return rightHandSign
 		}
 		return evalInfixExpression(node.Operator, leftHandSign, rightHandSign)
 	case *ast.BlockStatement:
-		return evalBlockStatements(node, env)
+		return self.evalBlockStatements(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return self.evalIfExpression(node, env)
 	case *ast.ReturnStatement:
-		value := Eval(node.ReturnValue, env)
+		value := self.eval(node.ReturnValue, env)
 		if isError(value) {
 			return value
 		}
 		return &object.ReturnValue{Value: value}
 	case *ast.LetStatement:
-		value := Eval(node.Value, env)
+		value := self.eval(node.Value, env)
 		if isError(value) {
 			return value
 		}
-		env.Set(node.Name.Value, value)
+		if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+			// as the compiler does, a function is named after its let
+			value.(*object.Function).Name = node.Name.Value
+		}
+		bind(env, node.Name, value)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		env.Capture()
+		return &object.Function{Parameters: params, Body: body, Env: env, Scope: node.Scope}
 	case *ast.CallExpression:
-		fnCall := Eval(node.Function, env)
+		fnCall := self.eval(node.Function, env)
 
 		if isError(fnCall) {
 			return fnCall
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		args := self.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(fnCall, args)
+		return self.applyFunction(fnCall, args)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return self.evalInterpolatedString(node, env)
 
 	case *ast.ArrayLiteral:
 
-		elements := evalExpressions(node.Elements, env)
+		elements := self.evalExpressions(node.Elements, env)
 
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 
-		return &object.Array{Elements: elements}
+		return object.NewArray(elements)
 	case *ast.IndexExpression:
 
-		left := Eval(node.Left, env)
+		left := self.eval(node.Left, env)
 
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(node.Index, env)
+		index := self.eval(node.Index, env)
 
 		if isError(index) {
 			return index
@@ -100,17 +169,23 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return evalIndexExpression(left, index)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return self.evalHashLiteral(node, env)
 	}
 
 	return nil
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (self *evaluation) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range stmts {
-		result = Eval(stmt, env)
+		if self.debugger != nil {
+			if stopped := self.debugger.Statement(stmt, env); stopped != nil {
+				return stopped
+			}
+		}
+
+		result = self.eval(stmt, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -164,7 +239,7 @@ func evalMinusPrefixOperatorExpression(rightHandSign object.Object) object.Objec
 	}
 
 	value := rightHandSign.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return object.NewInteger(-value)
 }
 
 func evalInfixExpression(operator string, leftHandSign object.Object, rightHandSign object.Object) object.Object {
@@ -175,6 +250,8 @@ func evalInfixExpression(operator string, leftHandSign object.Object, rightHandS
 		return nativeNodeToBooleanObject(leftHandSign == rightHandSign)
 	case operator == "!=":
 		return nativeNodeToBooleanObject(leftHandSign != rightHandSign)
+	case leftHandSign.Type() == object.STRING_OBJ && rightHandSign.Type() == object.INTEGER_OBJ:
+		return evalStringInfixExpression(operator, leftHandSign, rightHandSign)
 	case leftHandSign.Type() != rightHandSign.Type():
 		return newError("type mismatch: %s %s %s", leftHandSign.Type(), operator, rightHandSign.Type())
 	case leftHandSign.Type() == object.STRING_OBJ && rightHandSign.Type() == object.STRING_OBJ:
@@ -191,13 +268,16 @@ func evalIntegerInfixExpression(operator string, leftHandSign object.Object, rig
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
+		return object.NewInteger(leftValue + rightValue)
 	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
+		return object.NewInteger(leftValue - rightValue)
 	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
+		return object.NewInteger(leftValue * rightValue)
 	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+		if rightValue == 0 {
+			return newError("division by zero: %d / %d", leftValue, rightValue)
+		}
+		return object.NewInteger(leftValue / rightValue)
 	case "<":
 		return nativeNodeToBooleanObject(leftValue < rightValue)
 	case ">":
@@ -211,17 +291,17 @@ func evalIntegerInfixExpression(operator string, leftHandSign object.Object, rig
 	}
 }
 
-func evalIfExpression(ifExpr *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ifExpr.Condition, env)
+func (self *evaluation) evalIfExpression(ifExpr *ast.IfExpression, env *object.Environment) object.Object {
+	condition := self.eval(ifExpr.Condition, env)
 
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ifExpr.Consequence, env)
+		return self.eval(ifExpr.Consequence, env)
 	} else if ifExpr.Alternative != nil {
-		return Eval(ifExpr.Alternative, env)
+		return self.eval(ifExpr.Alternative, env)
 	} else {
 		return NULL
 	}
@@ -240,11 +320,17 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (self *evaluation) evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		if self.debugger != nil {
+			if stopped := self.debugger.Statement(statement, env); stopped != nil {
+				return stopped
+			}
+		}
+
+		result = self.eval(statement, env)
 
 		if result != nil {
 			resultType := result.Type()
@@ -254,6 +340,11 @@ func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) obj
 		}
 	}
 
+	if result == nil {
+		// an empty block, or one ending with a let, is null as in the vm
+		return NULL
+	}
+
 	return result
 }
 
@@ -269,6 +360,13 @@ func isError(obj object.Object) bool {
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Scope != nil {
+		if value, ok := env.GetSlot(node.Scope, node.Depth, node.Slot); ok {
+			return value
+		}
+		// not bound yet, or not resolved for this environment: like before
+		// resolution, the name may still be bound further out
+	}
 
 	if value, ok := env.Get(node.Value); ok {
 		return value
@@ -280,11 +378,11 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
-func evalExpressions(expressions []ast.Expression, env *object.Environment) []object.Object {
+func (self *evaluation) evalExpressions(expressions []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, expr := range expressions {
-		evaluated := Eval(expr, env)
+		evaluated := self.eval(expr, env)
 
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -296,20 +394,45 @@ func evalExpressions(expressions []ast.Expression, env *object.Environment) []ob
 	return result
 }
 
-func applyFunction(fnCall object.Object, args []object.Object) object.Object {
+func (self *evaluation) applyFunction(fnCall object.Object, args []object.Object) object.Object {
+	if self.tracer == nil {
+		return self.apply(fnCall, args)
+	}
+
+	self.tracer.Call(fnCall, args)
+	result := self.apply(fnCall, args)
+	self.reportError(result)
+	self.tracer.Return(fnCall, result)
+	return result
+}
+
+func (self *evaluation) apply(fnCall object.Object, args []object.Object) object.Object {
 
 	switch fn := fnCall.(type) {
 
 	case *object.Function:
 
+		if len(args) < len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+
 		extendedEnv := extendFunctionEnv(fn, args)
+		if self.debugger != nil {
+			self.debugger.Call(fn, extendedEnv)
+		}
 
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := unwrapReturnValue(self.eval(fn.Body, extendedEnv))
+		if self.debugger != nil {
+			if stopped := self.debugger.Return(fn, evaluated); stopped != nil {
+				evaluated = stopped
+			}
+		}
+		extendedEnv.Release()
 
-		return unwrapReturnValue(evaluated)
+		return evaluated
 	case *object.Builtin:
 
-		return fn.Fn(args...)
+		return fn.Fn(self, args...)
 
 	default:
 
@@ -319,16 +442,38 @@ func applyFunction(fnCall object.Object, args []object.Object) object.Object {
 
 }
 
+// Call is the object.Runtime the evaluation hands to builtins: it applies fn
+// in the same evaluation, telling the same tracer and debugger.
+func (self *evaluation) Call(fn object.Object, args ...object.Object) object.Object {
+	return self.applyFunction(fn, args)
+}
+
+// Output is where the builtins called in the evaluation print.
+func (self *evaluation) Output() io.Writer {
+	return self.output
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+	env := object.NewScopedEnvironment(fn.Scope, fn.Env)
 
 	for paramIndex, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIndex])
+		bind(env, param, args[paramIndex])
 	}
 
 	return env
 }
 
+// bind binds the name of identifier in env, by slot when it was resolved to
+// the scope of env.
+func bind(env *object.Environment, identifier *ast.Identifier, value object.Object) {
+	if identifier.Scope != nil && identifier.Scope == env.Scope() {
+		env.SetSlot(identifier.Slot, value)
+		return
+	}
+
+	env.Set(identifier.Value, value)
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -338,19 +483,41 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func evalStringInfixExpression(operator string, leftHandSign object.Object, rightHandSign object.Object) object.Object {
-	if operator != "+" {
+	switch {
+	case operator == "+" && rightHandSign.Type() == object.STRING_OBJ:
+		leftValue := leftHandSign.(*object.String).Value
+		rightValue := rightHandSign.(*object.String).Value
+		return &object.String{Value: leftValue + rightValue}
+	case operator == "*" && rightHandSign.Type() == object.INTEGER_OBJ:
+		return repeatString(leftHandSign.(*object.String), rightHandSign.(*object.Integer))
+	case rightHandSign.Type() != object.STRING_OBJ:
+		return newError("type mismatch: %s %s %s", leftHandSign.Type(), operator, rightHandSign.Type())
+	default:
 		return newError("unknown operator: %s %s %s", leftHandSign.Type(), operator, rightHandSign.Type())
 	}
+}
+
+func (self *evaluation) evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		evaluated := self.eval(part, env)
+		if isError(evaluated) {
+			return evaluated
+		}
+
+		out.WriteString(evaluated.Inspect())
+	}
 
-	leftValue := leftHandSign.(*object.String).Value
-	rightValue := rightHandSign.(*object.String).Value
-	return &object.String{Value: leftValue + rightValue}
+	return &object.String{Value: out.String()}
 }
 
 func evalIndexExpression(left object.Object, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -362,21 +529,32 @@ func evalArrayIndexExpression(array object.Object, index object.Object) object.O
 
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	maxIndexBoundary := int64(len(arrayObject.Elements) - 1)
+	maxIndexBoundary := int64(arrayObject.Len() - 1)
 
 	if idx < 0 || idx > maxIndexBoundary {
 		return NULL
 	}
 
-	return arrayObject.Elements[idx]
+	return arrayObject.Get(int(idx))
 
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func evalStringIndexExpression(str object.Object, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= int64(len(runes)) {
+		return NULL
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+func (self *evaluation) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := &object.Hash{}
 
 	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+		key := self.eval(keyNode, env)
 
 		if isError(key) {
 			return key
@@ -388,18 +566,16 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := self.eval(valueNode, env)
 
 		if isError(value) {
 			return value
 		}
 
-		hashKey := hashable.HashKey()
-
-		pairs[hashKey] = object.HashPair{Key: key, Value: value}
+		hash = hash.Set(hashable.HashKey(), object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(left object.Object, index object.Object) object.Object {
@@ -411,7 +587,7 @@ func evalHashIndexExpression(left object.Object, index object.Object) object.Obj
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 
 	if !ok {
 		return NULL
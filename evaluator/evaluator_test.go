@@ -1,9 +1,12 @@
 package evaluator
 
 import (
+	"github.com/Neal-C/interpreter-in-go/ast"
 	"github.com/Neal-C/interpreter-in-go/lexer"
 	"github.com/Neal-C/interpreter-in-go/object"
 	"github.com/Neal-C/interpreter-in-go/parser"
+	"io"
+	"sync"
 	"testing"
 )
 
@@ -35,13 +38,76 @@ func TestEvalIntegerExpression(t *testing.T) {
 
 }
 
-func testEval(input string) object.Object {
+// testEval evaluates input with the backend under test, see TestSuiteOnVM.
+var testEval = testEvalTreeWalking
+
+func testEvalTreeWalking(input string) object.Object {
+	return testEvalWithOptions(input, Options{})
+}
+
+// testEvalPrinting evaluates input as testEval does, puts printing to out.
+var testEvalPrinting = testEvalPrintingTreeWalking
+
+func testEvalPrintingTreeWalking(input string, out io.Writer) object.Object {
+	return testEvalWithOptions(input, Options{Output: out})
+}
+
+// testEvalWithOptions evaluates input with the tree-walking evaluator, which
+// alone takes a tracer and a debugger.
+func testEvalWithOptions(input string, options Options) object.Object {
 	myLexer := lexer.New(input)
 	myParser := parser.New(myLexer)
 	program := myParser.ParseProgram()
 	env := object.NewEnvironment()
+	Resolve(program, env)
+
+	return EvalWithOptions(program, env, options)
+}
+
+// testEvalLines evaluates inputs one after the other in a single global
+// environment, as the REPL does, with the backend under test.
+var testEvalLines = testEvalLinesTreeWalking
+
+func testEvalLinesTreeWalking(inputs []string) []object.Object {
+	env := object.NewEnvironment()
+
+	var results []object.Object
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		Resolve(program, env)
+		results = append(results, Eval(program, env))
+	}
+
+	return results
+}
+
+// testEvalConcurrently parses input once and evaluates it n times at the same
+// time, each in a global environment of its own, with the backend under test.
+var testEvalConcurrently = testEvalConcurrentlyTreeWalking
+
+func testEvalConcurrentlyTreeWalking(input string, n int) []object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	Resolve(program, object.NewEnvironment())
+
+	return concurrently(n, func() object.Object {
+		return Eval(program, object.NewEnvironment())
+	})
+}
+
+// concurrently calls eval n times at the same time and returns its results.
+func concurrently(n int, eval func() object.Object) []object.Object {
+	var wait sync.WaitGroup
+	results := make([]object.Object, n)
+	for i := range results {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			results[i] = eval()
+		}(i)
+	}
+	wait.Wait()
 
-	return Eval(program, env)
+	return results
 }
 
 func testIntegerObject(t *testing.T, evaluated object.Object, expected int64) bool {
@@ -140,6 +206,12 @@ func TestIfElseExpression(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) { }", nil},
+		{"let x = if (true) { }; x", nil},
+		{"let x = if (true) { let y = 1; }; x", nil},
+		{"let f = fn() { }; f()", nil},
+		{"let f = fn() { }; let x = f(); x", nil},
+		{"fn() { let a = 1; }()", nil},
 	}
 
 	for _, tt := range tableTests {
@@ -203,6 +275,8 @@ func TestErrorHandling(t *testing.T) {
 				return 1 
 				}`, "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar", "identifier not found: foobar"},
+		{"fn(x, y) { x }(1)", "wrong number of arguments. got=1, want=2"},
+		{"1 / 0", "division by zero: 1 / 0"},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
@@ -250,7 +324,7 @@ func TestFunctionObject(t *testing.T) {
 	input := `fn(x) { x + 2; };`
 	evaluated := testEval(input)
 
-	fn, ok := evaluated.(*object.Function)
+	fn, ok := functionLiteral(evaluated)
 
 	if !ok {
 		t.Fatalf("evaluated is not a function. got = %T (%v)", evaluated, evaluated)
@@ -271,6 +345,19 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+// functionLiteral returns the parameters and body of a function, the
+// evaluator's *object.Function or the vm's *object.Closure.
+func functionLiteral(obj object.Object) (*ast.FunctionLiteral, bool) {
+	switch fn := obj.(type) {
+	case *object.Function:
+		return &ast.FunctionLiteral{Parameters: fn.Parameters, Body: fn.Body}, true
+	case *object.Closure:
+		return fn.Fn.Literal, true
+	default:
+		return nil, false
+	}
+}
+
 func TestFunctionApplication(t *testing.T) {
 	tableTests := []struct {
 		input    string
@@ -320,6 +407,23 @@ func TestStringLiteral(t *testing.T) {
 
 }
 
+// Each evaluation of a string literal is a new string, which == tells apart.
+func TestStringLiteralIdentity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`let s = "a"; s == s`, true},
+		{`"a" == "a"`, false},
+		{`let f = fn() { "a" }; f() == f()`, false},
+		{`let f = fn() { "a" }; f() != f()`, true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -337,6 +441,102 @@ func TestStringConcatenation(t *testing.T) {
 
 }
 
+func TestInterpolatedStrings(t *testing.T) {
+	tableTests := []struct {
+		input    string
+		expected any
+	}{
+		{`"plain"`, "plain"},
+		{`let user = {"name": "Ada"}; let items = [1, 2];
+		  "Hello ${user["name"]}, you have ${len(items)} items"`, "Hello Ada, you have 2 items"},
+		{`"${1 + 2}${true}${[1, "a"]}"`, "3true[1, a]"},
+		{`"outer ${"inner ${1 * 2}"}"`, "outer inner 2"},
+		{`"${nope}"`, errorMessage("identifier not found: nope")},
+	}
+
+	for _, tt := range tableTests {
+		evaluated := testEval(tt.input)
+		testStringOrError(t, evaluated, tt.expected)
+	}
+}
+
+func TestStringRepetition(t *testing.T) {
+	tableTests := []struct {
+		input    string
+		expected any
+	}{
+		{`"ab" * 3`, "ababab"},
+		{`"ab" * 0`, ""},
+		{`"-" * 2 + "|"`, "--|"},
+		{`"ab" * -1`, errorMessage("negative repeat count: -1")},
+		{`"ab" * 9223372036854775807`, errorMessage("repeat count too large: 9223372036854775807")},
+		{`"" * 9223372036854775807`, ""},
+		{`"ab" - 1`, errorMessage("type mismatch: STRING - INTEGER")},
+		{`"ab" * "c"`, errorMessage("unknown operator: STRING * STRING")},
+	}
+
+	for _, tt := range tableTests {
+		evaluated := testEval(tt.input)
+		testStringOrError(t, evaluated, tt.expected)
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tableTests := []struct {
+		input    string
+		expected any
+	}{
+		{`"monkey"[0]`, "m"},
+		{`"monkey"[5]`, "y"},
+		{`let s = "héllo"; s[1]`, "é"},
+		{`"monkey"[6]`, nil},
+		{`"monkey"[-1]`, nil},
+	}
+
+	for _, tt := range tableTests {
+		evaluated := testEval(tt.input)
+
+		if tt.expected == nil {
+			testNullObject(t, evaluated)
+		} else {
+			testStringOrError(t, evaluated, tt.expected)
+		}
+	}
+}
+
+// errorMessage marks an expected value as the message of an *object.Error.
+type errorMessage string
+
+func testStringOrError(t *testing.T, evaluated object.Object, expected any) bool {
+	if message, ok := expected.(errorMessage); ok {
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned, got = %T (%v)", evaluated, evaluated)
+			return false
+		}
+
+		if errObj.Message != string(message) {
+			t.Errorf("wrong error message, expected = %q, got = %q", message, errObj.Message)
+			return false
+		}
+
+		return true
+	}
+
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Errorf("evaluated is not *object.String, got = %T (%v)", evaluated, evaluated)
+		return false
+	}
+
+	if str.Value != expected {
+		t.Errorf("str.Value has the wrong value, got = %q, want = %q", str.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tableTests := []struct {
 		input    string
@@ -381,13 +581,13 @@ func TestArrayLiteral(t *testing.T) {
 		t.Fatalf("evaluated is not a *object.Array, got = %T (%v)", evaluated, evaluated)
 	}
 
-	if len(result.Elements) != 3 {
-		t.Fatalf("array has wrong numbers of elements, got %d", len(result.Elements))
+	if result.Len() != 3 {
+		t.Fatalf("array has wrong numbers of elements, got %d", result.Len())
 	}
 
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
+	testIntegerObject(t, result.Get(0), 1)
+	testIntegerObject(t, result.Get(1), 4)
+	testIntegerObject(t, result.Get(2), 6)
 }
 
 func TestArrayIndexExpressions(t *testing.T) {
@@ -468,15 +668,15 @@ func TestHashLiterals(t *testing.T) {
 		FALSE.HashKey():                            6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has a wrong number of Paris. got %d , want %d", len(result.Pairs), len(expected))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has a wrong number of Paris. got %d , want %d", result.Len(), len(expected))
 	}
 
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 
 		if !ok {
-			t.Errorf("no pair for given key in result.Pairs")
+			t.Errorf("no pair for given key in result")
 		}
 
 		testIntegerObject(t, pair.Value, expectedValue)
@@ -516,3 +716,106 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestResolvedScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"let a = 1; let f = fn() { a }; let a = 2; f()", 2},
+		{"let f = fn() { later }; let later = 5; f()", 5},
+		{"let f = fn() { let g = fn() { x }; let x = 3; g() }; f()", 3},
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f()", 3},
+		{"let f = fn(x) { if (x > 1) { let y = x; }; y }; f(1)", errorMessage("identifier not found: y")},
+		{"let f = fn(x) { if (x > 1) { let y = x; }; y }; f(2)", 2},
+		{"let len = fn(x) { 42 }; len([])", 42},
+		{"let counter = fn(n) { fn() { n + 1 } }; let a = counter(1); let b = counter(10); a() + b()", 13},
+		{"let f = fn(a, a) { a }; f(1, 2)", 2},
+		{"fn() { let x = 1; let f = fn() { x }; let x = 2; f() }()", 2},
+		{"fn() { let f = fn() { g() }; let g = fn() { 1 }; f() }()", 1},
+		{"fn() { let f = fn() { g() }; let a = f(); let g = fn() { 1 }; a }()", errorMessage("identifier not found: g")},
+		{"let f = fn(n) { fn() { let g = fn() { n }; let n = n + 1; g() } }; f(1)()", 2},
+		{"let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } }; depth(100000)", 100000},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testStringOrError(t, evaluated, expected)
+		}
+	}
+}
+
+func TestIncrementalGlobalEnvironment(t *testing.T) {
+	lines := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn() { later * 2 };", 0},
+		{"let later = 21;", 0},
+		{"f()", 42},
+		{"let later = 5; f()", 10},
+	}
+
+	inputs := make([]string, len(lines))
+	for i, line := range lines {
+		inputs[i] = line.input
+	}
+
+	for i, evaluated := range testEvalLines(inputs) {
+		if lines[i].expected != 0 {
+			testIntegerObject(t, evaluated, lines[i].expected)
+		}
+	}
+}
+
+func TestProgramEvaluatedInSeveralEnvironments(t *testing.T) {
+	input := "let a = 2; let f = fn(x) { let y = x * a; y }; f(21)"
+
+	for _, result := range testEvalConcurrently(input, 8) {
+		testIntegerObject(t, result, 42)
+	}
+}
+
+func TestProgramResolvedForAnotherEnvironment(t *testing.T) {
+	program := parser.New(lexer.New("let a = 2; let f = fn(x) { x * a }; f(21)")).ParseProgram()
+	Resolve(program, object.NewEnvironment())
+
+	prelude := object.NewEnvironment()
+	Eval(parser.New(lexer.New("let unrelated = 0;")).ParseProgram(), prelude)
+
+	// the globals of the program have no slots in the scope of the prelude,
+	// they are found by name
+	testIntegerObject(t, Eval(program, prelude), 42)
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; a + len([])", nil},
+		{"let f = fn() { later }; let later = 1;", nil},
+		{"x + fn(y) { x + y + z }(1)", []string{"identifier not found: x", "identifier not found: z"}},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		errors := Resolve(program, object.NewEnvironment())
+
+		if len(errors) != len(tt.expected) {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.input, errors, tt.expected)
+			continue
+		}
+		for i, message := range tt.expected {
+			if errors[i] != message {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.input, errors, tt.expected)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package evaluator
+
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/object"
+)
+
+// Tracer is told by the evaluator of each node it evaluates, each function it
+// calls, builtins included, and each error it creates.
+type Tracer interface {
+	// Enter is called before node is evaluated in env.
+	Enter(node ast.Node, env *object.Environment)
+	// Exit is called once node evaluated to result, nil for a statement
+	// without a value.
+	Exit(node ast.Node, result object.Object)
+	// Call is called before fn, a function or a builtin, is applied to args.
+	Call(fn object.Object, args []object.Object)
+	// Return is called once the call of fn evaluated to result.
+	Return(fn object.Object, result object.Object)
+	// Error is called when an error comes out of the node or call that
+	// created it, before it propagates.
+	Error(err *object.Error)
+}
+
+// traceEval evaluates node in env, telling the tracer before and after.
+// Keeping the tracing out of eval keeps its frame, and so the untraced
+// recursion, as small as without a tracer.
+func (self *evaluation) traceEval(node ast.Node, env *object.Environment) object.Object {
+	self.tracer.Enter(node, env)
+	self.traced = node
+	result := self.eval(node, env)
+	self.reportError(result)
+	self.tracer.Exit(node, result)
+	return result
+}
+
+// reportError tells the tracer of result when it is an error it was not told
+// of yet, the first node or call it comes out of.
+func (self *evaluation) reportError(result object.Object) {
+	if err, ok := result.(*object.Error); ok && err != self.reported {
+		self.reported = err
+		self.tracer.Error(err)
+	}
+}
@@ -0,0 +1,113 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingTracer records what the evaluator tells it, nodes by their Go type.
+type recordingTracer struct {
+	events []string
+}
+
+func (self *recordingTracer) Enter(node ast.Node, env *object.Environment) {
+	self.events = append(self.events, fmt.Sprintf("enter %s", kind(node)))
+}
+
+func (self *recordingTracer) Exit(node ast.Node, result object.Object) {
+	value := "nil"
+	if result != nil {
+		value = result.Inspect()
+	}
+	self.events = append(self.events, fmt.Sprintf("exit %s %s", kind(node), value))
+}
+
+func (self *recordingTracer) Call(fn object.Object, args []object.Object) {
+	self.events = append(self.events, fmt.Sprintf("call %s %d", fn.Type(), len(args)))
+}
+
+func (self *recordingTracer) Return(fn object.Object, result object.Object) {
+	self.events = append(self.events, fmt.Sprintf("return %s %s", fn.Type(), result.Inspect()))
+}
+
+func (self *recordingTracer) Error(err *object.Error) {
+	self.events = append(self.events, "error "+err.Message)
+}
+
+func kind(node ast.Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+func TestTracer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"1 + 2",
+			[]string{
+				"enter Program",
+				"enter ExpressionStatement",
+				"enter InfixExpression",
+				"enter IntegerLiteral", "exit IntegerLiteral 1",
+				"enter IntegerLiteral", "exit IntegerLiteral 2",
+				"exit InfixExpression 3",
+				"exit ExpressionStatement 3",
+				"exit Program 3",
+			},
+		},
+		{
+			"len(-true)",
+			[]string{
+				"enter Program",
+				"enter ExpressionStatement",
+				"enter CallExpression",
+				"enter Identifier", "exit Identifier builtin function",
+				"enter PrefixExpression",
+				"enter Boolean", "exit Boolean true",
+				"error unknown operator: -BOOLEAN",
+				"exit PrefixExpression ERROR: unknown operator: -BOOLEAN",
+				"exit CallExpression ERROR: unknown operator: -BOOLEAN",
+				"exit ExpressionStatement ERROR: unknown operator: -BOOLEAN",
+				"exit Program ERROR: unknown operator: -BOOLEAN",
+			},
+		},
+		{
+			"fn(x) { x }(len(\"ab\"))",
+			[]string{
+				"enter Program",
+				"enter ExpressionStatement",
+				"enter CallExpression",
+				"enter FunctionLiteral", "exit FunctionLiteral fn(x) {\nx\n}",
+				"enter CallExpression",
+				"enter Identifier", "exit Identifier builtin function",
+				"enter StringLiteral", "exit StringLiteral ab",
+				"call BUILTIN 1", "return BUILTIN 2",
+				"exit CallExpression 2",
+				"call FUNCTION 1",
+				"enter BlockStatement",
+				"enter ExpressionStatement",
+				"enter Identifier", "exit Identifier 2",
+				"exit ExpressionStatement 2",
+				"exit BlockStatement 2",
+				"return FUNCTION 2",
+				"exit CallExpression 2",
+				"exit ExpressionStatement 2",
+				"exit Program 2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		recorder := &recordingTracer{}
+		testEvalWithOptions(tt.input, Options{Tracer: recorder})
+
+		if !reflect.DeepEqual(recorder.events, tt.expected) {
+			t.Errorf("%q: events wrong.\ngot=%q\nwant=%q", tt.input, recorder.events, tt.expected)
+		}
+	}
+}
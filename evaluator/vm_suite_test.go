@@ -0,0 +1,180 @@
+package evaluator
+
+import (
+	"github.com/Neal-C/interpreter-in-go/compiler"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"github.com/Neal-C/interpreter-in-go/vm"
+	goast "go/ast"
+	goparser "go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// suiteOnVM is the evaluator's test suite that TestSuiteOnVM runs against the
+// bytecode compiler and virtual machine. TestSuiteCoversEveryTest checks that
+// every test of the package is either in it or in evaluatorOnly.
+var suiteOnVM = []struct {
+	name string
+	test func(*testing.T)
+}{
+	{"TestEvalIntegerExpression", TestEvalIntegerExpression},
+	{"TestEvalBooleanExpression", TestEvalBooleanExpression},
+	{"TestBangOperator", TestBangOperator},
+	{"TestIfElseExpression", TestIfElseExpression},
+	{"TestReturnStatement", TestReturnStatement},
+	{"TestErrorHandling", TestErrorHandling},
+	{"TestLetStatement", TestLetStatement},
+	{"TestFunctionObject", TestFunctionObject},
+	{"TestFunctionApplication", TestFunctionApplication},
+	{"TestFunctionsAreNamedAfterTheirLet", TestFunctionsAreNamedAfterTheirLet},
+	{"TestClosures", TestClosures},
+	{"TestResolvedScopes", TestResolvedScopes},
+	{"TestIncrementalGlobalEnvironment", TestIncrementalGlobalEnvironment},
+	{"TestProgramEvaluatedInSeveralEnvironments", TestProgramEvaluatedInSeveralEnvironments},
+	{"TestStringLiteral", TestStringLiteral},
+	{"TestStringLiteralIdentity", TestStringLiteralIdentity},
+	{"TestStringConcatenation", TestStringConcatenation},
+	{"TestInterpolatedStrings", TestInterpolatedStrings},
+	{"TestStringRepetition", TestStringRepetition},
+	{"TestStringIndexExpressions", TestStringIndexExpressions},
+	{"TestBuiltinFunctions", TestBuiltinFunctions},
+	{"TestArrayLiteral", TestArrayLiteral},
+	{"TestArrayIndexExpressions", TestArrayIndexExpressions},
+	{"TestHashLiterals", TestHashLiterals},
+	{"TestHashIndexExpressions", TestHashIndexExpressions},
+	{"TestHashBuiltins", TestHashBuiltins},
+	{"TestArrayBuiltins", TestArrayBuiltins},
+	{"TestHigherOrderBuiltins", TestHigherOrderBuiltins},
+	{"TestStringBuiltins", TestStringBuiltins},
+	{"TestFormatBuiltins", TestFormatBuiltins},
+	{"TestPutsOutput", TestPutsOutput},
+	{"TestPutsOutputOfEachEvaluation", TestPutsOutputOfEachEvaluation},
+}
+
+// evaluatorOnly are the tests of the package that do not apply to the vm,
+// and why.
+var evaluatorOnly = map[string]string{
+	"TestDebugger":      "the vm has no debugger hooks",
+	"TestTracer":        "the vm has no tracer hooks",
+	"TestResolveErrors": "the vm does not use the resolver",
+	"TestProgramResolvedForAnotherEnvironment": "the vm does not use the resolver",
+	"TestSuiteOnVM":            "it runs the suite on the vm",
+	"TestSuiteCoversEveryTest": "it checks the suite",
+}
+
+// TestSuiteOnVM runs the evaluator's test suite against the bytecode compiler
+// and virtual machine, which must behave exactly like the tree-walking evaluator.
+func TestSuiteOnVM(t *testing.T) {
+	testEval, testEvalPrinting, testEvalLines, testEvalConcurrently = testEvalOnVM, testEvalPrintingOnVM, testEvalLinesOnVM, testEvalConcurrentlyOnVM
+	defer func() {
+		testEval, testEvalPrinting, testEvalLines, testEvalConcurrently = testEvalTreeWalking, testEvalPrintingTreeWalking, testEvalLinesTreeWalking, testEvalConcurrentlyTreeWalking
+	}()
+
+	for _, tt := range suiteOnVM {
+		t.Run(strings.TrimPrefix(tt.name, "Test"), tt.test)
+	}
+}
+
+func TestSuiteCoversEveryTest(t *testing.T) {
+	paths, err := filepath.Glob("*_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inSuite := map[string]bool{}
+	for _, tt := range suiteOnVM {
+		inSuite[tt.name] = true
+	}
+
+	for _, path := range paths {
+		file, err := goparser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "Test") {
+				continue
+			}
+
+			_, skipped := evaluatorOnly[fn.Name.Name]
+			if inSuite[fn.Name.Name] == skipped {
+				t.Errorf("%s must be either in suiteOnVM or in evaluatorOnly", fn.Name.Name)
+			}
+		}
+	}
+}
+
+func testEvalOnVM(input string) object.Object {
+	return testEvalPrintingOnVM(input, os.Stdout)
+}
+
+func testEvalPrintingOnVM(input string, out io.Writer) object.Object {
+	bytecode, errObj := compileForVM(input, compiler.New(Builtins()))
+	if errObj != nil {
+		return errObj
+	}
+
+	machine := vm.New(bytecode)
+	machine.SetOutput(out)
+	return runOnVM(machine)
+}
+
+func testEvalLinesOnVM(inputs []string) []object.Object {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+
+	var results []object.Object
+	for _, input := range inputs {
+		bytecode, errObj := compileForVM(input, compiler.NewWithState(symbolTable, constants, Builtins()))
+		if errObj != nil {
+			results = append(results, errObj)
+			continue
+		}
+		constants = bytecode.Constants
+
+		results = append(results, runOnVM(vm.NewWithGlobalsStore(bytecode, globals)))
+	}
+
+	return results
+}
+
+func testEvalConcurrentlyOnVM(input string, n int) []object.Object {
+	bytecode, errObj := compileForVM(input, compiler.New(Builtins()))
+	if errObj != nil {
+		return []object.Object{errObj}
+	}
+
+	return concurrently(n, func() object.Object {
+		return runOnVM(vm.New(bytecode))
+	})
+}
+
+func compileForVM(input string, comp *compiler.Compiler) (*compiler.Bytecode, *object.Error) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	if err := comp.Compile(program); err != nil {
+		return nil, &object.Error{Message: err.Error()}
+	}
+
+	return comp.Bytecode(), nil
+}
+
+func runOnVM(machine *vm.VM) object.Object {
+	if err := machine.Run(); err != nil {
+		if errObj, ok := err.(*object.Error); ok {
+			return errObj
+		}
+		return &object.Error{Message: err.Error()}
+	}
+
+	return machine.LastPoppedStackElem()
+}
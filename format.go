@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/format"
+	"io"
+	"os"
+)
+
+// runFormat formats the files named in args, standard input when there are
+// none, and prints the result unless -w or -l say otherwise. Errors go to
+// errOut. The exit status is 2 when a file does not parse, 1 when one could
+// not be read or written.
+func runFormat(args []string, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of printing it")
+	list := flags.Bool("l", false, "list the files whose formatting differs")
+	flags.SetOutput(errOut)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	sources, ok := readSources(flags.Args(), errOut)
+	status := 0
+	if !ok {
+		status = 1
+	}
+
+	for _, source := range sources {
+		formatted, err := format.Source(source.text)
+		if err != nil {
+			fmt.Fprintf(errOut, "%s: %s\n", source.path, err)
+			status = 2
+			continue
+		}
+
+		if *list && formatted != source.text {
+			fmt.Fprintln(out, source.path)
+		}
+
+		switch {
+		case *write && source.path != "-":
+			if formatted != source.text {
+				if err := os.WriteFile(source.path, []byte(formatted), 0o644); err != nil {
+					fmt.Fprintf(errOut, "%s: %s\n", source.path, err)
+					status = max(status, 1)
+				}
+			}
+		case !*list:
+			io.WriteString(out, formatted)
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,542 @@
+// Package format prints Monkey programs in a canonical layout: a statement per
+// line, blocks indented by four spaces, calls, arrays and hashes broken one
+// element per line when they do not fit in 80 columns. Comments and the blank
+// lines separating statements are kept, and formatting formatted code leaves
+// it unchanged.
+package format
+
+import (
+	"errors"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	INDENT    = "    "
+	MAX_WIDTH = 80
+)
+
+// Source formats source, and fails when it does not parse.
+func Source(source string) (string, error) {
+	monkeyLexer := lexer.New(source)
+	monkeyParser := parser.New(monkeyLexer)
+	program := monkeyParser.ParseProgram()
+
+	if len(monkeyParser.Errors()) != 0 {
+		return "", errors.New(strings.Join(monkeyParser.Errors(), "\n"))
+	}
+
+	return Program(program, monkeyLexer.Comments()), nil
+}
+
+// Program formats program with its comments, as returned by the lexer that
+// read it. A comment goes before the statement or list element following it,
+// or at the end of the line of the one preceding it when it was there.
+func Program(program *ast.Program, comments []token.Token) string {
+	self := &printer{comments: comments, fresh: true}
+
+	self.statements(program.Statements, false)
+	self.flushComments(math.MaxInt)
+
+	if self.out.Len() > 0 {
+		self.out.WriteString("\n")
+	}
+
+	return self.out.String()
+}
+
+// Node formats node alone, without comments.
+func Node(node ast.Node) string {
+	self := &printer{fresh: true}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		self.statements(node.Statements, false)
+	case ast.Statement:
+		self.statement(node, true, nil)
+	case ast.Expression:
+		self.expression(node)
+	}
+
+	return self.out.String()
+}
+
+type printer struct {
+	out      strings.Builder
+	indent   int
+	comments []token.Token // not printed yet
+
+	line  int  // source line of what was printed last
+	fresh bool // nothing printed yet in the current block or list
+
+	// in flat mode everything is printed on one line, failing on what
+	// cannot be
+	flatMode bool
+	failed   bool
+}
+
+func (self *printer) write(s string) {
+	self.out.WriteString(s)
+}
+
+func (self *printer) column() int {
+	out := self.out.String()
+	return utf8.RuneCountInString(out[strings.LastIndexByte(out, '\n')+1:])
+}
+
+func (self *printer) fits(s string) bool {
+	return self.column()+utf8.RuneCountInString(s) <= MAX_WIDTH
+}
+
+// lineBreak starts a new line, after a blank one when the source had one
+// before line. Blocks and lists do not start with a blank line.
+func (self *printer) lineBreak(line int) {
+	if self.out.Len() == 0 {
+		return
+	}
+
+	self.write("\n")
+	if line > 0 && !self.fresh && self.line > 0 && line > self.line+1 {
+		self.write("\n")
+	}
+	self.write(strings.Repeat(INDENT, self.indent))
+}
+
+// flushComments prints the comments before offset.
+func (self *printer) flushComments(offset int) {
+	for len(self.comments) > 0 && self.comments[0].Start.Offset < offset {
+		comment := self.comments[0]
+		self.comments = self.comments[1:]
+		text := strings.TrimRight(comment.Literal, " \t\r")
+
+		if comment.Start.Line == self.line && self.out.Len() > 0 {
+			self.write(" " + text)
+			continue
+		}
+
+		self.lineBreak(comment.Start.Line)
+		self.write(text)
+		self.line = comment.Start.Line
+		self.fresh = false
+	}
+}
+
+// hasComments reports whether comments are left between start and end.
+func (self *printer) hasComments(start token.Position, end token.Position) bool {
+	for _, comment := range self.comments {
+		if comment.Start.Offset >= end.Offset {
+			return false
+		}
+		if comment.Start.Offset >= start.Offset {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flat prints node on one line, when it can be and holds no comment.
+func (self *printer) flat(node ast.Node) (string, bool) {
+	if self.hasComments(node.Pos(), node.End()) {
+		return "", false
+	}
+
+	sub := &printer{flatMode: true}
+	switch node := node.(type) {
+	case ast.Statement:
+		sub.statement(node, true, nil)
+	case ast.Expression:
+		sub.expression(node)
+	}
+
+	return sub.out.String(), !sub.failed
+}
+
+func (self *printer) statements(statements []ast.Statement, inBlock bool) {
+	for i, statement := range statements {
+		self.flushComments(statement.Pos().Offset)
+		self.lineBreak(statement.Pos().Line)
+
+		var next ast.Statement
+		if i+1 < len(statements) {
+			next = statements[i+1]
+		}
+		self.statement(statement, inBlock && next == nil, next)
+
+		self.line = statement.End().Line
+		self.fresh = false
+	}
+}
+
+// statement prints statement, last being true for the one giving its value to
+// a block, next being the statement after it if any.
+func (self *printer) statement(statement ast.Statement, last bool, next ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		self.write("let " + statement.Name.Value + " = ")
+		self.expression(statement.Value)
+		self.write(";")
+	case *ast.ReturnStatement:
+		self.write("return ")
+		self.expression(statement.ReturnValue)
+		self.write(";")
+	case *ast.ExpressionStatement:
+		// an if standing as a statement is never put on one line
+		if ifExpression, ok := statement.Expression.(*ast.IfExpression); ok {
+			self.failed = self.failed || self.flatMode
+			self.ifExpression(ifExpression)
+		} else {
+			self.expression(statement.Expression)
+		}
+
+		if !last && needsSemicolon(statement.Expression, next) {
+			self.write(";")
+		}
+	case *ast.BlockStatement:
+		self.block(statement)
+	}
+}
+
+// needsSemicolon reports whether an expression statement must end with a
+// semicolon. Those ending with a block only need one when the next statement
+// would otherwise continue them, as an operand or the arguments of a call.
+func needsSemicolon(expression ast.Expression, next ast.Statement) bool {
+	switch expression.(type) {
+	case *ast.IfExpression, *ast.FunctionLiteral:
+	default:
+		return true
+	}
+
+	statement, ok := next.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	switch statement.Token.Type {
+	case token.LPAREN, token.LBRACKET, token.MINUS, token.PLUS, token.ASTERISK, token.SLASH,
+		token.LT, token.GT, token.EQ, token.NOT_EQ:
+		return true
+	default:
+		return false
+	}
+}
+
+func (self *printer) block(block *ast.BlockStatement) {
+	if self.flatMode {
+		switch {
+		case len(block.Statements) == 0:
+			self.write("{}")
+		case len(block.Statements) == 1:
+			if _, ok := block.Statements[0].(*ast.ExpressionStatement); !ok {
+				self.failed = true
+			}
+			self.write("{ ")
+			self.statement(block.Statements[0], true, nil)
+			self.write(" }")
+		default:
+			self.failed = true
+		}
+		return
+	}
+
+	if len(block.Statements) == 0 && !self.hasComments(block.Pos(), block.End()) {
+		self.write("{}")
+		return
+	}
+
+	self.write("{")
+	self.line = block.Token.Start.Line
+	self.fresh = true
+	self.indent++
+
+	self.statements(block.Statements, true)
+	self.flushComments(block.Close.Start.Offset)
+
+	self.indent--
+	self.lineBreak(0)
+	self.write("}")
+	self.line = block.Close.Start.Line
+}
+
+func (self *printer) expression(expression ast.Expression) {
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		self.write(expression.Value)
+	case *ast.IntegerLiteral:
+		self.write(strconv.FormatInt(expression.Value, 10))
+	case *ast.Boolean:
+		self.write(strconv.FormatBool(expression.Value))
+	case *ast.StringLiteral:
+		self.write(`"` + expression.Value + `"`)
+	case *ast.InterpolatedString:
+		self.write(`"` + expression.Token.Literal + `"`)
+	case *ast.PrefixExpression:
+		self.write(expression.Operator)
+		self.operand(expression.Right, parser.PREFIX, false)
+	case *ast.InfixExpression:
+		precedence := precedences[expression.Operator]
+		self.operand(expression.Left, precedence, false)
+		self.write(" " + expression.Operator + " ")
+		self.operand(expression.Right, precedence, true)
+	case *ast.IfExpression:
+		if self.writeFlat(expression) {
+			return
+		}
+		self.ifExpression(expression)
+	case *ast.FunctionLiteral:
+		if self.writeFlat(expression) {
+			return
+		}
+		self.write("fn(" + parameters(expression.Parameters) + ") ")
+		self.block(expression.Body)
+	case *ast.CallExpression:
+		self.postfixOperand(expression.Function)
+		self.list("(", ")", expression.Close, expressionElements(expression.Arguments))
+	case *ast.ArrayLiteral:
+		self.list("[", "]", expression.Close, expressionElements(expression.Elements))
+	case *ast.IndexExpression:
+		self.postfixOperand(expression.Left)
+		self.write("[")
+		self.expression(expression.Index)
+		self.write("]")
+	case *ast.HashLiteral:
+		self.list("{", "}", expression.Close, pairElements(expression))
+	}
+}
+
+// writeFlat prints node on one line when it fits there, out of flat mode
+// where it is printed so anyway.
+func (self *printer) writeFlat(node ast.Node) bool {
+	if self.flatMode {
+		return false
+	}
+
+	flat, ok := self.flat(node)
+	if !ok || !self.fits(flat) {
+		return false
+	}
+
+	self.write(flat)
+	return true
+}
+
+func (self *printer) ifExpression(expression *ast.IfExpression) {
+	self.write("if (")
+	self.expression(expression.Condition)
+	self.write(") ")
+	self.block(expression.Consequence)
+
+	if expression.Alternative != nil {
+		self.write(" else ")
+		self.block(expression.Alternative)
+	}
+}
+
+func parameters(identifiers []*ast.Identifier) string {
+	names := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		names[i] = identifier.Value
+	}
+
+	return strings.Join(names, ", ")
+}
+
+var precedences = map[string]int{
+	"==": parser.EQUALS,
+	"!=": parser.EQUALS,
+	"<":  parser.LESSGREATER,
+	">":  parser.LESSGREATER,
+	"+":  parser.SUM,
+	"-":  parser.SUM,
+	"*":  parser.PRODUCT,
+	"/":  parser.PRODUCT,
+}
+
+// operand prints an operand of an operator of precedence, in parentheses when
+// it binds less tightly. Operators being left-associative, a right operand of
+// the same precedence needs them too.
+func (self *printer) operand(expression ast.Expression, precedence int, right bool) {
+	infix, ok := expression.(*ast.InfixExpression)
+	if !ok {
+		self.expression(expression)
+		return
+	}
+
+	operandPrecedence := precedences[infix.Operator]
+	if operandPrecedence < precedence || right && operandPrecedence == precedence {
+		self.write("(")
+		self.expression(expression)
+		self.write(")")
+		return
+	}
+
+	self.expression(expression)
+}
+
+// postfixOperand prints what is called or indexed, in parentheses when it is
+// an operation.
+func (self *printer) postfixOperand(expression ast.Expression) {
+	switch expression.(type) {
+	case *ast.InfixExpression, *ast.PrefixExpression:
+		self.write("(")
+		self.expression(expression)
+		self.write(")")
+	default:
+		self.expression(expression)
+	}
+}
+
+// element is an element of a list: an expression, or a pair of a hash.
+type element struct {
+	start token.Position
+	end   token.Position
+	last  ast.Expression // the expression the element ends with
+	print func(*printer)
+}
+
+func expressionElements(expressions []ast.Expression) []element {
+	elements := make([]element, len(expressions))
+	for i, expression := range expressions {
+		expression := expression
+		elements[i] = element{
+			start: expression.Pos(),
+			end:   expression.End(),
+			last:  expression,
+			print: func(self *printer) { self.expression(expression) },
+		}
+	}
+
+	return elements
+}
+
+// pairElements returns the pairs of hash in the order of the source.
+func pairElements(hash *ast.HashLiteral) []element {
+	var elements []element
+	for _, key := range hash.Keys() {
+		key, value := key, hash.Pairs[key]
+		elements = append(elements, element{
+			start: key.Pos(),
+			end:   value.End(),
+			last:  value,
+			print: func(self *printer) {
+				self.expression(key)
+				self.write(": ")
+				self.expression(value)
+			},
+		})
+	}
+
+	return elements
+}
+
+// list prints elements between open and close. They go on one line when they
+// fit, else a function ending the list may hang from the line of the others,
+// else they go one per line.
+func (self *printer) list(open string, close string, closeToken token.Token, elements []element) {
+	if len(elements) == 0 {
+		self.write(open + close)
+		return
+	}
+
+	if self.flatMode {
+		self.write(open)
+		self.flatElements(elements)
+		self.write(close)
+		return
+	}
+
+	if flat, ok := self.flatList(elements, closeToken); ok && self.fits(open+flat+close) {
+		self.write(open + flat + close)
+		return
+	}
+
+	if self.hangingList(open, close, elements) {
+		return
+	}
+
+	self.write(open)
+	self.fresh = true
+	self.indent++
+
+	for i, element := range elements {
+		self.flushComments(element.start.Offset)
+		self.lineBreak(0)
+		element.print(self)
+		if i+1 < len(elements) {
+			self.write(",")
+		}
+		self.line = element.end.Line
+		self.fresh = false
+	}
+	self.flushComments(closeToken.Start.Offset)
+
+	self.indent--
+	self.lineBreak(0)
+	self.write(close)
+	self.line = closeToken.Start.Line
+}
+
+func (self *printer) flatElements(elements []element) {
+	for i, element := range elements {
+		if i > 0 {
+			self.write(", ")
+		}
+		element.print(self)
+	}
+}
+
+func (self *printer) flatList(elements []element, closeToken token.Token) (string, bool) {
+	if len(elements) > 0 && self.hasComments(elements[0].start, closeToken.End) {
+		return "", false
+	}
+
+	sub := &printer{flatMode: true}
+	sub.flatElements(elements)
+
+	return sub.out.String(), !sub.failed
+}
+
+// hangingList prints a list ending with a function literal as
+//
+//	map(xs, fn(x) {
+//	    x * 2
+//	})
+//
+// when the elements before the function fit on one line.
+func (self *printer) hangingList(open string, close string, elements []element) bool {
+	last := elements[len(elements)-1]
+	function, ok := last.last.(*ast.FunctionLiteral)
+	if !ok || last.start != function.Pos() || self.hasComments(elements[0].start, function.Body.Token.End) {
+		return false
+	}
+
+	heading, ok := self.flatList(elements[:len(elements)-1], function.Body.Token)
+	if !ok {
+		return false
+	}
+	if len(elements) > 1 {
+		heading += ", "
+	}
+	heading += "fn(" + parameters(function.Parameters) + ") {"
+
+	if !self.fits(open + heading) {
+		return false
+	}
+
+	self.write(open)
+	self.flatElements(elements[:len(elements)-1])
+	if len(elements) > 1 {
+		self.write(", ")
+	}
+	self.write("fn(" + parameters(function.Parameters) + ") ")
+	self.block(function.Body)
+	self.write(close)
+
+	return true
+}
@@ -0,0 +1,155 @@
+package format
+
+import (
+	"flag"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of testdata")
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{``, ``},
+		{`1 + 2 * 3`, "1 + 2 * 3;\n"},
+		{`(1 + 2) * 3`, "(1 + 2) * 3;\n"},
+		{`1 - (2 - 3)`, "1 - (2 - 3);\n"},
+		{`(1 - 2) - 3`, "1 - 2 - 3;\n"},
+		{`-(1 + 2)`, "-(1 + 2);\n"},
+		{`!(true == false)`, "!(true == false);\n"},
+		{`(-a)[0]`, "(-a)[0];\n"},
+		{`(a + b)(1)`, "(a + b)(1);\n"},
+		{`let f = fn(x,y){x+y}`, "let f = fn(x, y) { x + y };\n"},
+		{`let f = fn() { let a = 1; a }`, "let f = fn() {\n    let a = 1;\n    a\n};\n"},
+		{`if (a) { b }`, "if (a) {\n    b\n}\n"},
+		{`let a = if (b) { 1 } else { 2 };`, "let a = if (b) { 1 } else { 2 };\n"},
+		{"if (a) { b };\n(c)", "if (a) {\n    b\n};\nc;\n"},
+		{"if (a) { b }\n(c)", "if (a) { b }(c);\n"},
+		{"if (a) { b }\nc", "if (a) {\n    b\n}\nc;\n"},
+		{`{"b": 2, "a": 1}`, "{\"b\": 2, \"a\": 1};\n"},
+		{"a\n\n\n\nb", "a;\n\nb;\n"},
+		{"a // one\n// two\nb", "a; // one\n// two\nb;\n"},
+		{`"x is ${ x }"`, "\"x is ${ x }\";\n"},
+		{"#!/usr/bin/env monkey\nputs(args)", "#!/usr/bin/env monkey\nputs(args);\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source(tt.input)
+		if err != nil {
+			t.Fatalf("%q does not parse: %s", tt.input, err)
+		}
+
+		if formatted != tt.expected {
+			t.Errorf("wrong formatting of %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestSourceWithErrors(t *testing.T) {
+	if _, err := Source(`let = 1;`); err == nil {
+		t.Errorf("formatting a program that does not parse did not fail")
+	}
+}
+
+func TestNode(t *testing.T) {
+	program := parse(t, `let f = fn(a) { a * (2 + 3) };`)
+
+	if Node(program) != "let f = fn(a) { a * (2 + 3) };" {
+		t.Errorf("wrong formatting of the program: %q", Node(program))
+	}
+}
+
+// TestFixtures formats the programs of testdata and compares them to their
+// .golden files. Run with -update to rewrite those.
+func TestFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.mk"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no fixtures in testdata: %v", err)
+	}
+
+	for _, path := range paths {
+		source := readFile(t, path)
+
+		formatted, err := Source(source)
+		if err != nil {
+			t.Fatalf("%s does not parse: %s", path, err)
+		}
+
+		golden := strings.TrimSuffix(path, ".mk") + ".golden"
+		if *update {
+			if err := os.WriteFile(golden, []byte(formatted), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if expected := readFile(t, golden); formatted != expected {
+			t.Errorf("wrong formatting of %s.\nexpected=\n%s\ngot=\n%s", path, expected, formatted)
+		}
+	}
+}
+
+// TestIdempotency checks that formatting the fixtures and their formatted
+// versions changes nothing more, and keeps every comment.
+func TestIdempotency(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		source := readFile(t, path)
+
+		once, err := Source(source)
+		if err != nil {
+			t.Fatalf("%s does not parse: %s", path, err)
+		}
+
+		twice, err := Source(once)
+		if err != nil {
+			t.Fatalf("formatted %s does not parse: %s", path, err)
+		}
+
+		if twice != once {
+			t.Errorf("formatting %s again changed it.\nonce=\n%s\ntwice=\n%s", path, once, twice)
+		}
+
+		if countComments(source) != countComments(once) {
+			t.Errorf("formatting %s lost comments: %d, then %d", path, countComments(source), countComments(once))
+		}
+	}
+}
+
+func countComments(source string) int {
+	monkeyLexer := lexer.New(source)
+	parser.New(monkeyLexer).ParseProgram()
+
+	return len(monkeyLexer.Comments())
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	monkeyParser := parser.New(lexer.New(input))
+	program := monkeyParser.ParseProgram()
+
+	if len(monkeyParser.Errors()) != 0 {
+		t.Fatalf("parse errors for %q: %v", input, monkeyParser.Errors())
+	}
+
+	return program
+}
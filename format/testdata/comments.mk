@@ -0,0 +1,20 @@
+// leading comment
+
+// another one, after a blank line
+let a = 1; // trailing
+let f = fn(x) { // after the brace
+    // inside
+    x // the value
+    // before the closing brace
+};
+
+let list = [
+    1, // one
+    // before two
+    2
+];
+let empty = fn() {
+    // nothing here
+};
+puts(f(a), list, empty()) // last
+// at the end
@@ -0,0 +1,16 @@
+let   x=1;let y = x+2*3
+let z = (x + y) * (x - y) / -x;
+let w = x - (y - z); let v = (x - y) - z;
+let s = "a string"; let t = "x is ${x + 1}";
+if (x > y) { puts(x) } else { if (y > z) { puts(y) } }
+let max = fn(a,b){if(a>b){a}else{b}};
+let nested = {"key": [1, 2, {"deep": true}], "other": fn(a) { a }};
+let call = fn(f) { f }(fn(x) { x });
+let indexed = [1, 2, 3][0] + {"a": 1}["a"] + call(max)(1, 2);
+let g = fn() { return fn() { 1 }; };
+let h = if (x) { 1 }
+(2);
+let nothing = fn() {};
+let emptyList = []; let emptyHash = {};
+let long_variable_name = some_function(another_long_argument, yet_another_argument, 3);
+each(long_variable_name_for_the_list_of_things, fn(thing) { puts(thing); thing });
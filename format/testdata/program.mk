@@ -0,0 +1,16 @@
+// A sample program
+let add = fn(a, b) { a + b }; // adds
+
+
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  // recurse
+  fib(n - 1) + fib(n - 2)
+};
+let xs = map([1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23], fn(x) { x * 2 });
+let h = {"a": 1, "b": (2 + 3) * 4, "c": [1, 2, // two
+ 3]};
+puts(add(1, 2) - (3 - 4), -(1 + 2), f(1)[0], (-a)[0])
+let m = if (a > b) { a } else { b };
+if (true) { puts("yes") } else { puts("no") }
+let long = reduce(filter(range(100), fn(x) { x > 50 }), fn(acc, x) { let y = x * 2; acc + y }, 0);
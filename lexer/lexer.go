@@ -7,13 +7,26 @@ type Lexer struct {
 	position     int  // current position in the input (points to current character) // and where we last read
 	readPosition int  // current reading position in the input (after current character)
 	ch           byte // current char under examination
+	base         int  // offset of the input in the source it comes from
+	line         int  // line of the current char
+	column       int  // column of the current char
+	comments     []token.Token
 }
 
 const BLANK_WHITESPACE = ' '
 
 func New(input string) *Lexer {
+	return NewAt(input, token.Position{Line: 1, Column: 1})
+}
+
+// NewAt returns a lexer for input found at start in a larger source, so that
+// the positions of its tokens are those in that source.
+func NewAt(input string, start token.Position) *Lexer {
 	lexer := &Lexer{
-		input: input,
+		input:  input,
+		base:   start.Offset,
+		line:   start.Line,
+		column: start.Column - 1,
 	}
 
 	lexer.readChar()
@@ -22,6 +35,13 @@ func New(input string) *Lexer {
 }
 
 func (lexer *Lexer) readChar() {
+	if lexer.ch == '\n' {
+		lexer.line++
+		lexer.column = 1
+	} else {
+		lexer.column++
+	}
+
 	//  is to check whether we have reached the end of input.
 	if lexer.readPosition >= len(lexer.input) {
 		// 0 is the ASCII code for 'NUL' character
@@ -38,6 +58,11 @@ func (lexer *Lexer) readChar() {
 	lexer.readPosition++
 }
 
+// currentPosition is the position of the current char.
+func (lexer *Lexer) currentPosition() token.Position {
+	return token.Position{Offset: lexer.base + lexer.position, Line: lexer.line, Column: lexer.column}
+}
+
 func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{
 		Type:    tokenType,
@@ -49,6 +74,7 @@ func (lexer *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	lexer.skipWhitespace()
+	start := lexer.currentPosition()
 
 	switch lexer.ch {
 	case '=':
@@ -99,19 +125,24 @@ func (lexer *Lexer) NextToken() token.Token {
 	case ']':
 		tok = newToken(token.RBRACKET, lexer.ch)
 	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+		return token.Token{Type: token.EOF, Literal: "", Start: start, End: start}
 	case '"':
+		literal, interpolated := lexer.readString()
+		tok.Literal = literal
 		tok.Type = token.STRING
-		tok.Literal = lexer.readString()
+		if interpolated {
+			tok.Type = token.TEMPLATE
+		}
 	default:
 		if isLetter(lexer.ch) {
 			tok.Literal = lexer.readIdentifier()
 			tok.Type = token.LookUpIdent(tok.Literal)
+			tok.Start, tok.End = start, lexer.currentPosition()
 			return tok
 		} else if isDigit(lexer.ch) {
 			tok.Type = token.INT
 			tok.Literal = lexer.readNumber()
+			tok.Start, tok.End = start, lexer.currentPosition()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, lexer.ch)
@@ -119,6 +150,7 @@ func (lexer *Lexer) NextToken() token.Token {
 	}
 
 	lexer.readChar()
+	tok.Start, tok.End = start, lexer.currentPosition()
 	return tok
 }
 
@@ -135,10 +167,42 @@ func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
+// skipWhitespace skips blanks and comments, which are kept aside for the tools
+// that need them, such as the formatter.
 func (lexer *Lexer) skipWhitespace() {
-	for lexer.ch == BLANK_WHITESPACE || lexer.ch == '\t' || lexer.ch == '\n' || lexer.ch == '\r' {
+	for {
+		switch {
+		case lexer.ch == BLANK_WHITESPACE || lexer.ch == '\t' || lexer.ch == '\n' || lexer.ch == '\r':
+			lexer.readChar()
+		case lexer.ch == '/' && lexer.peekChar() == '/':
+			lexer.readComment()
+		case lexer.ch == '#' && lexer.peekChar() == '!' && lexer.base+lexer.position == 0:
+			// the #! line making a script executable
+			lexer.readComment()
+		default:
+			return
+		}
+	}
+}
+
+func (lexer *Lexer) readComment() {
+	start := lexer.currentPosition()
+	initialPosition := lexer.position
+	for lexer.ch != '\n' && lexer.ch != 0 {
 		lexer.readChar()
 	}
+
+	lexer.comments = append(lexer.comments, token.Token{
+		Type:    token.COMMENT,
+		Literal: lexer.input[initialPosition:lexer.position],
+		Start:   start,
+		End:     lexer.currentPosition(),
+	})
+}
+
+// Comments returns the comments skipped so far, in source order.
+func (lexer *Lexer) Comments() []token.Token {
+	return lexer.comments
 }
 
 func isDigit(ch byte) bool {
@@ -161,15 +225,94 @@ func (lexer *Lexer) peekChar() byte {
 	}
 }
 
-func (self *Lexer) readString() string {
+// readString reads up to the closing quote and reports whether the string
+// contains ${...} interpolations. Quotes inside an interpolation belong to the
+// embedded expression and do not end the string.
+func (self *Lexer) readString() (string, bool) {
 	position := self.position + 1
+	interpolated := false
 
 	for {
 		self.readChar()
+		if self.ch == '$' && self.peekChar() == '{' {
+			interpolated = true
+			self.readChar()
+			self.skipInterpolation()
+			if self.ch == 0 {
+				break
+			}
+			continue
+		}
 		if self.ch == '"' || self.ch == 0 {
 			break
 		}
 	}
 
-	return self.input[position:self.position]
+	return self.input[position:self.position], interpolated
+}
+
+// skipInterpolation advances from the opening brace of an interpolation to its
+// matching closing brace, or to the end of input when there is none.
+func (self *Lexer) skipInterpolation() {
+	depth := 1
+
+	for depth > 0 {
+		self.readChar()
+		switch self.ch {
+		case 0:
+			return
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			self.readString()
+			if self.ch == 0 {
+				return
+			}
+		}
+	}
+}
+
+// TemplatePart is a piece of an interpolated string: either literal text or
+// the source of an embedded ${...} expression.
+type TemplatePart struct {
+	Value        string
+	IsExpression bool
+	Offset       int // of the value in the literal
+}
+
+// TemplateParts splits the literal of a token.TEMPLATE token into its parts.
+// ok is false when an interpolation is not closed.
+func TemplateParts(literal string) (parts []TemplatePart, ok bool) {
+	lexer := New(literal)
+	start := 0
+
+	for lexer.ch != 0 {
+		if lexer.ch != '$' || lexer.peekChar() != '{' {
+			lexer.readChar()
+			continue
+		}
+
+		if start < lexer.position {
+			parts = append(parts, TemplatePart{Value: literal[start:lexer.position], Offset: start})
+		}
+
+		lexer.readChar()
+		expressionStart := lexer.position + 1
+		lexer.skipInterpolation()
+		if lexer.ch == 0 {
+			return parts, false
+		}
+
+		parts = append(parts, TemplatePart{Value: literal[expressionStart:lexer.position], IsExpression: true, Offset: expressionStart})
+		lexer.readChar()
+		start = lexer.position
+	}
+
+	if start < len(literal) {
+		parts = append(parts, TemplatePart{Value: literal[start:], Offset: start})
+	}
+
+	return parts, true
 }
@@ -437,3 +437,184 @@ return false;
 	}
 
 }
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"plain $ {}" "Hello ${user["name"]}!" "${ {"a": "}"}["a"] }" "${open"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "plain $ {}"},
+		{token.TEMPLATE, `Hello ${user["name"]}!`},
+		{token.TEMPLATE, `${ {"a": "}"}["a"] }`},
+		{token.TEMPLATE, `${open"`},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - Type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTemplateParts(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected []TemplatePart
+		ok       bool
+	}{
+		{
+			`Hello ${user["name"]}, you have ${len(items)} items`,
+			[]TemplatePart{
+				{Value: "Hello "},
+				{Value: `user["name"]`, IsExpression: true, Offset: 8},
+				{Value: ", you have ", Offset: 21},
+				{Value: "len(items)", IsExpression: true, Offset: 34},
+				{Value: " items", Offset: 45},
+			},
+			true,
+		},
+		{
+			`${a}${ {"b": 1}["b"] }`,
+			[]TemplatePart{
+				{Value: "a", IsExpression: true, Offset: 2},
+				{Value: ` {"b": 1}["b"] `, IsExpression: true, Offset: 6},
+			},
+			true,
+		},
+		{`cost: $5 ${x`, []TemplatePart{{Value: "cost: $5 "}}, false},
+	}
+
+	for i, tt := range tests {
+		parts, ok := TemplateParts(tt.literal)
+
+		if ok != tt.ok {
+			t.Fatalf("tests[%d] - ok wrong. expected=%t, got=%t", i, tt.ok, ok)
+		}
+
+		if len(parts) != len(tt.expected) {
+			t.Fatalf("tests[%d] - wrong number of parts. expected=%d, got=%d (%+v)", i, len(tt.expected), len(parts), parts)
+		}
+
+		for j, part := range parts {
+			if part != tt.expected[j] {
+				t.Errorf("tests[%d] - part %d wrong. expected=%+v, got=%+v", i, j, tt.expected[j], part)
+			}
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = \"a\";\n  x + 10\n"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedStart   token.Position
+		expectedEnd     token.Position
+	}{
+		{"let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"x", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{"=", token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"a", token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{";", token.Position{Offset: 11, Line: 1, Column: 12}, token.Position{Offset: 12, Line: 1, Column: 13}},
+		{"x", token.Position{Offset: 15, Line: 2, Column: 3}, token.Position{Offset: 16, Line: 2, Column: 4}},
+		{"+", token.Position{Offset: 17, Line: 2, Column: 5}, token.Position{Offset: 18, Line: 2, Column: 6}},
+		{"10", token.Position{Offset: 19, Line: 2, Column: 7}, token.Position{Offset: 21, Line: 2, Column: 9}},
+		{"", token.Position{Offset: 22, Line: 3, Column: 1}, token.Position{Offset: 22, Line: 3, Column: 1}},
+	}
+
+	lexer := New(input)
+
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Start != tt.expectedStart || tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - span of %q wrong. expected=%+v-%+v, got=%+v-%+v",
+				i, tok.Literal, tt.expectedStart, tt.expectedEnd, tok.Start, tok.End)
+		}
+	}
+
+	embedded := NewAt("y", token.Position{Offset: 30, Line: 4, Column: 9}).NextToken()
+	if embedded.Start != (token.Position{Offset: 30, Line: 4, Column: 9}) {
+		t.Errorf("token of a lexer started at 4:9 is at %+v", embedded.Start)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 1; // trailing
+x / 2 // after a slash`
+
+	expectedTypes := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.SLASH, token.INT, token.EOF,
+	}
+
+	lexer := New(input)
+
+	for i, expectedType := range expectedTypes {
+		tok := lexer.NextToken()
+		if tok.Type != expectedType {
+			t.Fatalf("tests[%d] - Type wrong. expected=%q, got=%q", i, expectedType, tok.Type)
+		}
+	}
+
+	expectedComments := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"// leading", 1, 1},
+		{"// trailing", 2, 12},
+		{"// after a slash", 3, 7},
+	}
+
+	comments := lexer.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expectedComments), len(comments))
+	}
+
+	for i, expected := range expectedComments {
+		comment := comments[i]
+		if comment.Type != token.COMMENT || comment.Literal != expected.literal {
+			t.Errorf("comments[%d] wrong. expected=%q, got=%q (%s)", i, expected.literal, comment.Literal, comment.Type)
+		}
+		if comment.Start.Line != expected.line || comment.Start.Column != expected.column {
+			t.Errorf("comments[%d] at %s, want=%d:%d", i, comment.Start, expected.line, expected.column)
+		}
+	}
+}
+
+func TestShebang(t *testing.T) {
+	lexer := New("#!/usr/bin/env monkey run\nlet x = 1;")
+
+	tok := lexer.NextToken()
+	if tok.Type != token.LET || tok.Start.Line != 2 || tok.Start.Column != 1 {
+		t.Fatalf("wrong first token. got=%q at %s", tok.Type, tok.Start)
+	}
+
+	comments := lexer.Comments()
+	if len(comments) != 1 || comments[0].Literal != "#!/usr/bin/env monkey run" {
+		t.Errorf("shebang not kept as a comment: %v", comments)
+	}
+
+	// elsewhere # is not a comment
+	lexer = New("\n#!")
+	if tok := lexer.NextToken(); tok.Type != token.ILLEGAL {
+		t.Errorf("# after the first line lexed as %q", tok.Type)
+	}
+}
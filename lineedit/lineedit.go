@@ -0,0 +1,498 @@
+// Package lineedit reads lines from a terminal the way shells do: the cursor
+// moves with the arrow keys and the usual control keys, earlier lines come
+// back with up and down or with a reverse search on Ctrl-R, and tab completes
+// the word before the cursor. History can be kept in a file across sessions.
+//
+// The terminal is put in raw mode only while a line is read. Lines are drawn
+// on a single row, the terminal wrapping those longer than its width.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MAX_HISTORY is the number of lines kept in the history, and in its file.
+const MAX_HISTORY = 1000
+
+// ErrInterrupted is returned by ReadLine when the line is abandoned with
+// Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// Completer returns the words that may replace line[start:cursor], the
+// offsets counting bytes.
+type Completer func(line string, cursor int) (start int, candidates []string)
+
+type Editor struct {
+	Complete Completer // nil when nothing completes
+
+	in          *bufio.Reader
+	out         io.Writer
+	terminal    *os.File // nil when the input is not a terminal
+	history     []string
+	historyFile string
+}
+
+// New returns an editor reading keys from in and drawing the line on out.
+func New(in io.Reader, out io.Writer) *Editor {
+	editor := &Editor{in: bufio.NewReader(in), out: out}
+
+	if file, ok := in.(*os.File); ok && IsTerminal(file) {
+		editor.terminal = file
+	}
+
+	return editor
+}
+
+// IsTerminal reports whether file is a terminal the editor can drive.
+func IsTerminal(file *os.File) bool {
+	_, err := getState(int(file.Fd()))
+	return err == nil
+}
+
+// History returns the lines of the history, the oldest first.
+func (self *Editor) History() []string {
+	return self.history
+}
+
+// SetHistoryFile loads the history kept in the file at path, if there is one,
+// and appends to it the lines added from then on.
+func (self *Editor) SetHistoryFile(path string) error {
+	self.historyFile = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for _, line := range lines {
+		self.addToHistory(line)
+	}
+
+	if len(lines) > MAX_HISTORY {
+		// keep the file from growing forever
+		return os.WriteFile(path, []byte(strings.Join(self.history, "\n")+"\n"), 0o600)
+	}
+
+	return nil
+}
+
+// AddHistory adds line to the history, unless it is blank or the same as the
+// last one.
+func (self *Editor) AddHistory(line string) {
+	if !self.addToHistory(line) || self.historyFile == "" {
+		return
+	}
+
+	file, err := os.OpenFile(self.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, line)
+}
+
+func (self *Editor) addToHistory(line string) bool {
+	if strings.TrimSpace(line) == "" || strings.Contains(line, "\n") {
+		return false
+	}
+	if len(self.history) > 0 && self.history[len(self.history)-1] == line {
+		return false
+	}
+
+	self.history = append(self.history, line)
+	if len(self.history) > MAX_HISTORY {
+		self.history = self.history[len(self.history)-MAX_HISTORY:]
+	}
+
+	return true
+}
+
+// state is the line being edited.
+type state struct {
+	prompt  string
+	buffer  []rune
+	cursor  int
+	history int    // index in the history of the line shown, its length for a new line
+	edited  []rune // the new line, while older ones are shown
+	tabbed  bool   // the previous key was a tab
+}
+
+// ReadLine prints prompt and returns the line typed after it, without its
+// end. It returns io.EOF on Ctrl-D or at the end of input, when the line is
+// empty, and ErrInterrupted on Ctrl-C.
+func (self *Editor) ReadLine(prompt string) (string, error) {
+	if self.terminal != nil {
+		fd := int(self.terminal.Fd())
+		original, err := makeRaw(fd)
+		if err != nil {
+			return "", err
+		}
+		defer setState(fd, original)
+	}
+
+	line := &state{prompt: prompt, history: len(self.history)}
+	self.refresh(line)
+
+	for {
+		key, err := self.readKey()
+		if err != nil {
+			if err == io.EOF && len(line.buffer) > 0 {
+				io.WriteString(self.out, "\n")
+				return string(line.buffer), nil
+			}
+			return "", err
+		}
+
+		if key == CTRL_R {
+			if key, err = self.search(line); err != nil {
+				return "", err
+			}
+		}
+
+		tabbed := key == TAB
+
+		switch key {
+		case ENTER, NEWLINE:
+			io.WriteString(self.out, "\n")
+			return string(line.buffer), nil
+		case CTRL_C:
+			io.WriteString(self.out, "^C\n")
+			return "", ErrInterrupted
+		case CTRL_D:
+			if len(line.buffer) == 0 {
+				io.WriteString(self.out, "\n")
+				return "", io.EOF
+			}
+			line.deleteAt(line.cursor)
+		case KEY_DELETE:
+			line.deleteAt(line.cursor)
+		case BACKSPACE, CTRL_H:
+			if line.cursor > 0 {
+				line.cursor--
+				line.deleteAt(line.cursor)
+			}
+		case KEY_LEFT, CTRL_B:
+			line.cursor = max(line.cursor-1, 0)
+		case KEY_RIGHT, CTRL_F:
+			line.cursor = min(line.cursor+1, len(line.buffer))
+		case KEY_HOME, CTRL_A:
+			line.cursor = 0
+		case KEY_END, CTRL_E:
+			line.cursor = len(line.buffer)
+		case KEY_WORD_LEFT:
+			line.cursor = line.wordStart()
+		case KEY_WORD_RIGHT:
+			line.cursor = line.wordEnd()
+		case CTRL_W:
+			start := line.wordStart()
+			line.buffer = append(line.buffer[:start], line.buffer[line.cursor:]...)
+			line.cursor = start
+		case CTRL_K:
+			line.buffer = line.buffer[:line.cursor]
+		case CTRL_U:
+			line.buffer = line.buffer[line.cursor:]
+			line.cursor = 0
+		case CTRL_L:
+			io.WriteString(self.out, "\x1b[H\x1b[2J")
+		case KEY_UP, CTRL_P:
+			self.showHistory(line, line.history-1)
+		case KEY_DOWN, CTRL_N:
+			self.showHistory(line, line.history+1)
+		case TAB:
+			self.complete(line)
+		default:
+			if key >= ' ' {
+				line.insert(key)
+			}
+		}
+
+		line.tabbed = tabbed
+		self.refresh(line)
+	}
+}
+
+// refresh draws the line again, and puts the cursor where it belongs. It
+// moves the cursor back rather than counting columns, the prompt may hold
+// escape sequences.
+func (self *Editor) refresh(line *state) {
+	fmt.Fprintf(self.out, "\r%s%s\x1b[K", line.prompt, string(line.buffer))
+	if back := len(line.buffer) - line.cursor; back > 0 {
+		fmt.Fprintf(self.out, "\x1b[%dD", back)
+	}
+}
+
+func (self *state) insert(r rune) {
+	self.buffer = append(self.buffer, 0)
+	copy(self.buffer[self.cursor+1:], self.buffer[self.cursor:])
+	self.buffer[self.cursor] = r
+	self.cursor++
+}
+
+func (self *state) deleteAt(i int) {
+	if i < len(self.buffer) {
+		self.buffer = append(self.buffer[:i], self.buffer[i+1:]...)
+	}
+}
+
+// wordStart is where the word before the cursor starts, blanks skipped.
+func (self *state) wordStart() int {
+	i := self.cursor
+	for i > 0 && unicode.IsSpace(self.buffer[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(self.buffer[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd is where the word after the cursor ends, blanks skipped.
+func (self *state) wordEnd() int {
+	i := self.cursor
+	for i < len(self.buffer) && unicode.IsSpace(self.buffer[i]) {
+		i++
+	}
+	for i < len(self.buffer) && !unicode.IsSpace(self.buffer[i]) {
+		i++
+	}
+	return i
+}
+
+func (self *Editor) showHistory(line *state, index int) {
+	if index < 0 || index > len(self.history) {
+		return
+	}
+
+	if line.history == len(self.history) {
+		line.edited = line.buffer
+	}
+
+	line.history = index
+	if index == len(self.history) {
+		line.buffer = line.edited
+	} else {
+		line.buffer = []rune(self.history[index])
+	}
+	line.cursor = len(line.buffer)
+}
+
+// search runs a reverse incremental search of the history, started by Ctrl-R.
+// The line found replaces the one edited, unless the search is cancelled
+// with Ctrl-G. It returns the key that ended the search, for ReadLine to act
+// on, or 0.
+func (self *Editor) search(line *state) (rune, error) {
+	original, originalCursor := line.buffer, line.cursor
+	prompt := line.prompt
+	defer func() { line.prompt = prompt }()
+
+	query := ""
+	found := len(self.history)
+
+	for {
+		line.prompt = fmt.Sprintf("(reverse-i-search)`%s': ", query)
+		self.refresh(line)
+
+		key, err := self.readKey()
+		if err != nil {
+			return 0, err
+		}
+
+		from := found
+		switch {
+		case key == CTRL_R:
+			from = found - 1
+		case key == BACKSPACE || key == CTRL_H:
+			if query == "" {
+				continue
+			}
+			_, size := utf8.DecodeLastRuneInString(query)
+			query = query[:len(query)-size]
+			from = len(self.history) - 1
+		case key == CTRL_G:
+			line.buffer, line.cursor = original, originalCursor
+			return 0, nil
+		case key >= ' ':
+			query += string(key)
+			from = min(found, len(self.history)-1)
+		default:
+			return key, nil
+		}
+
+		for i := from; i >= 0; i-- {
+			if position := strings.Index(self.history[i], query); position >= 0 {
+				found = i
+				line.buffer = []rune(self.history[i])
+				line.cursor = utf8.RuneCountInString(self.history[i][:position])
+				break
+			}
+		}
+	}
+}
+
+// complete completes the word before the cursor with the longest text the
+// candidates share. A second tab in a row lists the candidates.
+func (self *Editor) complete(line *state) {
+	if self.Complete == nil {
+		return
+	}
+
+	text := string(line.buffer)
+	cursor := len(string(line.buffer[:line.cursor]))
+	start, candidates := self.Complete(text, cursor)
+	if len(candidates) == 0 {
+		io.WriteString(self.out, "\a")
+		return
+	}
+
+	word := text[start:cursor]
+	prefix := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	if len(prefix) > len(word) {
+		completed := []rune(text[:start] + prefix)
+		line.buffer = append(completed, line.buffer[line.cursor:]...)
+		line.cursor = len(completed)
+		return
+	}
+
+	if line.tabbed {
+		fmt.Fprintf(self.out, "\n%s\n", strings.Join(candidates, "  "))
+		return
+	}
+
+	io.WriteString(self.out, "\a")
+}
+
+// The keys ReadLine tells apart. Control keys are their character, the keys
+// sending escape sequences are negative.
+const (
+	CTRL_A    = 1
+	CTRL_B    = 2
+	CTRL_C    = 3
+	CTRL_D    = 4
+	CTRL_E    = 5
+	CTRL_F    = 6
+	CTRL_G    = 7
+	CTRL_H    = 8
+	TAB       = 9
+	NEWLINE   = 10
+	CTRL_K    = 11
+	CTRL_L    = 12
+	ENTER     = 13
+	CTRL_N    = 14
+	CTRL_P    = 16
+	CTRL_R    = 18
+	CTRL_U    = 21
+	CTRL_W    = 23
+	ESCAPE    = 27
+	BACKSPACE = 127
+)
+
+const (
+	KEY_UNKNOWN = -(iota + 1)
+	KEY_UP
+	KEY_DOWN
+	KEY_RIGHT
+	KEY_LEFT
+	KEY_HOME
+	KEY_END
+	KEY_DELETE
+	KEY_WORD_LEFT
+	KEY_WORD_RIGHT
+)
+
+// readKey reads a key, decoding the escape sequences terminals send for the
+// keys that are not characters.
+func (self *Editor) readKey() (rune, error) {
+	r, _, err := self.in.ReadRune()
+	if err != nil || r != ESCAPE {
+		return r, err
+	}
+
+	next, _, err := self.in.ReadRune()
+	if err != nil {
+		return ESCAPE, nil
+	}
+
+	switch next {
+	case '[':
+		// parameters then a final character
+		var parameters []byte
+		for {
+			b, err := self.in.ReadByte()
+			if err != nil {
+				return KEY_UNKNOWN, nil
+			}
+			if '0' <= b && b <= '9' || b == ';' {
+				parameters = append(parameters, b)
+				continue
+			}
+			return csiKey(string(parameters), b), nil
+		}
+	case 'O':
+		b, err := self.in.ReadByte()
+		if err != nil {
+			return KEY_UNKNOWN, nil
+		}
+		return csiKey("", b), nil
+	case 'b':
+		return KEY_WORD_LEFT, nil
+	case 'f':
+		return KEY_WORD_RIGHT, nil
+	default:
+		return KEY_UNKNOWN, nil
+	}
+}
+
+func csiKey(parameters string, final byte) rune {
+	// the modifiers of Ctrl-arrows come after a semicolon
+	control := strings.HasSuffix(parameters, ";5")
+
+	switch final {
+	case 'A':
+		return KEY_UP
+	case 'B':
+		return KEY_DOWN
+	case 'C':
+		if control {
+			return KEY_WORD_RIGHT
+		}
+		return KEY_RIGHT
+	case 'D':
+		if control {
+			return KEY_WORD_LEFT
+		}
+		return KEY_LEFT
+	case 'H':
+		return KEY_HOME
+	case 'F':
+		return KEY_END
+	case '~':
+		switch parameters {
+		case "1", "7":
+			return KEY_HOME
+		case "4", "8":
+			return KEY_END
+		case "3":
+			return KEY_DELETE
+		}
+	}
+
+	return KEY_UNKNOWN
+}
@@ -0,0 +1,245 @@
+package lineedit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	UP    = "\x1b[A"
+	DOWN  = "\x1b[B"
+	RIGHT = "\x1b[C"
+	LEFT  = "\x1b[D"
+	HOME  = "\x1b[H"
+	END   = "\x1b[F"
+)
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"abc\n", "abc"},
+		{"abc" + LEFT + LEFT + "X\r", "aXbc"},
+		{"abc" + HOME + "X" + END + "Y\r", "XabcY"},
+		{"abc\x01X\x05Y\r", "XabcY"},
+		{"abc\x02\x02\x06Z\r", "abZc"},
+		{"abc\x7f\x7fd\r", "ad"},
+		{"abc" + LEFT + "\x1b[3~\r", "ab"},
+		{"abc" + HOME + "\x04\r", "bc"},
+		{"let x = 1\x17\x17y\r", "let x y"},
+		{"abcdef" + LEFT + LEFT + "\x0b\r", "abcd"},
+		{"abcdef" + LEFT + LEFT + "\x15\r", "ef"},
+		{"one two three\x1bb\x1bbX\x1bf\x1bfY\r", "one Xtwo threeY"},
+		{"one two\x1b[1;5DX\r", "one Xtwo"},
+		{"héllo" + LEFT + LEFT + LEFT + LEFT + "\x7f\r", "éllo"},
+		{"a\x1b[Zb\r", "ab"},
+		{"no end", "no end"},
+	}
+
+	for _, tt := range tests {
+		editor := New(strings.NewReader(tt.keys), io.Discard)
+
+		line, err := editor.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("%q: %s", tt.keys, err)
+		}
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. got=%q, want=%q", tt.keys, line, tt.expected)
+		}
+	}
+}
+
+func TestReadLineEnds(t *testing.T) {
+	editor := New(strings.NewReader("ab\x03\x04"), io.Discard)
+
+	if _, err := editor.ReadLine("> "); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("Ctrl-C returned %v, want=ErrInterrupted", err)
+	}
+	if _, err := editor.ReadLine("> "); err != io.EOF {
+		t.Errorf("Ctrl-D on an empty line returned %v, want=io.EOF", err)
+	}
+	if _, err := editor.ReadLine("> "); err != io.EOF {
+		t.Errorf("the end of input returned %v, want=io.EOF", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{UP + "\r", "third"},
+		{UP + UP + "\r", "second"},
+		{UP + UP + UP + UP + UP + "\r", "first"},
+		{"new" + UP + DOWN + "\r", "new"},
+		{UP + UP + DOWN + "\r", "third"},
+		{UP + "!\r", "third!"},
+		{"\x10\x10\r", "second"},
+		{"\x12s\r", "second"},
+		{"\x12ir\r", "third"},
+		{"\x12ir\x12\r", "first"},
+		{"\x12d\x12\x12\x12\r", "second"},
+		{"\x12co" + LEFT + "X\r", "sXecond"},
+		{"kept\x12fir\x07\r", "kept"},
+	}
+
+	for _, tt := range tests {
+		editor := New(strings.NewReader(tt.keys), io.Discard)
+		editor.AddHistory("first")
+		editor.AddHistory("second")
+		editor.AddHistory("third")
+
+		line, err := editor.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("%q: %s", tt.keys, err)
+		}
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. got=%q, want=%q", tt.keys, line, tt.expected)
+		}
+	}
+}
+
+func TestAddHistory(t *testing.T) {
+	editor := New(strings.NewReader(""), io.Discard)
+	for _, line := range []string{"a", "a", "  ", "b", "c\nd", "a"} {
+		editor.AddHistory(line)
+	}
+
+	if strings.Join(editor.History(), ",") != "a,b,a" {
+		t.Errorf("wrong history: %q", editor.History())
+	}
+
+	for i := 0; i < MAX_HISTORY+10; i++ {
+		editor.AddHistory(strings.Repeat("x", i+1))
+	}
+	if len(editor.History()) != MAX_HISTORY {
+		t.Errorf("history holds %d lines, want=%d", len(editor.History()), MAX_HISTORY)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	editor := New(strings.NewReader(""), io.Discard)
+	if err := editor.SetHistoryFile(path); err != nil {
+		t.Fatalf("a missing history file: %s", err)
+	}
+	editor.AddHistory("let x = 1;")
+	editor.AddHistory("x + 1")
+
+	again := New(strings.NewReader(UP+UP+"\r"), io.Discard)
+	if err := again.SetHistoryFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := again.ReadLine("> "); line != "let x = 1;" {
+		t.Errorf("history not loaded, got=%q", line)
+	}
+
+	var lines []string
+	for i := 0; i < MAX_HISTORY+5; i++ {
+		lines = append(lines, strings.Repeat("y", i+1))
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+
+	trimmed := New(strings.NewReader(""), io.Discard)
+	if err := trimmed.SetHistoryFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if count := strings.Count(string(data), "\n"); count != MAX_HISTORY {
+		t.Errorf("history file holds %d lines, want=%d", count, MAX_HISTORY)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	words := []string{"let", "len", "length", "puts"}
+	complete := func(line string, cursor int) (int, []string) {
+		start := strings.LastIndex(line[:cursor], " ") + 1
+		var candidates []string
+		for _, word := range words {
+			if strings.HasPrefix(word, line[start:cursor]) {
+				candidates = append(candidates, word)
+			}
+		}
+		return start, candidates
+	}
+
+	tests := []struct {
+		keys     string
+		expected string
+		output   string
+	}{
+		{"pu\t\r", "puts", ""},
+		{"x = pu\t(1)\r", "x = puts(1)", ""},
+		{"le\t\r", "le", "\a"},
+		{"lengt\t\r", "length", ""},
+		{"le\t\t\r", "le", "let  len  length\n"},
+		{"len\t\t\r", "len", "len  length\n"},
+		{"zz\t\r", "zz", "\a"},
+		{"a pu)" + LEFT + "\t\r", "a puts)", ""},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		editor := New(strings.NewReader(tt.keys), &out)
+		editor.Complete = complete
+
+		line, err := editor.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("%q: %s", tt.keys, err)
+		}
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. got=%q, want=%q", tt.keys, line, tt.expected)
+		}
+		if tt.output != "" && !strings.Contains(out.String(), tt.output) {
+			t.Errorf("output for %q does not show %q: %q", tt.keys, tt.output, out.String())
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	var out bytes.Buffer
+	editor := New(strings.NewReader("ab"+LEFT+"\r"), &out)
+	editor.ReadLine("> ")
+
+	expected := "\r> \x1b[K" + "\r> a\x1b[K" + "\r> ab\x1b[K" + "\r> ab\x1b[K\x1b[1D" + "\n"
+	if out.String() != expected {
+		t.Errorf("wrong drawing.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	defer writer.Close()
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	// a character device such as /dev/null is no terminal either
+	for _, file := range []*os.File{devNull, reader, file} {
+		if IsTerminal(file) {
+			t.Errorf("%s is taken for a terminal", file.Name())
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package lineedit
+
+import "syscall"
+
+const (
+	GET_TERMIOS = syscall.TIOCGETA
+	SET_TERMIOS = syscall.TIOCSETA
+)
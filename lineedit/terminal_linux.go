@@ -0,0 +1,8 @@
+package lineedit
+
+import "syscall"
+
+const (
+	GET_TERMIOS = syscall.TCGETS
+	SET_TERMIOS = syscall.TCSETS
+)
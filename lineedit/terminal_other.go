@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package lineedit
+
+import "errors"
+
+// Elsewhere the input is never taken for a terminal, and lines are read as
+// they come.
+
+type termios struct{}
+
+var errNotSupported = errors.New("terminals are not supported on this system")
+
+func getState(fd int) (*termios, error) {
+	return nil, errNotSupported
+}
+
+func setState(fd int, state *termios) error {
+	return errNotSupported
+}
+
+func makeRaw(fd int) (*termios, error) {
+	return nil, errNotSupported
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lint"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"io"
+)
+
+// runLint lints the files at paths, standard input when there are none, and
+// prints each finding as path:line:column: message (rule). Read and parse
+// errors go to errOut. The exit status is 2 when a file does not parse, 1
+// when one could not be read or something was found.
+func runLint(paths []string, out io.Writer, errOut io.Writer) int {
+	// scripts run with their arguments bound to args
+	predeclared := make(map[string]*object.Builtin)
+	for name, builtin := range evaluator.Builtins() {
+		predeclared[name] = builtin
+	}
+	predeclared["args"] = nil
+
+	sources, ok := readSources(paths, errOut)
+	status := 0
+	if !ok {
+		status = 1
+	}
+
+	for _, source := range sources {
+		program, ok := parse(source.path, source.text, errOut)
+		if !ok {
+			status = 2
+			continue
+		}
+
+		for _, finding := range lint.Lint(program, predeclared) {
+			fmt.Fprintf(out, "%s:%s\n", source.path, finding)
+			status = max(status, 1)
+		}
+	}
+
+	return status
+}
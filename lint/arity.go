@@ -0,0 +1,83 @@
+package lint
+
+import "fmt"
+
+// arity is the range of the numbers of arguments a function accepts, max
+// being variadic when there is no upper bound.
+type arity struct {
+	min int
+	max int
+}
+
+const variadic = -1
+
+func (self arity) accepts(count int) bool {
+	return count >= self.min && (self.max == variadic || count <= self.max)
+}
+
+// String completes "want" the way the builtins word their errors.
+func (self arity) String() string {
+	switch {
+	case self.max == variadic:
+		return fmt.Sprintf(" at least %d", self.min)
+	case self.min == self.max:
+		return fmt.Sprintf("=%d", self.min)
+	case self.min+1 == self.max:
+		return fmt.Sprintf("=%d or %d", self.min, self.max)
+	default:
+		return fmt.Sprintf("=%d to %d", self.min, self.max)
+	}
+}
+
+// builtinArities are the numbers of arguments taken by the builtins of the
+// evaluator. A builtin missing from the table is not checked.
+var builtinArities = map[string]arity{
+	"len":         {1, 1},
+	"first":       {1, 1},
+	"last":        {1, 1},
+	"rest":        {1, 1},
+	"push":        {2, 2},
+	"puts":        {0, variadic},
+	"keys":        {1, 1},
+	"values":      {1, 1},
+	"entries":     {1, 1},
+	"has":         {2, 2},
+	"delete":      {2, 2},
+	"merge":       {1, variadic},
+	"concat":      {1, variadic},
+	"slice":       {2, 3},
+	"reverse":     {1, 1},
+	"contains":    {2, 2},
+	"index_of":    {2, 2},
+	"flatten":     {1, 2},
+	"zip":         {1, variadic},
+	"range":       {1, 3},
+	"map":         {2, 2},
+	"filter":      {2, 2},
+	"reduce":      {2, 3},
+	"each":        {2, 2},
+	"find":        {2, 2},
+	"any":         {2, 2},
+	"all":         {2, 2},
+	"sort":        {1, 2},
+	"sort_by":     {2, 2},
+	"group_by":    {2, 2},
+	"split":       {2, 2},
+	"join":        {1, 2},
+	"trim":        {1, 2},
+	"trim_left":   {1, 2},
+	"trim_right":  {1, 2},
+	"upper":       {1, 1},
+	"lower":       {1, 1},
+	"replace":     {3, 4},
+	"starts_with": {2, 2},
+	"ends_with":   {2, 2},
+	"repeat":      {2, 2},
+	"pad_left":    {2, 3},
+	"pad_right":   {2, 3},
+	"chars":       {1, 1},
+	"substr":      {2, 3},
+	"format":      {1, variadic},
+	"sprintf":     {1, variadic},
+	"str":         {1, 1},
+}
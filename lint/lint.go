@@ -0,0 +1,338 @@
+// Package lint reports likely mistakes in Monkey programs without running them:
+// bindings never used or used but never bound, names hiding others, code that
+// cannot run, calls with the wrong number of arguments and conditions that
+// never change.
+package lint
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"sort"
+	"strings"
+)
+
+// The rules a finding can come from.
+const (
+	UNUSED_LET         = "unused-let"
+	UNDEFINED          = "undefined"
+	SHADOWING          = "shadowing"
+	UNREACHABLE        = "unreachable"
+	ARGUMENT_COUNT     = "argument-count"
+	CONSTANT_CONDITION = "constant-condition"
+)
+
+// Finding is a problem found in a program. Start and End delimit the token it
+// is about: the name of a binding, the callee of a call, the keyword of an if.
+type Finding struct {
+	Rule    string
+	Message string
+	Start   token.Position
+	End     token.Position
+}
+
+func (self Finding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", self.Start, self.Message, self.Rule)
+}
+
+type binding struct {
+	name      *ast.Identifier // where the name is first bound
+	parameter bool
+	used      bool
+
+	// the function the name is bound to, when its only binding is a
+	// function literal
+	function *ast.FunctionLiteral
+}
+
+// scope holds the bindings of a function, or of the program. As in the
+// evaluator, the lets of blocks bind their names in the enclosing function.
+type scope struct {
+	outer    *scope
+	bindings map[string]*binding
+	order    []*binding
+}
+
+type linter struct {
+	scope    *scope
+	builtins map[string]*object.Builtin
+	findings []Finding
+}
+
+// Lint checks program, whose identifiers may also refer to builtins, and
+// returns its findings in source order.
+func Lint(program *ast.Program, builtins map[string]*object.Builtin) []Finding {
+	self := &linter{builtins: builtins}
+
+	self.enterScope()
+	self.declareStatements(program.Statements)
+	self.checkStatements(program.Statements)
+	self.leaveScope()
+
+	sort.SliceStable(self.findings, func(i, j int) bool {
+		return self.findings[i].Start.Offset < self.findings[j].Start.Offset
+	})
+
+	return self.findings
+}
+
+func (self *linter) report(rule string, tok token.Token, format string, args ...any) {
+	self.findings = append(self.findings, Finding{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+		Start:   tok.Start,
+		End:     tok.End,
+	})
+}
+
+func (self *linter) enterScope() {
+	self.scope = &scope{outer: self.scope, bindings: make(map[string]*binding)}
+}
+
+// leaveScope reports the lets of the current scope that nothing refers to.
+// Names starting with an underscore are meant to be unused.
+func (self *linter) leaveScope() {
+	for _, binding := range self.scope.order {
+		if !binding.used && !binding.parameter && !strings.HasPrefix(binding.name.Value, "_") {
+			self.report(UNUSED_LET, binding.name.Token, "%s is bound but never used", binding.name.Value)
+		}
+	}
+
+	self.scope = self.scope.outer
+}
+
+func (self *linter) bind(name *ast.Identifier, parameter bool, value ast.Expression) {
+	if existing, ok := self.scope.bindings[name.Value]; ok {
+		// a let binding the name again, the value may change
+		existing.function = nil
+		return
+	}
+
+	self.checkShadowing(name)
+
+	binding := &binding{name: name, parameter: parameter}
+	binding.function, _ = value.(*ast.FunctionLiteral)
+
+	self.scope.bindings[name.Value] = binding
+	self.scope.order = append(self.scope.order, binding)
+}
+
+func (self *linter) checkShadowing(name *ast.Identifier) {
+	for scope := self.scope.outer; scope != nil; scope = scope.outer {
+		if outer, ok := scope.bindings[name.Value]; ok {
+			self.report(SHADOWING, name.Token, "%s shadows the binding at %s", name.Value, outer.name.Token.Start)
+			return
+		}
+	}
+
+	if _, ok := self.builtins[name.Value]; ok {
+		self.report(SHADOWING, name.Token, "%s shadows the builtin %s", name.Value, name.Value)
+	}
+}
+
+func (self *linter) lookup(name string) (*binding, bool) {
+	for scope := self.scope; scope != nil; scope = scope.outer {
+		if binding, ok := scope.bindings[name]; ok {
+			return binding, true
+		}
+	}
+
+	return nil, false
+}
+
+// declareStatements binds in the current scope the names of the lets of
+// statements, before any identifier is checked: a function may refer to a let
+// that follows it.
+func (self *linter) declareStatements(statements []ast.Statement) {
+	for _, statement := range statements {
+		self.declare(statement)
+	}
+}
+
+func (self *linter) declare(node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			self.bind(node.Name, false, node.Value)
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return true
+	})
+}
+
+// checkStatements checks statements, and reports those following a return.
+func (self *linter) checkStatements(statements []ast.Statement) {
+	for i, statement := range statements {
+		self.check(statement)
+
+		if _, ok := statement.(*ast.ReturnStatement); ok && i+1 < len(statements) {
+			unreachable := statements[i+1]
+			self.report(UNREACHABLE, statementToken(unreachable), "unreachable code after return")
+			for _, statement := range statements[i+1:] {
+				self.check(statement)
+			}
+			return
+		}
+	}
+}
+
+func (self *linter) check(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		self.check(node.Value)
+	case *ast.ReturnStatement:
+		self.check(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		self.check(node.Expression)
+	case *ast.BlockStatement:
+		self.checkStatements(node.Statements)
+	case *ast.Identifier:
+		self.checkIdentifier(node)
+	case *ast.PrefixExpression:
+		self.check(node.Right)
+	case *ast.InfixExpression:
+		self.check(node.Left)
+		self.check(node.Right)
+	case *ast.IfExpression:
+		if isConstant(node.Condition) {
+			self.report(CONSTANT_CONDITION, node.Token, "condition of if is constant: %s", node.Condition.String())
+		}
+		self.check(node.Condition)
+		self.check(node.Consequence)
+		if node.Alternative != nil {
+			self.check(node.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		self.checkFunction(node)
+	case *ast.CallExpression:
+		self.checkArgumentCount(node)
+		self.check(node.Function)
+		for _, argument := range node.Arguments {
+			self.check(argument)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			self.check(element)
+		}
+	case *ast.IndexExpression:
+		self.check(node.Left)
+		self.check(node.Index)
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			self.check(key)
+			self.check(value)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			self.check(part)
+		}
+	}
+}
+
+func (self *linter) checkFunction(function *ast.FunctionLiteral) {
+	self.enterScope()
+
+	for _, parameter := range function.Parameters {
+		self.bind(parameter, true, nil)
+	}
+
+	self.declareStatements(function.Body.Statements)
+	self.checkStatements(function.Body.Statements)
+
+	self.leaveScope()
+}
+
+func (self *linter) checkIdentifier(identifier *ast.Identifier) {
+	if binding, ok := self.lookup(identifier.Value); ok {
+		binding.used = true
+		return
+	}
+
+	if _, ok := self.builtins[identifier.Value]; ok {
+		return
+	}
+
+	self.report(UNDEFINED, identifier.Token, "undefined: %s", identifier.Value)
+}
+
+// checkArgumentCount checks calls to function literals, to names bound to a
+// function literal, and to builtins.
+func (self *linter) checkArgumentCount(call *ast.CallExpression) {
+	got := len(call.Arguments)
+
+	switch callee := call.Function.(type) {
+	case *ast.FunctionLiteral:
+		if got != len(callee.Parameters) {
+			self.report(ARGUMENT_COUNT, call.Token, "wrong number of arguments to function literal. got=%d, want=%d", got, len(callee.Parameters))
+		}
+	case *ast.Identifier:
+		if binding, ok := self.lookup(callee.Value); ok {
+			if binding.function != nil && got != len(binding.function.Parameters) {
+				self.report(ARGUMENT_COUNT, callee.Token, "wrong number of arguments to %s. got=%d, want=%d", callee.Value, got, len(binding.function.Parameters))
+			}
+			return
+		}
+
+		if _, ok := self.builtins[callee.Value]; !ok {
+			return
+		}
+
+		if arity, ok := builtinArities[callee.Value]; ok && !arity.accepts(got) {
+			self.report(ARGUMENT_COUNT, callee.Token, "wrong number of arguments to %s. got=%d, want%s", callee.Value, got, arity)
+		}
+	}
+}
+
+// isConstant reports whether the value of expression can be known without
+// running the program.
+func isConstant(expression ast.Expression) bool {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.StringLiteral, *ast.FunctionLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(expression.Right)
+	case *ast.InfixExpression:
+		return isConstant(expression.Left) && isConstant(expression.Right)
+	case *ast.ArrayLiteral:
+		for _, element := range expression.Elements {
+			if !isConstant(element) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashLiteral:
+		for key, value := range expression.Pairs {
+			if !isConstant(key) || !isConstant(value) {
+				return false
+			}
+		}
+		return true
+	case *ast.InterpolatedString:
+		for _, part := range expression.Parts {
+			if !isConstant(part) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// statementToken is the first token of statement.
+func statementToken(statement ast.Statement) token.Token {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return statement.Token
+	case *ast.ReturnStatement:
+		return statement.Token
+	case *ast.ExpressionStatement:
+		return statement.Token
+	case *ast.BlockStatement:
+		return statement.Token
+	default:
+		return token.Token{}
+	}
+}
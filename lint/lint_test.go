@@ -0,0 +1,123 @@
+package lint
+
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 1; x`, nil},
+		{`let x = 1;`, []string{"1:5: x is bound but never used (unused-let)"}},
+		{`let _x = 1;`, nil},
+		{`let f = fn(a, b) { a }; f(1, 2)`, nil},
+		{`let f = fn() { let y = 2; 1 }; f()`, []string{"1:20: y is bound but never used (unused-let)"}},
+		{`y + 1`, []string{"1:1: undefined: y (undefined)"}},
+		{`len([1]) + puts()`, nil},
+		{`let f = fn() { g() }; let g = fn() { 1 }; f()`, nil},
+		{"let x = 1;\n\"${x} and ${z}\"", []string{"2:13: undefined: z (undefined)"}},
+		{`let x = 1; let f = fn(x) { x }; f(x)`, []string{"1:23: x shadows the binding at 1:5 (shadowing)"}},
+		{`let f = fn() { let f = 1; f }; f()`, []string{"1:20: f shadows the binding at 1:5 (shadowing)"}},
+		{`let len = fn(x) { 1 }; len(1)`, []string{"1:5: len shadows the builtin len (shadowing)"}},
+		{`let x = 1; let x = x + 1; x`, nil},
+		{
+			"let f = fn(x) {\n  return x;\n  x + 1;\n  x\n}; f(1)",
+			[]string{"3:3: unreachable code after return (unreachable)"},
+		},
+		{`let f = fn(x) { if (x) { return 1; } 2 }; f(1)`, nil},
+		{`let f = fn(a, b) { a + b }; f(1)`, []string{"1:29: wrong number of arguments to f. got=1, want=2 (argument-count)"}},
+		{`fn(a) { a }(1, 2)`, []string{"1:12: wrong number of arguments to function literal. got=2, want=1 (argument-count)"}},
+		{`len(1, 2)`, []string{"1:1: wrong number of arguments to len. got=2, want=1 (argument-count)"}},
+		{`slice([1])`, []string{"1:1: wrong number of arguments to slice. got=1, want=2 or 3 (argument-count)"}},
+		{`format()`, []string{"1:1: wrong number of arguments to format. got=0, want at least 1 (argument-count)"}},
+		{`let f = fn(a) { a }; let f = fn(a, b) { b }; f(1, 2)`, nil},
+		{`let g = fn(f) { f(1, 2) }; g(len)`, nil},
+		{`if (true) { 1 }`, []string{"1:1: condition of if is constant: true (constant-condition)"}},
+		{`if (1 < 2) { 1 } else { 2 }`, []string{"1:1: condition of if is constant: (1 < 2) (constant-condition)"}},
+		{`let x = 1; if (x < 2) { 1 }`, nil},
+		{
+			"let unused = 1;\nlet f = fn(a) { return a; b }",
+			[]string{
+				"1:5: unused is bound but never used (unused-let)",
+				"2:5: f is bound but never used (unused-let)",
+				"2:27: unreachable code after return (unreachable)",
+				"2:27: undefined: b (undefined)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		findings := Lint(parse(t, tt.input), evaluator.Builtins())
+
+		var got []string
+		for _, finding := range findings {
+			got = append(got, finding.String())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong findings for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFindingSpans(t *testing.T) {
+	findings := Lint(parse(t, `let counter = 1;`), evaluator.Builtins())
+
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got=%d", len(findings))
+	}
+
+	if findings[0].Start.Column != 5 || findings[0].End.Column != 12 {
+		t.Errorf("finding spans columns %d to %d, want=5 to 12", findings[0].Start.Column, findings[0].End.Column)
+	}
+}
+
+// TestBuiltinArities checks the table against the builtins themselves, which
+// reject the numbers of arguments it does not accept.
+func TestBuiltinArities(t *testing.T) {
+	for name, builtin := range evaluator.Builtins() {
+		arity, ok := builtinArities[name]
+		if !ok {
+			t.Errorf("no arity for builtin %s", name)
+			continue
+		}
+
+		if name == "puts" {
+			continue
+		}
+
+		for count := 0; count <= 5; count++ {
+			args := make([]object.Object, count)
+			for i := range args {
+				args[i] = object.NULL
+			}
+
+			result := builtin.Fn(nil, args...)
+			err, isError := result.(*object.Error)
+			rejected := isError && strings.HasPrefix(err.Message, "wrong number of arguments")
+
+			if rejected == arity.accepts(count) {
+				t.Errorf("%s with %d arguments: table accepts=%t, builtin returned %s", name, count, arity.accepts(count), result.Inspect())
+			}
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	monkeyParser := parser.New(lexer.New(input))
+	program := monkeyParser.ParseProgram()
+
+	if len(monkeyParser.Errors()) != 0 {
+		t.Fatalf("parse errors for %q: %v", input, monkeyParser.Errors())
+	}
+
+	return program
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/lsp"
+	"io"
+)
+
+// runLanguageServer serves the Language Server Protocol on in and out until
+// the editor asks it to exit. The exit status is 1 when the editor did not ask
+// it to shut down first, or the connection broke.
+func runLanguageServer(in io.Reader, out io.Writer, errOut io.Writer) int {
+	if err := lsp.NewServer(in, out).Serve(); err != nil {
+		fmt.Fprintf(errOut, "lsp: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,65 @@
+package lsp
+
+import "github.com/Neal-C/interpreter-in-go/object"
+
+// builtinDoc is what hovering a builtin shows: how it is called and what it
+// does. result is the type of what it returns, when there is a single one.
+type builtinDoc struct {
+	signature string
+	doc       string
+	result    object.ObjectType
+}
+
+var builtinDocs = map[string]builtinDoc{
+	"len":         {"len(value)", "The number of bytes of a string, elements of an array or pairs of a hash.", object.INTEGER_OBJ},
+	"first":       {"first(array)", "The first element of array, null when it is empty.", ""},
+	"last":        {"last(array)", "The last element of array, null when it is empty.", ""},
+	"rest":        {"rest(array)", "array without its first element, null when it is empty.", ""},
+	"push":        {"push(array, value)", "A new array with value after the elements of array.", object.ARRAY_OBJ},
+	"puts":        {"puts(values...)", "Prints each value on a line of its own.", object.NULL_OBJ},
+	"keys":        {"keys(hash)", "The keys of hash.", object.ARRAY_OBJ},
+	"values":      {"values(hash)", "The values of hash.", object.ARRAY_OBJ},
+	"entries":     {"entries(hash)", "The [key, value] pairs of hash.", object.ARRAY_OBJ},
+	"has":         {"has(hash, key)", "Whether hash has key.", object.BOOLEAN_OBJ},
+	"delete":      {"delete(hash, key)", "A new hash without key.", object.HASH_OBJ},
+	"merge":       {"merge(hashes...)", "A new hash with the pairs of all hashes, the later ones winning.", object.HASH_OBJ},
+	"concat":      {"concat(arrays...)", "A new array with the elements of all arrays.", object.ARRAY_OBJ},
+	"slice":       {"slice(array, start, end)", "The elements of array from start up to end, which defaults to its length. Negative indices count from the end.", object.ARRAY_OBJ},
+	"reverse":     {"reverse(array)", "The elements of array in reverse order.", object.ARRAY_OBJ},
+	"contains":    {"contains(collection, value)", "Whether an array holds value, or a string holds the string value.", object.BOOLEAN_OBJ},
+	"index_of":    {"index_of(collection, value)", "The index of value in an array, or of a substring in a string, -1 when it is not there.", object.INTEGER_OBJ},
+	"flatten":     {"flatten(array, depth)", "array with its nested arrays spliced in, down to depth levels, 1 by default.", object.ARRAY_OBJ},
+	"zip":         {"zip(arrays...)", "Arrays of the elements at the same index in each array, as many as in the shortest.", object.ARRAY_OBJ},
+	"range":       {"range(start, end, step)", "The integers from start, 0 when only end is given, up to end excluded, by step, 1 by default.", object.ARRAY_OBJ},
+	"map":         {"map(array, fn)", "The results of calling fn on each element of array.", object.ARRAY_OBJ},
+	"filter":      {"filter(array, fn)", "The elements of array for which fn returns a truthy value.", object.ARRAY_OBJ},
+	"reduce":      {"reduce(array, fn, initial)", "Folds array with fn(accumulator, element), starting from initial or the first element.", ""},
+	"each":        {"each(array, fn)", "Calls fn on each element of array.", object.NULL_OBJ},
+	"find":        {"find(array, fn)", "The first element of array for which fn returns a truthy value, null when there is none.", ""},
+	"any":         {"any(array, fn)", "Whether fn returns a truthy value for some element of array.", object.BOOLEAN_OBJ},
+	"all":         {"all(array, fn)", "Whether fn returns a truthy value for every element of array.", object.BOOLEAN_OBJ},
+	"sort":        {"sort(array, compare)", "The elements of array sorted, by compare(left, right) returning a negative, zero or positive integer when given.", object.ARRAY_OBJ},
+	"sort_by":     {"sort_by(array, fn)", "The elements of array sorted by the results of fn.", object.ARRAY_OBJ},
+	"group_by":    {"group_by(array, fn)", "A hash of the elements of array by the results of fn.", object.HASH_OBJ},
+	"split":       {"split(string, separator)", "The parts of string between separators.", object.ARRAY_OBJ},
+	"join":        {"join(array, separator)", "The strings of array joined by separator, nothing by default.", object.STRING_OBJ},
+	"trim":        {"trim(string, cutset)", "string without the characters of cutset, or whitespace, at both ends.", object.STRING_OBJ},
+	"trim_left":   {"trim_left(string, cutset)", "string without the characters of cutset, or whitespace, at its start.", object.STRING_OBJ},
+	"trim_right":  {"trim_right(string, cutset)", "string without the characters of cutset, or whitespace, at its end.", object.STRING_OBJ},
+	"upper":       {"upper(string)", "string in upper case.", object.STRING_OBJ},
+	"lower":       {"lower(string)", "string in lower case.", object.STRING_OBJ},
+	"replace":     {"replace(string, old, new, count)", "string with the first count occurrences of old, all of them by default, replaced by new.", object.STRING_OBJ},
+	"starts_with": {"starts_with(string, prefix)", "Whether string starts with prefix.", object.BOOLEAN_OBJ},
+	"ends_with":   {"ends_with(string, suffix)", "Whether string ends with suffix.", object.BOOLEAN_OBJ},
+	"repeat":      {"repeat(string, count)", "string repeated count times.", object.STRING_OBJ},
+	"pad_left":    {"pad_left(string, width, padding)", "string padded at its start up to width characters with padding, a blank by default.", object.STRING_OBJ},
+	"pad_right":   {"pad_right(string, width, padding)", "string padded at its end up to width characters with padding, a blank by default.", object.STRING_OBJ},
+	"chars":       {"chars(string)", "The characters of string.", object.ARRAY_OBJ},
+	"substr":      {"substr(string, start, length)", "The characters of string from start, length of them or up to its end.", object.STRING_OBJ},
+	"format":      {"format(template, values...)", "template with its verbs replaced by values: %d %b %o %x %X %f %e %g for integers, %s %q for strings, %t for booleans, %v for any value.", object.STRING_OBJ},
+	"sprintf":     {"sprintf(template, values...)", "The same as format.", object.STRING_OBJ},
+	"str":         {"str(value)", "value as a string, as it is printed.", object.STRING_OBJ},
+}
+
+// ARGS_DOC describes args, the array scripts find their arguments in.
+const ARGS_DOC = "The arguments the script was run with."
@@ -0,0 +1,26 @@
+package lsp
+
+import (
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestEveryBuiltinHasDocs(t *testing.T) {
+	for name := range evaluator.Builtins() {
+		doc, ok := builtinDocs[name]
+		if !ok {
+			t.Errorf("builtin %s has no docs", name)
+			continue
+		}
+		if !strings.HasPrefix(doc.signature, name+"(") || doc.doc == "" {
+			t.Errorf("wrong docs for %s: %+v", name, doc)
+		}
+	}
+
+	for name := range builtinDocs {
+		if _, ok := evaluator.Builtins()[name]; !ok {
+			t.Errorf("docs for %s, which is not a builtin", name)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package lsp
+
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"sort"
+	"strings"
+)
+
+// document is an open text document, parsed, with its identifiers resolved
+// to the bindings they refer to.
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []int // the offsets the lines start at
+
+	program *ast.Program
+	errors  []parser.Error
+
+	global      *scope
+	scopes      []*scope          // every scope, in source order
+	identifiers []*ast.Identifier // the identifiers of the program, in source order
+	bindings    map[*ast.Identifier]*binding
+}
+
+// binding is a name bound by a let or as a parameter, and the identifiers
+// referring to it, its name first.
+type binding struct {
+	name       *ast.Identifier
+	let        *ast.LetStatement // nil for a parameter
+	scope      *scope
+	references []*ast.Identifier
+	visible    int // the offset from which the binding is visible in its scope
+}
+
+// scope is the program or a function. As in the evaluator, the lets of the
+// blocks of a function bind their names in its scope.
+type scope struct {
+	outer    *scope
+	function *ast.FunctionLiteral // nil for the program
+	bindings map[string][]*binding
+	order    []*binding
+}
+
+func newDocument(uri string, version int, text string) *document {
+	self := &document{uri: uri, version: version, text: text, lines: lineStarts(text), bindings: make(map[*ast.Identifier]*binding)}
+
+	monkeyParser := parser.New(lexer.New(text))
+	self.program = monkeyParser.ParseProgram()
+	self.errors = monkeyParser.DetailedErrors()
+
+	self.global = self.enterScope(nil, nil)
+	self.declare(self.global, self.program)
+	self.resolveStatements(self.global, self.program.Statements)
+
+	sort.Slice(self.identifiers, func(i, j int) bool {
+		return self.identifiers[i].Pos().Offset < self.identifiers[j].Pos().Offset
+	})
+
+	return self
+}
+
+func (self *document) enterScope(outer *scope, function *ast.FunctionLiteral) *scope {
+	created := &scope{outer: outer, function: function, bindings: make(map[string][]*binding)}
+	self.scopes = append(self.scopes, created)
+	return created
+}
+
+func (self *document) bind(in *scope, name *ast.Identifier, let *ast.LetStatement, visible int) {
+	bound := &binding{name: name, let: let, scope: in, references: []*ast.Identifier{name}, visible: visible}
+	in.bindings[name.Value] = append(in.bindings[name.Value], bound)
+	in.order = append(in.order, bound)
+
+	self.bindings[name] = bound
+	self.identifiers = append(self.identifiers, name)
+}
+
+// declare binds the lets of node in scope, leaving out those of the
+// functions inside it.
+func (self *document) declare(in *scope, node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			self.bind(in, node.Name, node, node.End().Offset)
+		}
+		return true
+	})
+}
+
+func (self *document) resolveStatements(in *scope, statements []ast.Statement) {
+	for _, statement := range statements {
+		if statement != nil {
+			self.resolve(in, statement)
+		}
+	}
+}
+
+// resolve binds the identifiers used in node to what they refer to.
+func (self *document) resolve(in *scope, node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			// the name was bound by declare
+			if node.Value != nil {
+				self.resolve(in, node.Value)
+			}
+			return false
+
+		case *ast.FunctionLiteral:
+			function := self.enterScope(in, node)
+			for _, parameter := range node.Parameters {
+				self.bind(function, parameter, nil, 0)
+			}
+			self.declare(function, node.Body)
+			self.resolve(function, node.Body)
+			return false
+
+		case *ast.Identifier:
+			self.identifiers = append(self.identifiers, node)
+			if bound := in.lookup(node.Value, node.Pos().Offset); bound != nil {
+				bound.references = append(bound.references, node)
+				self.bindings[node] = bound
+			}
+		}
+		return true
+	})
+}
+
+// lookup finds the binding name refers to at offset. In its own scope, a
+// name is bound once its let is over; from the functions inside the scope,
+// which may run later, by the last let before offset or else the first one.
+func (self *scope) lookup(name string, offset int) *binding {
+	for current := self; current != nil; current = current.outer {
+		candidates := current.bindings[name]
+
+		var found *binding
+		for _, candidate := range candidates {
+			if current == self && candidate.visible <= offset || current != self && candidate.name.Pos().Offset <= offset {
+				found = candidate
+			}
+		}
+		if found == nil && current != self && len(candidates) > 0 {
+			found = candidates[0]
+		}
+
+		if found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// identifierAt returns the identifier at offset, or just before it.
+func (self *document) identifierAt(offset int) *ast.Identifier {
+	i := sort.Search(len(self.identifiers), func(i int) bool {
+		return self.identifiers[i].End().Offset >= offset
+	})
+	if i < len(self.identifiers) && self.identifiers[i].Pos().Offset <= offset {
+		return self.identifiers[i]
+	}
+	return nil
+}
+
+// scopeAt returns the innermost scope offset is in.
+func (self *document) scopeAt(offset int) *scope {
+	innermost := self.global
+	for _, current := range self.scopes[1:] {
+		if current.function.Pos().Offset <= offset && offset <= current.function.End().Offset {
+			innermost = current
+		}
+	}
+	return innermost
+}
+
+// visible returns the bindings visible at offset, the innermost first, one
+// for each name.
+func (self *document) visible(offset int) []*binding {
+	var found []*binding
+	seen := make(map[string]bool)
+
+	innermost := self.scopeAt(offset)
+	for current := innermost; current != nil; current = current.outer {
+		for _, bound := range current.order {
+			if seen[bound.name.Value] || current == innermost && bound.visible > offset {
+				continue
+			}
+			seen[bound.name.Value] = true
+			found = append(found, bound)
+		}
+	}
+
+	return found
+}
+
+func lineStarts(text string) []int {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// position converts an offset to a position of the protocol.
+func (self *document) position(offset int) Position {
+	offset = max(0, min(offset, len(self.text)))
+	line := sort.Search(len(self.lines), func(i int) bool { return self.lines[i] > offset }) - 1
+
+	return Position{Line: line, Character: utf16Length(self.text[self.lines[line]:offset])}
+}
+
+// offset converts a position of the protocol to an offset, clamped to the
+// line it is on.
+func (self *document) offset(position Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+	if position.Line >= len(self.lines) {
+		return len(self.text)
+	}
+
+	start := self.lines[position.Line]
+	line := self.text[start:]
+	if end := strings.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+
+	units := 0
+	for i, r := range line {
+		if units >= position.Character {
+			return start + i
+		}
+		units += utf16Length(string(r))
+	}
+
+	return start + len(line)
+}
+
+func (self *document) rangeOf(node ast.Node) Range {
+	return Range{Start: self.position(node.Pos().Offset), End: self.position(node.End().Offset)}
+}
+
+// utf16Length is the number of UTF-16 code units of text.
+func utf16Length(text string) int {
+	length := 0
+	for _, r := range text {
+		if r >= 0x10000 {
+			length += 2 // a surrogate pair
+		} else {
+			length++
+		}
+	}
+	return length
+}
@@ -0,0 +1,72 @@
+package lsp
+
+import "testing"
+
+func TestPositions(t *testing.T) {
+	current := newDocument(URI, 1, "let s = \"é😀\";\ns\n")
+
+	tests := []struct {
+		offset   int
+		position Position
+	}{
+		{0, Position{0, 0}},
+		{9, Position{0, 9}},
+		{11, Position{0, 10}}, // after é, two bytes and one code unit
+		{15, Position{0, 12}}, // after 😀, four bytes and two code units
+		{18, Position{1, 0}},
+		{19, Position{1, 1}},
+		{20, Position{2, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := current.position(tt.offset); got != tt.position {
+			t.Errorf("wrong position of offset %d. got=%+v, want=%+v", tt.offset, got, tt.position)
+		}
+		if got := current.offset(tt.position); got != tt.offset {
+			t.Errorf("wrong offset of %+v. got=%d, want=%d", tt.position, got, tt.offset)
+		}
+	}
+
+	clamped := []struct {
+		position Position
+		offset   int
+	}{
+		{Position{0, 100}, 17},
+		{Position{5, 0}, 20},
+		{Position{-1, 3}, 0},
+	}
+
+	for _, tt := range clamped {
+		if got := current.offset(tt.position); got != tt.offset {
+			t.Errorf("wrong offset of %+v. got=%d, want=%d", tt.position, got, tt.offset)
+		}
+	}
+}
+
+func TestBrokenDocuments(t *testing.T) {
+	sources := []string{
+		"let",
+		"let x = ",
+		"let f = fn(a, { a }",
+		"if (x { 1 }",
+		"{1: }",
+		"f(1,",
+		"\"${\"",
+	}
+
+	for _, source := range sources {
+		current := newDocument(URI, 1, source)
+		if len(current.errors) == 0 {
+			t.Errorf("%q parsed", source)
+		}
+
+		// nothing left of the parse may trip up the features
+		current.symbols(current.global)
+		current.visible(len(source))
+		for offset := 0; offset <= len(source); offset++ {
+			if identifier := current.identifierAt(offset); identifier != nil {
+				current.bindingOf(identifier)
+			}
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/format"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MAX_INFERENCE_DEPTH bounds how many bindings kindOf follows.
+const MAX_INFERENCE_DEPTH = 16
+
+// identifierAt returns the document at uri and the identifier at position in
+// it, either of them nil when there is none.
+func (self *Server) identifierAt(params TextDocumentPositionParams) (*document, *ast.Identifier) {
+	current, ok := self.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	return current, current.identifierAt(current.offset(params.Position))
+}
+
+func (self *Server) hover(raw json.RawMessage) (any, error) {
+	var params TextDocumentPositionParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	current, identifier := self.identifierAt(params)
+	if identifier == nil {
+		return nil, nil
+	}
+
+	var contents string
+	if bound, ok := current.bindings[identifier]; ok {
+		contents = "```monkey\n" + current.signature(bound) + "\n```"
+	} else if doc, ok := builtinDocs[identifier.Value]; ok {
+		contents = "```monkey\n" + doc.signature + "\n```\n" + doc.doc
+	} else if identifier.Value == "args" {
+		contents = "```monkey\nargs: ARRAY\n```\n" + ARGS_DOC
+	} else {
+		return nil, nil
+	}
+
+	identifierRange := current.rangeOf(identifier)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: contents}, Range: &identifierRange}, nil
+}
+
+// signature is how a binding is shown: with the parameters of the function
+// bound by a let, or the type of the value when it can be told without
+// running the program.
+func (self *document) signature(bound *binding) string {
+	if bound.let == nil {
+		return "(parameter) " + bound.name.Value
+	}
+
+	detail := self.detail(bound.let)
+	switch {
+	case strings.HasPrefix(detail, "fn("):
+		return fmt.Sprintf("let %s = %s", bound.name.Value, detail)
+	case detail != "":
+		return fmt.Sprintf("let %s: %s", bound.name.Value, detail)
+	}
+
+	return "let " + bound.name.Value
+}
+
+// detail is the parameters of the function let binds, or the type of its
+// value, if known.
+func (self *document) detail(let *ast.LetStatement) string {
+	if function, ok := let.Value.(*ast.FunctionLiteral); ok {
+		names := make([]string, len(function.Parameters))
+		for i, parameter := range function.Parameters {
+			names[i] = parameter.Value
+		}
+		return "fn(" + strings.Join(names, ", ") + ")"
+	}
+
+	return string(self.kindOf(let.Value, 0))
+}
+
+// kindOf infers the type of the value of expression, "" when it cannot be
+// told without running the program.
+func (self *document) kindOf(expression ast.Expression, depth int) object.ObjectType {
+	if depth > MAX_INFERENCE_DEPTH {
+		return ""
+	}
+
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ
+	case *ast.StringLiteral, *ast.InterpolatedString:
+		return object.STRING_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ
+	case *ast.HashLiteral:
+		return object.HASH_OBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ
+
+	case *ast.PrefixExpression:
+		switch expression.Operator {
+		case "!":
+			return object.BOOLEAN_OBJ
+		case "-":
+			return object.INTEGER_OBJ
+		}
+
+	case *ast.InfixExpression:
+		left, right := self.kindOf(expression.Left, depth+1), self.kindOf(expression.Right, depth+1)
+		switch {
+		case slices.Contains([]string{"==", "!=", "<", ">"}, expression.Operator):
+			return object.BOOLEAN_OBJ
+		case left == object.INTEGER_OBJ && right == object.INTEGER_OBJ:
+			return object.INTEGER_OBJ
+		case expression.Operator == "+" && left == object.STRING_OBJ && right == object.STRING_OBJ:
+			return object.STRING_OBJ
+		case expression.Operator == "*" && left == object.STRING_OBJ && right == object.INTEGER_OBJ:
+			return object.STRING_OBJ
+		}
+
+	case *ast.Identifier:
+		if bound, ok := self.bindings[expression]; ok {
+			if bound.let != nil && bound.let.Value != nil {
+				return self.kindOf(bound.let.Value, depth+1)
+			}
+			return ""
+		}
+		if _, ok := builtinDocs[expression.Value]; ok {
+			return object.BUILTIN_OBJ
+		}
+		if expression.Value == "args" {
+			return object.ARRAY_OBJ
+		}
+
+	case *ast.CallExpression:
+		if callee, ok := expression.Function.(*ast.Identifier); ok {
+			if _, bound := self.bindings[callee]; !bound {
+				return builtinDocs[callee.Value].result
+			}
+		}
+	}
+
+	return ""
+}
+
+func (self *Server) definition(raw json.RawMessage) (any, error) {
+	var params TextDocumentPositionParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	current, identifier := self.identifierAt(params)
+	bound, ok := current.bindingOf(identifier)
+	if !ok {
+		return nil, nil
+	}
+
+	return Location{URI: current.uri, Range: current.rangeOf(bound.name)}, nil
+}
+
+// bindingOf returns the binding identifier refers to, when it refers to one.
+func (self *document) bindingOf(identifier *ast.Identifier) (*binding, bool) {
+	if self == nil || identifier == nil {
+		return nil, false
+	}
+
+	bound, ok := self.bindings[identifier]
+	return bound, ok
+}
+
+func (self *Server) references(raw json.RawMessage) (any, error) {
+	var params ReferenceParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	current, identifier := self.identifierAt(params.TextDocumentPositionParams)
+	bound, ok := current.bindingOf(identifier)
+	if !ok {
+		return []Location{}, nil
+	}
+
+	locations := []Location{}
+	for _, reference := range current.sortedReferences(bound) {
+		if reference == bound.name && !params.Context.IncludeDeclaration {
+			continue
+		}
+		locations = append(locations, Location{URI: current.uri, Range: current.rangeOf(reference)})
+	}
+
+	return locations, nil
+}
+
+func (self *document) sortedReferences(bound *binding) []*ast.Identifier {
+	references := slices.Clone(bound.references)
+	sort.Slice(references, func(i, j int) bool {
+		return references[i].Pos().Offset < references[j].Pos().Offset
+	})
+	return references
+}
+
+func (self *Server) documentSymbol(raw json.RawMessage) (any, error) {
+	var params DocumentSymbolParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	current, ok := self.documents[params.TextDocument.URI]
+	if !ok {
+		return []DocumentSymbol{}, nil
+	}
+
+	return current.symbols(current.global), nil
+}
+
+// symbols returns the symbols of the lets of in, with the lets of the
+// functions in the value of a let as its children.
+func (self *document) symbols(in *scope) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	for _, bound := range in.order {
+		if bound.let == nil {
+			continue
+		}
+
+		symbol := DocumentSymbol{
+			Name:           bound.name.Value,
+			Detail:         self.detail(bound.let),
+			Kind:           SYMBOL_VARIABLE,
+			Range:          self.rangeOf(bound.let),
+			SelectionRange: self.rangeOf(bound.name),
+		}
+		if _, ok := bound.let.Value.(*ast.FunctionLiteral); ok {
+			symbol.Kind = SYMBOL_FUNCTION
+		}
+
+		for _, inner := range self.scopes {
+			if inner.outer == in && contains(bound.let, inner.function) {
+				symbol.Children = append(symbol.Children, self.symbols(inner)...)
+			}
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
+
+// contains reports whether inner is within the span of outer.
+func contains(outer ast.Node, inner ast.Node) bool {
+	return outer.Pos().Offset <= inner.Pos().Offset && inner.End().Offset <= outer.End().Offset
+}
+
+func (self *Server) completion(raw json.RawMessage) (any, error) {
+	var params TextDocumentPositionParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	current, ok := self.documents[params.TextDocument.URI]
+	if !ok {
+		return []CompletionItem{}, nil
+	}
+
+	offset := current.offset(params.Position)
+	start := offset
+	for start > 0 && isIdentifierCharacter(current.text[start-1]) {
+		start--
+	}
+	prefix := current.text[start:offset]
+
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	add := func(item CompletionItem) {
+		if !seen[item.Label] && strings.HasPrefix(item.Label, prefix) {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	for _, bound := range current.visible(offset) {
+		// the name being typed is not a completion of itself
+		if bound.name.Pos().Offset == start {
+			continue
+		}
+
+		kind := COMPLETION_VARIABLE
+		if bound.let != nil {
+			if _, ok := bound.let.Value.(*ast.FunctionLiteral); ok {
+				kind = COMPLETION_FUNCTION
+			}
+		}
+		add(CompletionItem{Label: bound.name.Value, Kind: kind, Detail: current.signature(bound)})
+	}
+
+	names := make([]string, 0, len(builtinDocs))
+	for name := range builtinDocs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc := builtinDocs[name]
+		add(CompletionItem{Label: name, Kind: COMPLETION_FUNCTION, Detail: doc.signature, Documentation: &MarkupContent{Kind: "markdown", Value: doc.doc}})
+	}
+
+	add(CompletionItem{Label: "args", Kind: COMPLETION_VARIABLE, Detail: "args: ARRAY", Documentation: &MarkupContent{Kind: "markdown", Value: ARGS_DOC}})
+
+	for _, keyword := range token.Keywords() {
+		add(CompletionItem{Label: keyword, Kind: COMPLETION_KEYWORD})
+	}
+
+	return items, nil
+}
+
+func isIdentifierCharacter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
+
+func (self *Server) rename(raw json.RawMessage) (any, error) {
+	var params RenameParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	current, identifier := self.identifierAt(params.TextDocumentPositionParams)
+	bound, ok := current.bindingOf(identifier)
+	if !ok {
+		return nil, fmt.Errorf("only names bound in the document can be renamed")
+	}
+
+	if !isIdentifier(params.NewName) {
+		return nil, fmt.Errorf("%q is not a name", params.NewName)
+	}
+	if err := current.renameConflict(bound, params.NewName); err != nil {
+		return nil, err
+	}
+
+	edits := []TextEdit{}
+	for _, reference := range current.sortedReferences(bound) {
+		edits = append(edits, TextEdit{Range: current.rangeOf(reference), NewText: params.NewName})
+	}
+
+	return WorkspaceEdit{Changes: map[string][]TextEdit{current.uri: edits}}, nil
+}
+
+// renameConflict tells why renaming bound to name would change what an
+// identifier refers to, nil when it would not. As gopls does, it refuses when
+// name is bound in a scope a reference of bound sits in, from the scope of the
+// reference out to that of bound, and when a use of name in the scope of
+// bound refers to something outside of it, which bound would then shadow.
+func (self *document) renameConflict(bound *binding, name string) error {
+	if name == bound.name.Value {
+		return nil
+	}
+
+	for _, reference := range bound.references {
+		for current := self.scopeAt(reference.Pos().Offset); current != bound.scope.outer; current = current.outer {
+			if others := current.bindings[name]; len(others) > 0 {
+				return fmt.Errorf("%s is already bound at line %d, where %s is used", name, self.position(others[0].name.Pos().Offset).Line+1, bound.name.Value)
+			}
+		}
+	}
+
+	for _, identifier := range self.identifiers {
+		if identifier.Value != name || !self.scopeAt(identifier.Pos().Offset).within(bound.scope) {
+			continue
+		}
+		if other, ok := self.bindings[identifier]; !ok || !other.scope.within(bound.scope) {
+			return fmt.Errorf("%s would shadow the %s used at line %d", bound.name.Value, name, self.position(identifier.Pos().Offset).Line+1)
+		}
+	}
+
+	return nil
+}
+
+// within reports whether the scope is outer or a scope inside it.
+func (self *scope) within(outer *scope) bool {
+	for current := self; current != nil; current = current.outer {
+		if current == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdentifier reports whether name can be bound: it is made of letters and
+// underscores, and is not a keyword.
+func isIdentifier(name string) bool {
+	if name == "" || slices.Contains(token.Keywords(), name) {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		if !isIdentifierCharacter(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *Server) formatting(raw json.RawMessage) (any, error) {
+	var params DocumentFormattingParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	current, ok := self.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	formatted, err := format.Source(current.text)
+	if err != nil {
+		// the diagnostics tell why
+		return nil, nil
+	}
+
+	if formatted == current.text {
+		return []TextEdit{}, nil
+	}
+
+	whole := Range{Start: Position{}, End: current.position(len(current.text))}
+	return []TextEdit{{Range: whole, NewText: formatted}}, nil
+}
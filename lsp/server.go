@@ -0,0 +1,279 @@
+// Package lsp is a language server for Monkey: it speaks the Language Server
+// Protocol with an editor over a pair of streams, reporting the parse errors
+// and lint findings of the documents open in the editor, and answering hover,
+// definition, references, symbols, completion, rename and formatting
+// requests.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lint"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"io"
+)
+
+// NAME is the name of the server, and the source of its diagnostics.
+const NAME = "monkey"
+
+// ErrExitWithoutShutdown is returned by Serve when the client asked it to
+// exit without asking it to shut down first.
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+type handler func(self *Server, params json.RawMessage) (any, error)
+
+// requests are the methods answered with a result, notifications those
+// that are not answered.
+var (
+	requests      map[string]handler
+	notifications map[string]handler
+)
+
+func init() {
+	requests = map[string]handler{
+		"initialize":                  (*Server).initialize,
+		"shutdown":                    (*Server).shutdown,
+		"textDocument/hover":          (*Server).hover,
+		"textDocument/definition":     (*Server).definition,
+		"textDocument/references":     (*Server).references,
+		"textDocument/documentSymbol": (*Server).documentSymbol,
+		"textDocument/completion":     (*Server).completion,
+		"textDocument/rename":         (*Server).rename,
+		"textDocument/formatting":     (*Server).formatting,
+	}
+
+	notifications = map[string]handler{
+		"textDocument/didOpen":   (*Server).didOpen,
+		"textDocument/didChange": (*Server).didChange,
+		"textDocument/didClose":  (*Server).didClose,
+	}
+}
+
+// Server is a language server talking with a client over a reader and a
+// writer.
+type Server struct {
+	reader      *bufio.Reader
+	out         io.Writer
+	documents   map[string]*document
+	predeclared map[string]*object.Builtin
+
+	initialized  bool
+	shuttingDown bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	// scripts run with their arguments bound to args
+	predeclared := make(map[string]*object.Builtin)
+	for name, builtin := range evaluator.Builtins() {
+		predeclared[name] = builtin
+	}
+	predeclared["args"] = nil
+
+	return &Server{
+		reader:      bufio.NewReader(in),
+		out:         out,
+		documents:   make(map[string]*document),
+		predeclared: predeclared,
+	}
+}
+
+// Serve answers the messages of the client until it asks the server to
+// exit, or its input ends. It returns nil when the client asked the server to
+// shut down then exit.
+func (self *Server) Serve() error {
+	for {
+		body, err := readMessage(self.reader)
+		if err != nil {
+			return err
+		}
+
+		var request message
+		if err := json.Unmarshal(body, &request); err != nil {
+			self.reply(json.RawMessage("null"), nil, &ResponseError{Code: PARSE_ERROR, Message: err.Error()})
+			continue
+		}
+
+		if request.Method == "exit" {
+			if !self.shuttingDown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		if request.ID == nil {
+			self.notified(request)
+			continue
+		}
+
+		result, err := self.call(request)
+		if err := self.reply(*request.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+// call runs the handler of request, returning what it is answered with.
+func (self *Server) call(request message) (any, error) {
+	handle, ok := requests[request.Method]
+	switch {
+	case !ok:
+		return nil, &ResponseError{Code: METHOD_NOT_FOUND, Message: fmt.Sprintf("unknown method %s", request.Method)}
+	case !self.initialized && request.Method != "initialize":
+		return nil, &ResponseError{Code: SERVER_NOT_INITIALIZED, Message: "the server is not initialized"}
+	case self.shuttingDown:
+		return nil, &ResponseError{Code: INVALID_REQUEST, Message: "the server is shutting down"}
+	}
+
+	return handle(self, request.Params)
+}
+
+// notified handles a notification. Those the server does not know, such as
+// initialized or $/cancelRequest, are ignored.
+func (self *Server) notified(request message) {
+	if handle, ok := notifications[request.Method]; ok && self.initialized {
+		handle(self, request.Params)
+	}
+}
+
+func (self *Server) reply(id json.RawMessage, result any, err error) error {
+	if err == nil {
+		return writeMessage(self.out, response{JSONRPC: "2.0", ID: id, Result: result})
+	}
+
+	var responseError *ResponseError
+	if !errors.As(err, &responseError) {
+		responseError = &ResponseError{Code: REQUEST_FAILED, Message: err.Error()}
+	}
+
+	return writeMessage(self.out, errorResponse{JSONRPC: "2.0", ID: id, Error: responseError})
+}
+
+func (self *Server) notify(method string, params any) error {
+	return writeMessage(self.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// decode unmarshals the params of a request into params.
+func decode(raw json.RawMessage, params any) error {
+	if err := json.Unmarshal(raw, params); err != nil {
+		return &ResponseError{Code: INVALID_PARAMS, Message: err.Error()}
+	}
+	return nil
+}
+
+func (self *Server) initialize(params json.RawMessage) (any, error) {
+	self.initialized = true
+
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           TEXT_DOCUMENT_SYNC_FULL,
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			DocumentSymbolProvider:     true,
+			CompletionProvider:         &CompletionOptions{},
+			RenameProvider:             true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: NAME},
+	}, nil
+}
+
+func (self *Server) shutdown(params json.RawMessage) (any, error) {
+	self.shuttingDown = true
+	return nil, nil
+}
+
+func (self *Server) didOpen(raw json.RawMessage) (any, error) {
+	var params DidOpenTextDocumentParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	item := params.TextDocument
+	self.open(newDocument(item.URI, item.Version, item.Text))
+	return nil, nil
+}
+
+func (self *Server) didChange(raw json.RawMessage) (any, error) {
+	var params DidChangeTextDocumentParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	current, ok := self.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	text := current.text
+	for _, change := range params.ContentChanges {
+		if change.Range == nil {
+			text = change.Text
+			continue
+		}
+
+		// clients may send ranges even though the server asked for
+		// whole texts
+		changed := &document{text: text, lines: lineStarts(text)}
+		text = text[:changed.offset(change.Range.Start)] + change.Text + text[changed.offset(change.Range.End):]
+	}
+
+	self.open(newDocument(current.uri, params.TextDocument.Version, text))
+	return nil, nil
+}
+
+func (self *Server) didClose(raw json.RawMessage) (any, error) {
+	var params DidCloseTextDocumentParams
+	if err := decode(raw, &params); err != nil {
+		return nil, err
+	}
+
+	delete(self.documents, params.TextDocument.URI)
+	return nil, self.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+}
+
+// open keeps opened as the current state of its document, and publishes
+// its diagnostics.
+func (self *Server) open(opened *document) {
+	self.documents[opened.uri] = opened
+
+	self.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         opened.uri,
+		Version:     opened.version,
+		Diagnostics: self.diagnostics(opened),
+	})
+}
+
+// diagnostics are the parse errors of a document, or the findings of the
+// linter when it parses.
+func (self *Server) diagnostics(current *document) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for _, parseError := range current.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: current.position(parseError.Start.Offset), End: current.position(parseError.End.Offset)},
+			Severity: SEVERITY_ERROR,
+			Source:   NAME,
+			Message:  parseError.Message,
+		})
+	}
+
+	if len(current.errors) != 0 {
+		return diagnostics
+	}
+
+	for _, finding := range lint.Lint(current.program, self.predeclared) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: current.position(finding.Start.Offset), End: current.position(finding.End.Offset)},
+			Severity: SEVERITY_WARNING,
+			Code:     finding.Rule,
+			Source:   NAME,
+			Message:  finding.Message,
+		})
+	}
+
+	return diagnostics
+}
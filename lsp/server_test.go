@@ -0,0 +1,524 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client talks with a Server running in a goroutine, the way an editor
+// does.
+type client struct {
+	t        *testing.T
+	out      *io.PipeWriter
+	messages chan message
+	pending  []message // the notifications read while waiting for a response
+	nextID   int
+	done     chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	self := &client{t: t, out: clientOut, messages: make(chan message, 100), done: make(chan error, 1)}
+
+	go func() {
+		self.done <- NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+
+	go func() {
+		reader := bufio.NewReader(clientIn)
+		for {
+			body, err := readMessage(reader)
+			if err != nil {
+				close(self.messages)
+				return
+			}
+
+			var received message
+			if err := json.Unmarshal(body, &received); err != nil {
+				t.Errorf("the server sent %q: %s", body, err)
+			}
+			self.messages <- received
+		}
+	}()
+
+	t.Cleanup(func() { clientOut.Close() })
+
+	return self
+}
+
+// initialized returns a client past the initialization handshake.
+func initialized(t *testing.T) *client {
+	self := newClient(t)
+	if err := self.call("initialize", map[string]any{"capabilities": map[string]any{}}, nil); err != nil {
+		t.Fatalf("initialize: %s", err)
+	}
+	self.notify("initialized", map[string]any{})
+	return self
+}
+
+func (self *client) send(value any) {
+	if err := writeMessage(self.out, value); err != nil {
+		self.t.Fatalf("writing to the server: %s", err)
+	}
+}
+
+func (self *client) receive() message {
+	select {
+	case received, ok := <-self.messages:
+		if !ok {
+			self.t.Fatalf("the server closed the connection")
+		}
+		return received
+	case <-time.After(5 * time.Second):
+		self.t.Fatalf("the server did not answer")
+	}
+	return message{}
+}
+
+// call sends a request and unmarshals its result into result, returning the
+// error it was answered with instead, if any.
+func (self *client) call(method string, params any, result any) *ResponseError {
+	self.nextID++
+	self.send(map[string]any{"jsonrpc": "2.0", "id": self.nextID, "method": method, "params": params})
+
+	for {
+		received := self.receive()
+		if received.ID == nil {
+			self.pending = append(self.pending, received)
+			continue
+		}
+
+		if string(*received.ID) != strings.TrimSpace(mustMarshal(self.nextID)) {
+			self.t.Fatalf("answer to request %s while waiting for %d", *received.ID, self.nextID)
+		}
+		if received.Error != nil {
+			return received.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(received.Result, result); err != nil {
+				self.t.Fatalf("%s: unmarshaling %s: %s", method, received.Result, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (self *client) notify(method string, params any) {
+	self.send(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// notification returns the next notification of method from the server.
+func (self *client) notification(method string, params any) {
+	for {
+		var received message
+		if len(self.pending) > 0 {
+			received, self.pending = self.pending[0], self.pending[1:]
+		} else {
+			received = self.receive()
+		}
+
+		if received.Method == method {
+			if err := json.Unmarshal(received.Params, params); err != nil {
+				self.t.Fatalf("%s: unmarshaling %s: %s", method, received.Params, err)
+			}
+			return
+		}
+	}
+}
+
+func (self *client) open(uri string, text string) []Diagnostic {
+	self.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text}})
+
+	var published PublishDiagnosticsParams
+	self.notification("textDocument/publishDiagnostics", &published)
+	if published.URI != uri {
+		self.t.Fatalf("diagnostics published for %s, want=%s", published.URI, uri)
+	}
+	return published.Diagnostics
+}
+
+func mustMarshal(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func at(uri string, line int, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: line, Character: character}}
+}
+
+func span(startLine int, startCharacter int, endLine int, endCharacter int) Range {
+	return Range{Start: Position{startLine, startCharacter}, End: Position{endLine, endCharacter}}
+}
+
+const URI = "file:///test.mk"
+
+const SOURCE = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let total = add(1, 2);
+let name = "monkey";
+puts(len(name), total);
+`
+
+func TestLifecycle(t *testing.T) {
+	self := newClient(t)
+
+	if err := self.call("textDocument/hover", at(URI, 0, 0), nil); err == nil || err.Code != SERVER_NOT_INITIALIZED {
+		t.Errorf("a request before initialize was answered with %v", err)
+	}
+
+	var result InitializeResult
+	if err := self.call("initialize", map[string]any{"processId": nil, "capabilities": map[string]any{}}, &result); err != nil {
+		t.Fatalf("initialize: %s", err)
+	}
+	capabilities := result.Capabilities
+	if capabilities.TextDocumentSync != TEXT_DOCUMENT_SYNC_FULL || !capabilities.HoverProvider || !capabilities.DefinitionProvider ||
+		!capabilities.ReferencesProvider || !capabilities.DocumentSymbolProvider || capabilities.CompletionProvider == nil ||
+		!capabilities.RenameProvider || !capabilities.DocumentFormattingProvider {
+		t.Errorf("missing capabilities: %+v", capabilities)
+	}
+
+	if err := self.call("textDocument/unknown", map[string]any{}, nil); err == nil || err.Code != METHOD_NOT_FOUND {
+		t.Errorf("an unknown method was answered with %v", err)
+	}
+	if err := self.call("textDocument/hover", "not params", nil); err == nil || err.Code != INVALID_PARAMS {
+		t.Errorf("bad params were answered with %v", err)
+	}
+
+	self.send(map[string]any{"jsonrpc": "2.0", "method": "$/cancelRequest", "params": map[string]any{"id": 1}})
+
+	var shutdownResult any = "not null"
+	if err := self.call("shutdown", nil, &shutdownResult); err != nil || shutdownResult != nil {
+		t.Errorf("shutdown answered %v, %v", shutdownResult, err)
+	}
+	if err := self.call("textDocument/hover", at(URI, 0, 0), nil); err == nil || err.Code != INVALID_REQUEST {
+		t.Errorf("a request after shutdown was answered with %v", err)
+	}
+
+	self.notify("exit", nil)
+	if err := <-self.done; err != nil {
+		t.Errorf("the server exited with %v", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	self := initialized(t)
+	self.notify("exit", nil)
+
+	if err := <-self.done; err != ErrExitWithoutShutdown {
+		t.Errorf("the server exited with %v, want=%v", err, ErrExitWithoutShutdown)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	self := initialized(t)
+
+	diagnostics := self.open(URI, "let x = 1;\nlet = 2;")
+	if len(diagnostics) != 2 {
+		t.Fatalf("wrong diagnostics: %+v", diagnostics)
+	}
+	if diagnostics[0].Message != "expected next token to be IDENT, got = instead" || diagnostics[0].Range != span(1, 4, 1, 5) ||
+		diagnostics[0].Severity != SEVERITY_ERROR || diagnostics[0].Source != NAME {
+		t.Errorf("wrong diagnostic: %+v", diagnostics[0])
+	}
+
+	self.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: URI, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = 1;\nlet y = 2;\ny"}},
+	})
+	var published PublishDiagnosticsParams
+	self.notification("textDocument/publishDiagnostics", &published)
+	if published.Version != 2 || len(published.Diagnostics) != 1 {
+		t.Fatalf("wrong diagnostics after a change: %+v", published)
+	}
+	if finding := published.Diagnostics[0]; finding.Code != "unused-let" || finding.Severity != SEVERITY_WARNING || finding.Range != span(0, 4, 0, 5) {
+		t.Errorf("wrong lint diagnostic: %+v", finding)
+	}
+
+	replaced := span(0, 0, 0, 10)
+	self.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: URI, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Range: &replaced, Text: "puts(args);"}},
+	})
+	self.notification("textDocument/publishDiagnostics", &published)
+	if len(published.Diagnostics) != 0 {
+		t.Errorf("diagnostics after a ranged change: %+v", published.Diagnostics)
+	}
+
+	self.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: URI}})
+	self.notification("textDocument/publishDiagnostics", &published)
+	if published.Diagnostics == nil || len(published.Diagnostics) != 0 {
+		t.Errorf("diagnostics left after closing: %+v", published.Diagnostics)
+	}
+}
+
+func TestHover(t *testing.T) {
+	self := initialized(t)
+	if diagnostics := self.open(URI, SOURCE); len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %+v", diagnostics)
+	}
+
+	tests := []struct {
+		line          int
+		character     int
+		expected      string
+		expectedRange Range
+	}{
+		{4, 12, "```monkey\nlet add = fn(a, b)\n```", span(4, 12, 4, 15)},
+		{4, 15, "```monkey\nlet add = fn(a, b)\n```", span(4, 12, 4, 15)},
+		{1, 12, "```monkey\n(parameter) a\n```", span(1, 12, 1, 13)},
+		{1, 6, "```monkey\nlet sum\n```", span(1, 6, 1, 9)},
+		{5, 5, "```monkey\nlet name: STRING\n```", span(5, 4, 5, 8)},
+		{6, 5, "```monkey\nlen(value)\n```\nThe number of bytes of a string, elements of an array or pairs of a hash.", span(6, 5, 6, 8)},
+	}
+
+	for _, tt := range tests {
+		var hover *Hover
+		if err := self.call("textDocument/hover", at(URI, tt.line, tt.character), &hover); err != nil {
+			t.Fatal(err)
+		}
+
+		if hover == nil || hover.Contents.Value != tt.expected || hover.Contents.Kind != "markdown" || *hover.Range != tt.expectedRange {
+			t.Errorf("wrong hover at %d:%d: %+v", tt.line, tt.character, hover)
+		}
+	}
+
+	var hover *Hover
+	if err := self.call("textDocument/hover", at(URI, 3, 0), &hover); err != nil || hover != nil {
+		t.Errorf("hover outside identifiers: %+v, %v", hover, err)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	self := initialized(t)
+	self.open(URI, SOURCE)
+
+	var location *Location
+	if err := self.call("textDocument/definition", at(URI, 6, 17), &location); err != nil {
+		t.Fatal(err)
+	}
+	if location == nil || location.URI != URI || location.Range != span(4, 4, 4, 9) {
+		t.Errorf("wrong definition of total: %+v", location)
+	}
+
+	location = nil
+	if err := self.call("textDocument/definition", at(URI, 6, 1), &location); err != nil || location != nil {
+		t.Errorf("a builtin has a definition: %+v, %v", location, err)
+	}
+
+	tests := []struct {
+		includeDeclaration bool
+		expected           []Range
+	}{
+		{true, []Range{span(0, 4, 0, 7), span(4, 12, 4, 15)}},
+		{false, []Range{span(4, 12, 4, 15)}},
+	}
+
+	for _, tt := range tests {
+		params := ReferenceParams{TextDocumentPositionParams: at(URI, 0, 5), Context: ReferenceContext{IncludeDeclaration: tt.includeDeclaration}}
+
+		var locations []Location
+		if err := self.call("textDocument/references", params, &locations); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(locations) != len(tt.expected) {
+			t.Fatalf("wrong references: %+v", locations)
+		}
+		for i, expected := range tt.expected {
+			if locations[i].Range != expected {
+				t.Errorf("wrong reference %d: %+v, want=%+v", i, locations[i].Range, expected)
+			}
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	source := `let x = 1;
+let f = fn(x) { x };
+let g = fn() { h() };
+let h = fn() { x };
+let x = x + 1;
+x`
+
+	self := initialized(t)
+	self.open(URI, source)
+
+	tests := []struct {
+		line      int
+		character int
+		expected  Range
+	}{
+		{1, 16, span(1, 11, 1, 12)}, // the parameter hides the let
+		{2, 15, span(3, 4, 3, 5)},   // a function refers to a later let
+		{3, 15, span(0, 4, 0, 5)},   // the last let before the function
+		{4, 8, span(0, 4, 0, 5)},    // the value of a let is before its name
+		{5, 0, span(4, 4, 4, 5)},
+	}
+
+	for _, tt := range tests {
+		var location *Location
+		if err := self.call("textDocument/definition", at(URI, tt.line, tt.character), &location); err != nil {
+			t.Fatal(err)
+		}
+		if location == nil || location.Range != tt.expected {
+			t.Errorf("wrong definition at %d:%d: %+v, want=%+v", tt.line, tt.character, location, tt.expected)
+		}
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	self := initialized(t)
+	self.open(URI, SOURCE)
+
+	var symbols []DocumentSymbol
+	if err := self.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: URI}}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []DocumentSymbol{
+		{Name: "add", Detail: "fn(a, b)", Kind: SYMBOL_FUNCTION, Range: span(0, 0, 3, 1), SelectionRange: span(0, 4, 0, 7), Children: []DocumentSymbol{
+			{Name: "sum", Kind: SYMBOL_VARIABLE, Range: span(1, 2, 1, 17), SelectionRange: span(1, 6, 1, 9)},
+		}},
+		{Name: "total", Kind: SYMBOL_VARIABLE, Range: span(4, 0, 4, 21), SelectionRange: span(4, 4, 4, 9)},
+		{Name: "name", Detail: "STRING", Kind: SYMBOL_VARIABLE, Range: span(5, 0, 5, 19), SelectionRange: span(5, 4, 5, 8)},
+	}
+
+	if mustMarshal(symbols) != mustMarshal(expected) {
+		t.Errorf("wrong symbols.\nexpected=%s\ngot=%s", mustMarshal(expected), mustMarshal(symbols))
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		source    string
+		line      int
+		character int
+		expected  []string
+	}{
+		{"let total = 1;\nlet tomato = fn(x) { x };\nto", 2, 2, []string{"total", "tomato"}},
+		{"let f = fn(param) {\n  pa\n};", 1, 4, []string{"param", "pad_left", "pad_right"}},
+		{"let rest = 1;\nre", 1, 2, []string{"rest", "reduce", "repeat", "replace", "reverse", "return"}},
+		{"let later = 1;\nla", 0, 6, []string{"last"}},
+		{"fn", 0, 2, []string{"fn"}},
+		{"ar", 0, 2, []string{"args"}},
+	}
+
+	for _, tt := range tests {
+		self := initialized(t)
+		self.open(URI, tt.source)
+
+		var items []CompletionItem
+		if err := self.call("textDocument/completion", at(URI, tt.line, tt.character), &items); err != nil {
+			t.Fatal(err)
+		}
+
+		var labels []string
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		if strings.Join(labels, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("wrong completions in %q at %d:%d. got=%q, want=%q", tt.source, tt.line, tt.character, labels, tt.expected)
+		}
+	}
+
+	self := initialized(t)
+	self.open(URI, "let f = fn(a) { a };\nf")
+
+	var items []CompletionItem
+	self.call("textDocument/completion", at(URI, 1, 1), &items)
+	if len(items) == 0 || items[0].Label != "f" || items[0].Kind != COMPLETION_FUNCTION || items[0].Detail != "let f = fn(a)" {
+		t.Errorf("wrong completion of a function: %+v", items)
+	}
+}
+
+func TestRename(t *testing.T) {
+	self := initialized(t)
+	self.open(URI, SOURCE)
+
+	var edit WorkspaceEdit
+	if err := self.call("textDocument/rename", RenameParams{TextDocumentPositionParams: at(URI, 1, 12), NewName: "left"}, &edit); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TextEdit{{Range: span(0, 13, 0, 14), NewText: "left"}, {Range: span(1, 12, 1, 13), NewText: "left"}}
+	if mustMarshal(edit.Changes[URI]) != mustMarshal(expected) || len(edit.Changes) != 1 {
+		t.Errorf("wrong rename.\nexpected=%s\ngot=%s", mustMarshal(expected), mustMarshal(edit.Changes))
+	}
+
+	failures := []RenameParams{
+		{TextDocumentPositionParams: at(URI, 6, 0), NewName: "print"},
+		{TextDocumentPositionParams: at(URI, 1, 12), NewName: "two words"},
+		{TextDocumentPositionParams: at(URI, 1, 12), NewName: "let"},
+		{TextDocumentPositionParams: at(URI, 1, 12), NewName: ""},
+	}
+	for _, params := range failures {
+		if err := self.call("textDocument/rename", params, &edit); err == nil || err.Code != REQUEST_FAILED {
+			t.Errorf("renaming to %q was answered with %v", params.NewName, err)
+		}
+	}
+}
+
+func TestRenameConflicts(t *testing.T) {
+	tests := []struct {
+		source   string
+		position Position
+		newName  string
+		expected string // the error, empty when the rename is made
+	}{
+		{"let x = 1; let f = fn(y) { x + y }", Position{0, 4}, "y", "y is already bound at line 1, where x is used"},
+		{"let x = 1; let y = 2; x", Position{0, 4}, "y", "y is already bound at line 1, where x is used"},
+		{"let x = 1; let f = fn(y) { x + y }", Position{0, 22}, "x", "y would shadow the x used at line 1"},
+		{"let f = fn(x) { len(x) }", Position{0, 11}, "len", "x would shadow the len used at line 1"},
+		{"let f = fn(x) { x }; let g = fn(y) { y }", Position{0, 11}, "y", ""},
+		{"let x = 1; let f = fn() { let y = 2; y }; x", Position{0, 4}, "y", ""},
+		{"let x = 1; x", Position{0, 4}, "x", ""},
+	}
+
+	for _, tt := range tests {
+		self := initialized(t)
+		self.open(URI, tt.source)
+
+		var edit WorkspaceEdit
+		err := self.call("textDocument/rename", RenameParams{TextDocumentPositionParams: at(URI, tt.position.Line, tt.position.Character), NewName: tt.newName}, &edit)
+
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("%q: renaming to %s failed: %s", tt.source, tt.newName, err.Message)
+		case tt.expected != "" && (err == nil || err.Message != tt.expected):
+			t.Errorf("%q: renaming to %s was answered with %v, want=%s", tt.source, tt.newName, err, tt.expected)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"let x=1\nputs( x )", `[{"range":{"start":{"line":0,"character":0},"end":{"line":1,"character":9}},"newText":"let x = 1;\nputs(x);\n"}]`},
+		{"let x = 1;\n", `[]`},
+		{"let = 1", `null`},
+	}
+
+	for _, tt := range tests {
+		self := initialized(t)
+		self.open(URI, tt.source)
+
+		var edits json.RawMessage
+		if err := self.call("textDocument/formatting", DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: URI}}, &edits); err != nil {
+			t.Fatal(err)
+		}
+		if string(edits) != tt.expected {
+			t.Errorf("wrong edits for %q.\nexpected=%s\ngot=%s", tt.source, tt.expected, edits)
+		}
+	}
+}
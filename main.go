@@ -1,19 +1,103 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"github.com/Neal-C/interpreter-in-go/lineedit"
 	"github.com/Neal-C/interpreter-in-go/repl"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
 )
 
+const USAGE = `usage:
+	monkey [flags]                     the REPL, or the script on standard input when it is not a terminal
+	monkey [flags] file.mk [args...]   run a script, also what a #!/usr/bin/env monkey line does
+	monkey [flags] run [flags] file.mk [args...]
+	monkey [flags] -e code [args...]   run code and print its value
+	monkey [flags] repl
+	monkey lint|fmt|dot [files...]
+	monkey debug file.mk [args...]     run a script in the debugger, help lists its commands
+	monkey lsp                         a language server for editors, on standard input and output
+	monkey dap                         a debug adapter for editors, on standard input and output
+
+A script finds its arguments in the array args. The exit status is 1 when it
+evaluates to an error, 2 when it does not parse.
+
+flags:
+`
+
+// HISTORY_FILE keeps the lines typed in the REPL, in the home directory.
+const HISTORY_FILE = ".monkey_history"
+
 func main() {
-	currentUser, err := user.Current()
-	if err != nil {
-		panic(err)
+	optimize := flag.Bool("optimize", false, "optimize each input before evaluating it")
+	dumpOptimized := flag.Bool("dump-optimized", false, "print the optimized AST of each input, implies -optimize")
+	dumpTokens := flag.Bool("dump-tokens", false, "print the tokens of the files given, or of standard input, as JSON")
+	dumpAST := flag.Bool("dump-ast", false, "print the AST of the files given, or of standard input, as JSON")
+	tracePath := flag.String("trace", "", "write a JSON-lines trace of the evaluation to `file`")
+	cpuProfile := flag.String("cpuprofile", "", "write a pprof profile of the Monkey functions to `file`")
+	onVM := flag.Bool("vm", false, "run scripts on the bytecode virtual machine instead of the evaluator")
+	expression := flag.String("e", "", "run `code` given on the command line and print its value")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), USAGE)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *dumpTokens || *dumpAST {
+		os.Exit(runDump(flag.Args(), *dumpTokens, os.Stdout, os.Stderr))
+	}
+
+	options := runOptions{optimize: *optimize, dumpOptimized: *dumpOptimized, trace: *tracePath, cpuProfile: *cpuProfile, vm: *onVM}
+
+	if *expression != "" {
+		os.Exit(runSource("-e", *expression, flag.Args(), true, options, os.Stdout, os.Stderr))
+	}
+
+	switch flag.Arg(0) {
+	case "lint":
+		os.Exit(runLint(flag.Args()[1:], os.Stdout, os.Stderr))
+	case "fmt":
+		os.Exit(runFormat(flag.Args()[1:], os.Stdout, os.Stderr))
+	case "dot":
+		os.Exit(runDot(flag.Args()[1:], os.Stdout, os.Stderr))
+	case "debug":
+		os.Exit(runDebug(flag.Args()[1:], os.Stdin, os.Stdout, os.Stderr))
+	case "dap":
+		os.Exit(runDebugAdapter(os.Stdin, os.Stdout, os.Stderr))
+	case "lsp":
+		os.Exit(runLanguageServer(os.Stdin, os.Stdout, os.Stderr))
+	case "run":
+		os.Exit(runCommand(flag.Args()[1:], options, os.Stdout, os.Stderr))
+	case "repl":
+		startRepl(os.Stdin, os.Stdout, repl.Options{Optimize: *optimize, DumpOptimized: *dumpOptimized})
+	case "":
+		if lineedit.IsTerminal(os.Stdin) {
+			startRepl(os.Stdin, os.Stdout, repl.Options{Optimize: *optimize, DumpOptimized: *dumpOptimized})
+		} else {
+			os.Exit(runFile([]string{"-"}, options, os.Stdout, os.Stderr))
+		}
+	default:
+		os.Exit(runFile(flag.Args(), options, os.Stdout, os.Stderr))
+	}
+}
+
+// startRepl greets the user on out and runs the REPL, in colors when out is a
+// terminal and NO_COLOR is not set.
+func startRepl(in io.Reader, out *os.File, options repl.Options) {
+	greeting := "Hello !"
+	if currentUser, err := user.Current(); err == nil {
+		greeting = fmt.Sprintf("Hello %s !", currentUser.Username)
+	}
+
+	fmt.Fprintf(out, "%s This is the monkey programming language ! \n", greeting)
+	fmt.Fprintf(out, "Start typing commands \n")
+	if home, err := os.UserHomeDir(); err == nil {
+		options.HistoryFile = filepath.Join(home, HISTORY_FILE)
 	}
+	options.Color = lineedit.IsTerminal(out) && os.Getenv("NO_COLOR") == ""
 
-	fmt.Printf("Hello %s ! This is the monkey programming language ! \n", currentUser.Username)
-	fmt.Printf("Start typing commands \n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.StartWithOptions(in, out, options)
 }
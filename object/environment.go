@@ -1,33 +1,187 @@
 package object
 
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"sort"
+	"sync"
+)
+
+// Environment holds the values of the names of a scope in slots, a nil slot
+// being a name of the scope that is not bound yet.
 type Environment struct {
-	store map[string]Object
+	scope *ast.Scope
+	store []Object
 	outer *Environment
+
+	// extra binds the names set in the environment of a call that its scope
+	// does not have: the scope is the function's, shared by all its calls.
+	extra map[string]Object
+
+	pooled   bool // created from environmentPool, it can go back to it
+	captured bool // a function refers to it, it must outlive its call
+}
+
+// environmentPool recycles the environments of calls, most of which end
+// without any function keeping a reference to them.
+var environmentPool = sync.Pool{
+	New: func() any { return &Environment{pooled: true} },
 }
 
+// NewEnvironment creates a global environment. Its scope grows with the lets
+// of every program evaluated in it, as the REPL does line after line.
 func NewEnvironment() *Environment {
-	nameToValueMap := make(map[string]Object)
 	return &Environment{
-		store: nameToValueMap,
+		scope: ast.NewScope(nil),
 		outer: nil,
 	}
 }
 
+// NewEnclosedEnvironment creates an environment with a scope of its own, for
+// a function that was not resolved.
+func NewEnclosedEnvironment(outerEnv *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outerEnv
+	return env
+}
+
+// NewScopedEnvironment creates an environment for a call of a function whose
+// parameters and lets were resolved to the slots of scope.
+func NewScopedEnvironment(scope *ast.Scope, outerEnv *Environment) *Environment {
+	if scope == nil {
+		return NewEnclosedEnvironment(outerEnv)
+	}
+
+	env := environmentPool.Get().(*Environment)
+	env.scope = scope
+	env.outer = outerEnv
+
+	if cap(env.store) >= scope.Len() {
+		env.store = env.store[:scope.Len()]
+	} else {
+		env.store = make([]Object, scope.Len())
+	}
+
+	return env
+}
+
+// Capture marks the environment as referred to by a function, which keeps it
+// from being released.
+func (self *Environment) Capture() {
+	self.captured = true
+}
+
+// Release hands the environment of a finished call back for reuse, unless a
+// function captured it. Nothing else may refer to it after the call.
+func (self *Environment) Release() {
+	if !self.pooled || self.captured {
+		return
+	}
+
+	clear(self.store)
+	self.scope = nil
+	self.outer = nil
+	self.extra = nil
+
+	environmentPool.Put(self)
+}
+
+func (self *Environment) Scope() *ast.Scope {
+	return self.scope
+}
+
+// Outer returns the enclosing environment, nil for a global one.
+func (self *Environment) Outer() *Environment {
+	return self.outer
+}
+
+// Binding is a name bound in an environment and its value.
+type Binding struct {
+	Name  string
+	Value Object
+}
+
+// Bindings returns the names bound in the environment itself, not in the ones
+// enclosing it, in the order of their slots.
+func (self *Environment) Bindings() []Binding {
+	var bindings []Binding
+	for slot, name := range self.scope.Names() {
+		if slot < len(self.store) && self.store[slot] != nil {
+			bindings = append(bindings, Binding{Name: name, Value: self.store[slot]})
+		}
+	}
+
+	names := make([]string, 0, len(self.extra))
+	for name := range self.extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		bindings = append(bindings, Binding{Name: name, Value: self.extra[name]})
+	}
+
+	return bindings
+}
+
 func (self *Environment) Get(name string) (Object, bool) {
-	value, ok := self.store[name]
-	if !ok && self.outer != nil {
-		value, ok = self.outer.Get(name)
+	if slot, ok := self.scope.Lookup(name); ok && slot < len(self.store) && self.store[slot] != nil {
+		return self.store[slot], true
 	}
-	return value, ok
+
+	if value, ok := self.extra[name]; ok {
+		return value, true
+	}
+
+	if self.outer != nil {
+		return self.outer.Get(name)
+	}
+
+	return nil, false
 }
 
+// Set binds name in the environment. Only a global scope, or the scope of an
+// environment that was not resolved, gains a slot for a name it does not
+// have: the scope of a call is shared with the other calls of the function.
 func (self *Environment) Set(name string, value Object) Object {
-	self.store[name] = value
-	return value
+	if slot, ok := self.scope.Lookup(name); ok {
+		return self.SetSlot(slot, value)
+	}
+
+	if self.scope.Outer != nil {
+		if self.extra == nil {
+			self.extra = make(map[string]Object)
+		}
+		self.extra[name] = value
+		return value
+	}
+
+	return self.SetSlot(self.scope.Define(name), value)
 }
 
-func NewEnclosedEnvironment(outerEnv *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outerEnv
-	return env
+// GetSlot returns the value at slot of the environment depth levels out,
+// provided that environment is one of scope and the slot is bound.
+func (self *Environment) GetSlot(scope *ast.Scope, depth int, slot int) (Object, bool) {
+	env := self
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.outer
+	}
+
+	if env == nil || env.scope != scope || slot >= len(env.store) {
+		return nil, false
+	}
+
+	value := env.store[slot]
+	return value, value != nil
+}
+
+// SetSlot binds slot of the environment's own scope to value.
+func (self *Environment) SetSlot(slot int, value Object) Object {
+	if slot >= len(self.store) {
+		// the global scope grows after its environment is created
+		grown := make([]Object, self.scope.Len())
+		copy(grown, self.store)
+		self.store = grown
+	}
+
+	self.store[slot] = value
+	return value
 }
@@ -0,0 +1,108 @@
+package object
+
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"testing"
+)
+
+func TestEnvironmentSlots(t *testing.T) {
+	global := NewEnvironment()
+	global.Set("a", NewInteger(1))
+
+	scope := ast.NewScope(global.Scope())
+	slot := scope.Define("b")
+
+	env := NewScopedEnvironment(scope, global)
+	env.SetSlot(slot, NewInteger(2))
+
+	if value, ok := env.GetSlot(global.Scope(), 1, 0); !ok || value != NewInteger(1) {
+		t.Errorf("GetSlot of the global a returned %v, %t", value, ok)
+	}
+
+	if value, ok := env.Get("b"); !ok || value != NewInteger(2) {
+		t.Errorf("Get of b returned %v, %t", value, ok)
+	}
+
+	// a slot read through the wrong scope misses instead of returning another value
+	if _, ok := env.GetSlot(ast.NewScope(nil), 0, slot); ok {
+		t.Errorf("GetSlot matched an environment of another scope")
+	}
+
+	// the global scope grows after its environment was created
+	global.Set("c", NewInteger(3))
+	if value, ok := env.Get("c"); !ok || value != NewInteger(3) {
+		t.Errorf("Get of c returned %v, %t", value, ok)
+	}
+}
+
+func TestEnvironmentRelease(t *testing.T) {
+	scope := ast.NewScope(nil)
+	scope.Define("x")
+
+	captured := NewScopedEnvironment(scope, nil)
+	captured.SetSlot(0, NewInteger(7))
+	captured.Capture()
+	captured.Release()
+
+	if value, ok := captured.Get("x"); !ok || value != NewInteger(7) {
+		t.Errorf("a captured environment was released, Get of x returned %v, %t", value, ok)
+	}
+
+	released := NewScopedEnvironment(scope, nil)
+	released.SetSlot(0, NewInteger(8))
+	released.Release()
+
+	reused := NewScopedEnvironment(scope, nil)
+	if _, ok := reused.Get("x"); ok {
+		t.Errorf("a new environment sees the bindings of a released one")
+	}
+}
+
+func TestSetInCallEnvironment(t *testing.T) {
+	global := NewEnvironment()
+	scope := ast.NewScope(global.Scope())
+	scope.Define("x")
+
+	env := NewScopedEnvironment(scope, global)
+	env.Set("x", NewInteger(1))
+	env.Set("y", NewInteger(2))
+
+	if scope.Len() != 1 {
+		t.Errorf("the scope of the function grew to %v", scope.Names())
+	}
+	if value, ok := env.Get("y"); !ok || value != NewInteger(2) {
+		t.Errorf("Get of y returned %v, %t", value, ok)
+	}
+	if bindings := env.Bindings(); len(bindings) != 2 || bindings[0] != (Binding{"x", NewInteger(1)}) || bindings[1] != (Binding{"y", NewInteger(2)}) {
+		t.Errorf("wrong bindings: %v", bindings)
+	}
+
+	other := NewScopedEnvironment(scope, global)
+	if _, ok := other.Get("y"); ok {
+		t.Errorf("another call of the function sees y")
+	}
+}
+
+func TestEnvironmentBindings(t *testing.T) {
+	global := NewEnvironment()
+	global.Set("a", NewInteger(1))
+	global.Scope().Define("unbound")
+	global.Set("b", NewInteger(2))
+
+	env := NewEnclosedEnvironment(global)
+	env.Set("c", NewInteger(3))
+
+	bindings := global.Bindings()
+	if len(bindings) != 2 || bindings[0] != (Binding{"a", NewInteger(1)}) || bindings[1] != (Binding{"b", NewInteger(2)}) {
+		t.Errorf("wrong global bindings: %v", bindings)
+	}
+
+	bindings = env.Bindings()
+	if len(bindings) != 1 || bindings[0].Name != "c" {
+		t.Errorf("wrong enclosed bindings: %v", bindings)
+	}
+
+	if env.Outer() != global || global.Outer() != nil {
+		t.Errorf("wrong outer environments")
+	}
+}
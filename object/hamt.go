@@ -0,0 +1,239 @@
+package object
+
+import "math/bits"
+
+// hamt is a persistent hash array mapped trie from hash keys to pairs. Each
+// level of the trie consumes 5 bits of the hash of a key, and only holds the
+// children actually present, found by counting the bits of a bitmap. Like
+// vectors, updates copy the path they change and share the rest.
+type hamt struct {
+	count int
+	root  *hamtNode
+}
+
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+	// below this depth the hash is exhausted and keys colliding on all of
+	// its bits are kept in a list
+	hamtMaxShift = 64
+)
+
+type hamtNode struct {
+	bitmap  uint32
+	entries []hamtEntry
+}
+
+// hamtEntry is either a subtree, or the pair of a key.
+type hamtEntry struct {
+	node *hamtNode
+
+	hash uint64
+	key  HashKey
+	pair HashPair
+}
+
+// hash mixes both fields of the key, so that the keys of different types and
+// of consecutive integers spread over the trie.
+func (self HashKey) hash() uint64 {
+	h := self.Value
+	for i := 0; i < len(self.Type); i++ {
+		h = (h ^ uint64(self.Type[i])) * 1099511628211
+	}
+
+	// splitmix64 finalizer
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+
+	return h
+}
+
+func (self hamt) get(key HashKey) (HashPair, bool) {
+	return self.lookup(key.hash(), key)
+}
+
+func (self hamt) lookup(hash uint64, key HashKey) (HashPair, bool) {
+	node := self.root
+
+	for shift := uint(0); node != nil; shift += hamtBits {
+		if shift >= hamtMaxShift {
+			for _, entry := range node.entries {
+				if entry.key == key {
+					return entry.pair, true
+				}
+			}
+			return HashPair{}, false
+		}
+
+		bit := uint32(1) << ((hash >> shift) & hamtMask)
+		if node.bitmap&bit == 0 {
+			return HashPair{}, false
+		}
+
+		entry := node.entries[bits.OnesCount32(node.bitmap&(bit-1))]
+		if entry.node == nil {
+			if entry.key == key {
+				return entry.pair, true
+			}
+			return HashPair{}, false
+		}
+
+		node = entry.node
+	}
+
+	return HashPair{}, false
+}
+
+func (self hamt) set(key HashKey, pair HashPair) hamt {
+	return self.insert(key.hash(), key, pair)
+}
+
+func (self hamt) insert(hash uint64, key HashKey, pair HashPair) hamt {
+	root, added := setInHamtNode(self.root, 0, hamtEntry{hash: hash, key: key, pair: pair})
+	if added {
+		return hamt{count: self.count + 1, root: root}
+	}
+
+	return hamt{count: self.count, root: root}
+}
+
+func setInHamtNode(node *hamtNode, shift uint, entry hamtEntry) (*hamtNode, bool) {
+	if node == nil {
+		node = &hamtNode{}
+	}
+
+	if shift >= hamtMaxShift {
+		for i, existing := range node.entries {
+			if existing.key == entry.key {
+				return node.replaced(i, entry), false
+			}
+		}
+		return node.inserted(len(node.entries), 0, entry), true
+	}
+
+	bit := uint32(1) << ((entry.hash >> shift) & hamtMask)
+	index := bits.OnesCount32(node.bitmap & (bit - 1))
+
+	if node.bitmap&bit == 0 {
+		return node.inserted(index, bit, entry), true
+	}
+
+	existing := node.entries[index]
+	switch {
+	case existing.node != nil:
+		child, added := setInHamtNode(existing.node, shift+hamtBits, entry)
+		return node.replaced(index, hamtEntry{node: child}), added
+	case existing.key == entry.key:
+		return node.replaced(index, entry), false
+	default:
+		// two keys share this slot, they move down to a subtree
+		child, _ := setInHamtNode(nil, shift+hamtBits, existing)
+		child, _ = setInHamtNode(child, shift+hamtBits, entry)
+		return node.replaced(index, hamtEntry{node: child}), true
+	}
+}
+
+func (self hamt) delete(key HashKey) hamt {
+	return self.remove(key.hash(), key)
+}
+
+func (self hamt) remove(hash uint64, key HashKey) hamt {
+	root, removed := deleteFromHamtNode(self.root, 0, hash, key)
+	if removed {
+		return hamt{count: self.count - 1, root: root}
+	}
+
+	return self
+}
+
+// deleteFromHamtNode returns the node without key, nil once it is empty.
+func deleteFromHamtNode(node *hamtNode, shift uint, hash uint64, key HashKey) (*hamtNode, bool) {
+	if node == nil {
+		return nil, false
+	}
+
+	if shift >= hamtMaxShift {
+		for i, existing := range node.entries {
+			if existing.key == key {
+				return node.removed(i, 0), true
+			}
+		}
+		return node, false
+	}
+
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	if node.bitmap&bit == 0 {
+		return node, false
+	}
+
+	index := bits.OnesCount32(node.bitmap & (bit - 1))
+	existing := node.entries[index]
+
+	if existing.node == nil {
+		if existing.key != key {
+			return node, false
+		}
+		return node.removed(index, bit), true
+	}
+
+	child, removed := deleteFromHamtNode(existing.node, shift+hamtBits, hash, key)
+	if !removed {
+		return node, false
+	}
+	if child == nil {
+		return node.removed(index, bit), true
+	}
+
+	return node.replaced(index, hamtEntry{node: child}), true
+}
+
+// each calls fn on the pairs of the trie, in the order of their hashes.
+func (self hamt) each(fn func(HashPair)) {
+	self.root.each(fn)
+}
+
+func (self *hamtNode) each(fn func(HashPair)) {
+	if self == nil {
+		return
+	}
+
+	for _, entry := range self.entries {
+		if entry.node != nil {
+			entry.node.each(fn)
+		} else {
+			fn(entry.pair)
+		}
+	}
+}
+
+func (self *hamtNode) inserted(index int, bit uint32, entry hamtEntry) *hamtNode {
+	entries := make([]hamtEntry, len(self.entries)+1)
+	copy(entries, self.entries[:index])
+	entries[index] = entry
+	copy(entries[index+1:], self.entries[index:])
+
+	return &hamtNode{bitmap: self.bitmap | bit, entries: entries}
+}
+
+func (self *hamtNode) replaced(index int, entry hamtEntry) *hamtNode {
+	entries := make([]hamtEntry, len(self.entries))
+	copy(entries, self.entries)
+	entries[index] = entry
+
+	return &hamtNode{bitmap: self.bitmap, entries: entries}
+}
+
+func (self *hamtNode) removed(index int, bit uint32) *hamtNode {
+	if len(self.entries) == 1 {
+		return nil
+	}
+
+	entries := make([]hamtEntry, len(self.entries)-1)
+	copy(entries, self.entries[:index])
+	copy(entries[index:], self.entries[index+1:])
+
+	return &hamtNode{bitmap: self.bitmap &^ bit, entries: entries}
+}
@@ -0,0 +1,110 @@
+package object
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHamt(t *testing.T) {
+	var h hamt
+	versions := []hamt{}
+
+	for i := 0; i < 5000; i++ {
+		key := NewInteger(int64(i))
+		h = h.set(key.HashKey(), HashPair{Key: key, Value: NewInteger(int64(i * 2))})
+		if i%1000 == 0 {
+			versions = append(versions, h)
+		}
+	}
+
+	if h.count != 5000 {
+		t.Fatalf("hamt has count %d, want=5000", h.count)
+	}
+
+	for i := 0; i < 5000; i++ {
+		pair, ok := h.get(NewInteger(int64(i)).HashKey())
+		if !ok || pair.Value.(*Integer).Value != int64(i*2) {
+			t.Fatalf("wrong pair for %d: %v, %t", i, pair, ok)
+		}
+	}
+
+	// earlier versions are unchanged
+	for version, old := range versions {
+		if old.count != version*1000+1 {
+			t.Errorf("version %d has count %d", version, old.count)
+		}
+		if _, ok := old.get(NewInteger(4999).HashKey()); ok {
+			t.Errorf("version %d has a key added after it", version)
+		}
+	}
+
+	replaced := h.set(NewInteger(7).HashKey(), HashPair{Key: NewInteger(7), Value: TRUE})
+	if replaced.count != 5000 {
+		t.Errorf("replacing a pair changed the count to %d", replaced.count)
+	}
+
+	for i := 0; i < 5000; i += 2 {
+		h = h.delete(NewInteger(int64(i)).HashKey())
+	}
+	h = h.delete(NewInteger(-1).HashKey())
+
+	if h.count != 2500 {
+		t.Fatalf("hamt has count %d after deletes, want=2500", h.count)
+	}
+
+	seen := 0
+	h.each(func(pair HashPair) {
+		seen++
+		if pair.Key.(*Integer).Value%2 == 0 {
+			t.Errorf("deleted key %d still in the hamt", pair.Key.(*Integer).Value)
+		}
+	})
+	if seen != 2500 {
+		t.Errorf("each visited %d pairs, want=2500", seen)
+	}
+}
+
+func TestHamtCollisions(t *testing.T) {
+	var h hamt
+	const hash = 0xdeadbeef
+
+	keys := []HashKey{}
+	for i := 0; i < 3; i++ {
+		key := (&String{Value: fmt.Sprintf("key %d", i)}).HashKey()
+		keys = append(keys, key)
+		h = h.insert(hash, key, HashPair{Key: NewInteger(int64(i)), Value: NewInteger(int64(i))})
+	}
+
+	for i, key := range keys {
+		pair, ok := h.lookup(hash, key)
+		if !ok || pair.Value != NewInteger(int64(i)) {
+			t.Errorf("wrong pair for colliding key %d: %v, %t", i, pair, ok)
+		}
+	}
+
+	h = h.remove(hash, keys[1])
+	if _, ok := h.lookup(hash, keys[1]); ok || h.count != 2 {
+		t.Errorf("colliding key was not removed")
+	}
+	if _, ok := h.lookup(hash, keys[2]); !ok {
+		t.Errorf("removing a colliding key removed another one")
+	}
+}
+
+func TestHash(t *testing.T) {
+	one := NewHash(HashPair{Key: &String{Value: "a"}, Value: NewInteger(1)})
+	two := one.Set((&String{Value: "b"}).HashKey(), HashPair{Key: &String{Value: "b"}, Value: NewInteger(2)})
+	none := two.Delete((&String{Value: "a"}).HashKey()).Delete((&String{Value: "b"}).HashKey())
+
+	if one.Len() != 1 || two.Len() != 2 || none.Len() != 0 {
+		t.Errorf("wrong lengths: %d, %d, %d", one.Len(), two.Len(), none.Len())
+	}
+
+	if _, ok := one.Get((&String{Value: "b"}).HashKey()); ok {
+		t.Errorf("Set modified the hash it was called on")
+	}
+
+	if none.Inspect() != "{}" {
+		t.Errorf("empty hash inspects as %s", none.Inspect())
+	}
+}
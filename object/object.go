@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/code"
 	"hash/fnv"
+	"io"
 	"log"
 	"strings"
 )
@@ -20,6 +22,17 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
+)
+
+// The null and boolean singletons are shared by every backend, so that
+// comparing them by identity works the same in the evaluator, the builtins and the vm.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
 )
 
 type ObjectType string
@@ -35,6 +48,30 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// The integers from MinSmallInteger to MaxSmallInteger are interned: they are
+// what loops count and index with, and integers are never mutated.
+const (
+	MinSmallInteger = -128
+	MaxSmallInteger = 1024
+)
+
+var smallIntegers = func() []*Integer {
+	integers := make([]*Integer, MaxSmallInteger-MinSmallInteger+1)
+	for i := range integers {
+		integers[i] = &Integer{Value: int64(i + MinSmallInteger)}
+	}
+	return integers
+}()
+
+// NewInteger returns the integer of value, interned when it is small.
+func NewInteger(value int64) *Integer {
+	if value >= MinSmallInteger && value <= MaxSmallInteger {
+		return smallIntegers[value-MinSmallInteger]
+	}
+
+	return &Integer{Value: value}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -61,18 +98,27 @@ type Error struct {
 func (self *Error) Type() ObjectType { return ERROR_OBJ }
 func (self *Error) Inspect() string  { return "ERROR: " + self.Message }
 
+// Error lets the vm return Monkey runtime errors as Go errors.
+func (self *Error) Error() string { return self.Message }
+
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Scope      *ast.Scope // the resolved scope of the body, nil when the function was not resolved
+	Name       string     // the name the literal was bound to by a let, empty for an anonymous function
 }
 
 func (self *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (self *Function) Inspect() string {
+	return inspectFunction(self.Parameters, self.Body)
+}
+
+func inspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 	var params []string
 
-	for _, param := range self.Parameters {
+	for _, param := range parameters {
 		params = append(params, param.String())
 	}
 
@@ -80,12 +126,62 @@ func (self *Function) Inspect() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(self.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
 
+// CompiledFunction is a function literal compiled to bytecode.
+// It keeps its literal so that it inspects like a Function.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	Literal       *ast.FunctionLiteral
+	Name          string // the let binding the literal, empty for an anonymous function
+	// LocalNames and FreeNames map local slots and free variables back to
+	// names, for runtime error messages
+	LocalNames []string
+	FreeNames  []string
+}
+
+func (self *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (self *CompiledFunction) Inspect() string {
+	return inspectFunction(self.Literal.Parameters, self.Literal.Body)
+}
+
+// Closure is a CompiledFunction with the free variables it captured.
+// To Monkey code it is just a function.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (self *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (self *Closure) Inspect() string  { return self.Fn.Inspect() }
+
+// Cell holds a local of a compiled function once a closure captures it, in
+// its slot and in the Free of the closure, so that both see what a later let
+// binds it to, as closures of the evaluator share its environment. The Free
+// of a function referring to itself by name holds the closure itself instead.
+// Cells are never values of Monkey code.
+type Cell struct {
+	Value Object // nil until a let binds it
+}
+
+func (self *Cell) Type() ObjectType { return CELL_OBJ }
+func (self *Cell) Inspect() string {
+	if self.Value == nil {
+		return "cell()"
+	}
+	return "cell(" + self.Value.Inspect() + ")"
+}
+
+// MAX_STRING_LENGTH is the length in bytes of the longest string repeating
+// one builds, longer ones being an error rather than a crash.
+const MAX_STRING_LENGTH = 1 << 30
+
 type String struct {
 	Value string
 }
@@ -93,7 +189,17 @@ type String struct {
 func (self *String) Type() ObjectType { return STRING_OBJ }
 func (self *String) Inspect() string  { return self.Value }
 
-type BuiltinFunction func(args ...Object) Object
+// Runtime is handed to every builtin by the interpreter running it, for the
+// evaluation or the run of the vm the builtin is called in: builtins such as
+// map or sort call back into Monkey code through it, and puts prints to it.
+type Runtime interface {
+	// Call applies a callable object, a Function or a Builtin, to arguments.
+	Call(fn Object, args ...Object) Object
+	// Output is where builtins print.
+	Output() io.Writer
+}
+
+type BuiltinFunction func(runtime Runtime, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
@@ -102,8 +208,53 @@ type Builtin struct {
 func (self *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (self *Builtin) Inspect() string  { return "builtin function" }
 
+// Array is an immutable array: push, rest and slice share the elements of
+// the array they derive from rather than copying them.
 type Array struct {
-	Elements []Object
+	elements vector
+	// the array is the window [start, end) of elements, which lets rest and
+	// slice share the vector
+	start int
+	end   int
+}
+
+// NewArray creates an array of elements, which it keeps: they must not be
+// modified afterwards.
+func NewArray(elements []Object) *Array {
+	return &Array{elements: newVector(elements), end: len(elements)}
+}
+
+func (self *Array) Len() int {
+	return self.end - self.start
+}
+
+// Get returns the element at index, which must be in range.
+func (self *Array) Get(index int) Object {
+	return self.elements.get(self.start + index)
+}
+
+// Elements returns a copy of the elements of the array.
+func (self *Array) Elements() []Object {
+	return self.elements.appendTo(make([]Object, 0, self.Len()), self.start, self.end)
+}
+
+// Push returns an array with value after the elements of self.
+func (self *Array) Push(value Object) *Array {
+	elements := self.elements
+	if self.end < elements.count {
+		// the vector goes on past this array: overwrite, in a copy, the
+		// element that follows it
+		elements = elements.set(self.end, value)
+	} else {
+		elements = elements.push(value)
+	}
+
+	return &Array{elements: elements, start: self.start, end: self.end + 1}
+}
+
+// Slice returns the elements from start to end, which must be in range.
+func (self *Array) Slice(start int, end int) *Array {
+	return &Array{elements: self.elements, start: self.start + start, end: self.start + end}
 }
 
 func (self *Array) Type() ObjectType { return ARRAY_OBJ }
@@ -112,7 +263,7 @@ func (self *Array) Inspect() string {
 
 	var elements []string
 
-	for _, element := range self.Elements {
+	for _, element := range self.Elements() {
 		elements = append(elements, element.Inspect())
 	}
 
@@ -163,8 +314,48 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash is an immutable hash: Set and Delete return a new hash sharing most of
+// its structure with the old one. The zero value is an empty hash.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs hamt
+}
+
+// NewHash creates a hash of pairs, whose keys must be Hashable.
+func NewHash(pairs ...HashPair) *Hash {
+	hash := &Hash{}
+	for _, pair := range pairs {
+		hash.pairs = hash.pairs.set(pair.Key.(Hashable).HashKey(), pair)
+	}
+
+	return hash
+}
+
+func (self *Hash) Len() int {
+	return self.pairs.count
+}
+
+func (self *Hash) Get(key HashKey) (HashPair, bool) {
+	return self.pairs.get(key)
+}
+
+// Set returns a hash where key is bound to pair.
+func (self *Hash) Set(key HashKey, pair HashPair) *Hash {
+	return &Hash{pairs: self.pairs.set(key, pair)}
+}
+
+// Delete returns a hash without key.
+func (self *Hash) Delete(key HashKey) *Hash {
+	return &Hash{pairs: self.pairs.delete(key)}
+}
+
+// Pairs returns the pairs of the hash, in no particular order.
+func (self *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, self.Len())
+	self.pairs.each(func(pair HashPair) {
+		pairs = append(pairs, pair)
+	})
+
+	return pairs
 }
 
 func (self *Hash) Type() ObjectType { return HASH_OBJ }
@@ -174,7 +365,7 @@ func (self *Hash) Inspect() string {
 
 	var pairs []string
 
-	for _, pair := range self.Pairs {
+	for _, pair := range self.Pairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
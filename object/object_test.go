@@ -23,3 +23,20 @@ func TestStringHashKey(t *testing.T) {
 	}
 
 }
+
+func TestSmallIntegersAreInterned(t *testing.T) {
+	for _, value := range []int64{MinSmallInteger, 0, 1, MaxSmallInteger} {
+		if NewInteger(value) != NewInteger(value) {
+			t.Errorf("integer %d is not interned", value)
+		}
+		if NewInteger(value).Value != value {
+			t.Errorf("interned integer has the wrong value, got=%d, want=%d", NewInteger(value).Value, value)
+		}
+	}
+
+	for _, value := range []int64{MinSmallInteger - 1, MaxSmallInteger + 1} {
+		if NewInteger(value) == NewInteger(value) {
+			t.Errorf("integer %d is interned", value)
+		}
+	}
+}
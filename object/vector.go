@@ -0,0 +1,171 @@
+package object
+
+// vector is a persistent vector: a trie of 32-wide nodes plus a tail holding
+// the last elements, as in Clojure. Updates copy the path to the element they
+// change and share everything else, so that a vector is never modified once
+// built and pushing to it takes O(log32 n).
+type vector struct {
+	count int
+	shift uint // bits of the index consumed by the root level
+	root  *vectorNode
+	tail  []Object
+}
+
+const (
+	vectorBits  = 5
+	vectorWidth = 1 << vectorBits
+	vectorMask  = vectorWidth - 1
+)
+
+// vectorNode is an inner node when it has children, a leaf holding values otherwise.
+type vectorNode struct {
+	children []*vectorNode
+	values   []Object
+}
+
+var emptyVectorNode = &vectorNode{}
+
+// newVector builds a vector of elements, which it keeps: they must not be
+// modified afterwards.
+func newVector(elements []Object) vector {
+	v := vector{shift: vectorBits, root: emptyVectorNode}
+	if len(elements) == 0 {
+		return v
+	}
+
+	tailStart := (len(elements) - 1) &^ vectorMask
+	for start := 0; start < tailStart; start += vectorWidth {
+		v = v.withTail(elements[start : start+vectorWidth : start+vectorWidth])
+	}
+
+	return v.withTail(elements[tailStart:len(elements):len(elements)])
+}
+
+func (self vector) tailOffset() int {
+	if self.count < vectorWidth {
+		return 0
+	}
+
+	return (self.count - 1) &^ vectorMask
+}
+
+// leaf returns the values of the leaf, or the tail, holding index i.
+func (self vector) leaf(i int) []Object {
+	if i >= self.tailOffset() {
+		return self.tail
+	}
+
+	node := self.root
+	for level := self.shift; level > 0; level -= vectorBits {
+		node = node.children[(i>>level)&vectorMask]
+	}
+
+	return node.values
+}
+
+func (self vector) get(i int) Object {
+	return self.leaf(i)[i&vectorMask]
+}
+
+// appendTo appends the elements from start to end to out, a leaf at a time.
+func (self vector) appendTo(out []Object, start int, end int) []Object {
+	for i := start; i < end; {
+		values := self.leaf(i)
+		offset := i & vectorMask
+		n := min(len(values)-offset, end-i)
+
+		out = append(out, values[offset:offset+n]...)
+		i += n
+	}
+
+	return out
+}
+
+func (self vector) push(value Object) vector {
+	if len(self.tail) < vectorWidth {
+		tail := make([]Object, len(self.tail)+1, vectorWidth)
+		copy(tail, self.tail)
+		tail[len(self.tail)] = value
+
+		return vector{count: self.count + 1, shift: self.shift, root: self.root, tail: tail}
+	}
+
+	return self.withTail([]Object{value})
+}
+
+// withTail moves a full tail into the trie and starts a new one with values.
+func (self vector) withTail(values []Object) vector {
+	if self.count == 0 {
+		return vector{count: len(values), shift: self.shift, root: self.root, tail: values}
+	}
+
+	tailNode := &vectorNode{values: self.tail}
+	shift := self.shift
+
+	var root *vectorNode
+	if (self.count >> vectorBits) > (1 << self.shift) {
+		// the trie is full, it grows a level
+		root = &vectorNode{children: []*vectorNode{self.root, newVectorPath(self.shift, tailNode)}}
+		shift += vectorBits
+	} else {
+		root = self.pushTail(self.shift, self.root, tailNode)
+	}
+
+	return vector{count: self.count + len(values), shift: shift, root: root, tail: values}
+}
+
+func (self vector) pushTail(level uint, parent *vectorNode, tailNode *vectorNode) *vectorNode {
+	index := ((self.count - 1) >> level) & vectorMask
+
+	node := &vectorNode{children: make([]*vectorNode, max(len(parent.children), index+1))}
+	copy(node.children, parent.children)
+
+	if level == vectorBits {
+		node.children[index] = tailNode
+	} else if index < len(parent.children) {
+		node.children[index] = self.pushTail(level-vectorBits, parent.children[index], tailNode)
+	} else {
+		node.children[index] = newVectorPath(level-vectorBits, tailNode)
+	}
+
+	return node
+}
+
+func newVectorPath(level uint, node *vectorNode) *vectorNode {
+	if level == 0 {
+		return node
+	}
+
+	return &vectorNode{children: []*vectorNode{newVectorPath(level-vectorBits, node)}}
+}
+
+// set returns a vector whose element i, which must exist, is value.
+func (self vector) set(i int, value Object) vector {
+	if i >= self.tailOffset() {
+		tail := make([]Object, len(self.tail), vectorWidth)
+		copy(tail, self.tail)
+		tail[i&vectorMask] = value
+
+		return vector{count: self.count, shift: self.shift, root: self.root, tail: tail}
+	}
+
+	return vector{count: self.count, shift: self.shift, root: setInVectorNode(self.shift, self.root, i, value), tail: self.tail}
+}
+
+func setInVectorNode(level uint, node *vectorNode, i int, value Object) *vectorNode {
+	if level == 0 {
+		values := make([]Object, len(node.values))
+		copy(values, node.values)
+		values[i&vectorMask] = value
+
+		return &vectorNode{values: values}
+	}
+
+	children := make([]*vectorNode, len(node.children))
+	copy(children, node.children)
+
+	index := (i >> level) & vectorMask
+	children[index] = setInVectorNode(level-vectorBits, children[index], i, value)
+
+	return &vectorNode{children: children}
+}
@@ -0,0 +1,86 @@
+package object
+
+import "testing"
+
+func TestVector(t *testing.T) {
+	for _, size := range []int{0, 1, 31, 32, 33, 64, 1025, 32*32*32 + 7} {
+		elements := make([]Object, size)
+		for i := range elements {
+			elements[i] = NewInteger(int64(i))
+		}
+
+		built := newVector(append([]Object{}, elements...))
+
+		pushed := vector{shift: vectorBits, root: emptyVectorNode}
+		for _, element := range elements {
+			pushed = pushed.push(element)
+		}
+
+		for _, v := range []vector{built, pushed} {
+			if v.count != size {
+				t.Fatalf("vector of %d elements has count %d", size, v.count)
+			}
+
+			for i, element := range elements {
+				if v.get(i) != element {
+					t.Fatalf("vector of %d elements: element %d is %v", size, i, v.get(i))
+				}
+			}
+
+			all := v.appendTo(nil, 0, size)
+			if len(all) != size {
+				t.Fatalf("vector of %d elements: appendTo returned %d elements", size, len(all))
+			}
+		}
+	}
+}
+
+func TestVectorIsPersistent(t *testing.T) {
+	v := vector{shift: vectorBits, root: emptyVectorNode}
+	for i := 0; i < 100; i++ {
+		v = v.push(NewInteger(int64(i)))
+	}
+
+	updated := v.set(3, NewInteger(-1)).set(99, NewInteger(-2))
+	grown := v.push(NewInteger(100))
+
+	if v.get(3) != NewInteger(3) || v.get(99) != NewInteger(99) || v.count != 100 {
+		t.Errorf("updating a vector modified it")
+	}
+
+	if updated.get(3) != NewInteger(-1) || updated.get(99) != NewInteger(-2) {
+		t.Errorf("set did not update the new vector")
+	}
+
+	if grown.get(100) != NewInteger(100) || grown.count != 101 {
+		t.Errorf("push did not grow the new vector")
+	}
+}
+
+func TestArraySharesItsElements(t *testing.T) {
+	array := NewArray([]Object{NewInteger(1), NewInteger(2), NewInteger(3)})
+
+	rest := array.Slice(1, array.Len())
+	head := array.Slice(0, 2)
+	pushedOnHead := head.Push(NewInteger(4))
+	pushedOnRest := rest.Push(NewInteger(5))
+
+	tests := []struct {
+		array    *Array
+		expected string
+	}{
+		{array, "[1, 2, 3]"},
+		{rest, "[2, 3]"},
+		{head, "[1, 2]"},
+		{pushedOnHead, "[1, 2, 4]"},
+		{pushedOnRest, "[2, 3, 5]"},
+		{&Array{}, "[]"},
+		{(&Array{}).Push(TRUE), "[true]"},
+	}
+
+	for _, tt := range tests {
+		if tt.array.Inspect() != tt.expected {
+			t.Errorf("array is %s, want=%s", tt.array.Inspect(), tt.expected)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package optimizer
+
+import "github.com/Neal-C/interpreter-in-go/ast"
+
+// countBindings counts how many times each name is bound by parameters and by
+// the lets of statements, without looking into nested function literals which
+// bind names in scopes of their own.
+func countBindings(statements []ast.Statement, parameters []*ast.Identifier) map[string]int {
+	counts := make(map[string]int)
+
+	for _, parameter := range parameters {
+		counts[parameter.Value]++
+	}
+
+	for _, statement := range statements {
+		ast.Inspect(statement, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.LetStatement:
+				counts[node.Name.Value]++
+			case *ast.FunctionLiteral:
+				return false
+			}
+			return true
+		})
+	}
+
+	return counts
+}
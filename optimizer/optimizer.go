@@ -0,0 +1,387 @@
+// Package optimizer rewrites a parsed program into an equivalent one that is
+// cheaper to run, for the evaluator as well as for the compiler.
+//
+// The passes are:
+//   - constant folding of prefix and infix expressions and of interpolated
+//     strings whose operands are literals
+//   - dead branch elimination for ifs whose condition is a literal
+//   - removal of the statements following a return
+//   - inlining of let bindings to an integer or boolean literal, where the
+//     name cannot be rebound
+package optimizer
+
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"strconv"
+	"strings"
+)
+
+// maxFoldedStringLength bounds the strings built by folding a repetition, so
+// that "-" * 1000000000 is left for the runtime instead of the optimizer.
+const maxFoldedStringLength = 1024
+
+// scope is what the optimizer knows about the bindings of a function body, or
+// of the program itself.
+type scope struct {
+	global bool
+
+	// bindings counts how many times each name is bound in the scope, by a
+	// parameter or a let, including lets nested in if blocks.
+	bindings map[string]int
+
+	// inline holds the literal value of the names bound once in the scope,
+	// from the point of their let statement onwards.
+	inline map[string]ast.Expression
+}
+
+// Optimize rewrites program in place and returns it.
+//
+// The lets of the program itself are only inlined in the statements that
+// follow them, not in function bodies: a function can outlive the program, as
+// it does in the REPL where a later line may rebind the global it refers to.
+func Optimize(program *ast.Program) *ast.Program {
+	global := &scope{
+		global:   true,
+		bindings: countBindings(program.Statements, nil),
+		inline:   make(map[string]ast.Expression),
+	}
+
+	program.Statements = global.optimizeStatements(program.Statements, true)
+
+	return program
+}
+
+// optimizeStatements optimizes a list of statements, top being true for the
+// statements of the scope itself rather than of an if block inside it.
+func (self *scope) optimizeStatements(statements []ast.Statement, top bool) []ast.Statement {
+	optimized := make([]ast.Statement, 0, len(statements))
+
+	for i, statement := range statements {
+		last := i == len(statements)-1
+
+		for _, stmt := range self.optimizeStatement(statement, last) {
+			optimized = append(optimized, stmt)
+
+			switch stmt := stmt.(type) {
+			case *ast.ReturnStatement:
+				// everything after it is unreachable
+				return optimized
+			case *ast.LetStatement:
+				if top && isInlinable(stmt.Value) && self.bindings[stmt.Name.Value] == 1 {
+					self.inline[stmt.Name.Value] = stmt.Value
+				}
+			}
+		}
+	}
+
+	return optimized
+}
+
+// optimizeStatement returns the statements replacing statement, which can be
+// none or several when an if with a literal condition is spliced in. last
+// tells whether statement gives its value to the enclosing block or program.
+func (self *scope) optimizeStatement(statement ast.Statement, last bool) []ast.Statement {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		statement.Value = self.optimizeExpression(statement.Value)
+	case *ast.ReturnStatement:
+		if statement.ReturnValue != nil {
+			statement.ReturnValue = self.optimizeExpression(statement.ReturnValue)
+		}
+	case *ast.ExpressionStatement:
+		statement.Expression = self.optimizeExpression(statement.Expression)
+
+		// blocks share the environment of their scope, so the statements
+		// of the branch taken can replace the if itself, as long as the
+		// if was not giving its value away
+		if ifExpression, ok := statement.Expression.(*ast.IfExpression); ok {
+			if _, ok := ifExpression.Condition.(*ast.Boolean); ok {
+				taken := ifExpression.Consequence.Statements
+				if !last || endsWithValue(taken) {
+					return taken
+				}
+			}
+		}
+	case *ast.BlockStatement:
+		statement.Statements = self.optimizeStatements(statement.Statements, false)
+	}
+
+	return []ast.Statement{statement}
+}
+
+func (self *scope) optimizeExpression(expression ast.Expression) ast.Expression {
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		if value, ok := self.inline[expression.Value]; ok {
+			return copyLiteral(value)
+		}
+	case *ast.PrefixExpression:
+		expression.Right = self.optimizeExpression(expression.Right)
+		return foldPrefix(expression)
+	case *ast.InfixExpression:
+		expression.Left = self.optimizeExpression(expression.Left)
+		expression.Right = self.optimizeExpression(expression.Right)
+		return foldInfix(expression)
+	case *ast.IfExpression:
+		return self.optimizeIf(expression)
+	case *ast.FunctionLiteral:
+		self.optimizeFunction(expression)
+	case *ast.CallExpression:
+		expression.Function = self.optimizeExpression(expression.Function)
+		self.optimizeExpressions(expression.Arguments)
+	case *ast.ArrayLiteral:
+		self.optimizeExpressions(expression.Elements)
+	case *ast.IndexExpression:
+		expression.Left = self.optimizeExpression(expression.Left)
+		expression.Index = self.optimizeExpression(expression.Index)
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(expression.Pairs))
+		for key, value := range expression.Pairs {
+			pairs[self.optimizeExpression(key)] = self.optimizeExpression(value)
+		}
+		expression.Pairs = pairs
+	case *ast.InterpolatedString:
+		self.optimizeExpressions(expression.Parts)
+		return foldInterpolatedString(expression)
+	}
+
+	return expression
+}
+
+func (self *scope) optimizeExpressions(expressions []ast.Expression) {
+	for i, expression := range expressions {
+		expressions[i] = self.optimizeExpression(expression)
+	}
+}
+
+// optimizeIf reduces an if whose condition is a literal to the branch taken:
+// its only expression when it has one, otherwise an if (true) over it, or an
+// empty if (false) which still evaluates to null.
+func (self *scope) optimizeIf(expression *ast.IfExpression) ast.Expression {
+	expression.Condition = self.optimizeExpression(expression.Condition)
+	expression.Consequence.Statements = self.optimizeStatements(expression.Consequence.Statements, false)
+	if expression.Alternative != nil {
+		expression.Alternative.Statements = self.optimizeStatements(expression.Alternative.Statements, false)
+	}
+
+	truthy, ok := literalTruthiness(expression.Condition)
+	if !ok {
+		return expression
+	}
+
+	taken := expression.Alternative
+	if truthy {
+		taken = expression.Consequence
+	}
+
+	if taken == nil {
+		return &ast.IfExpression{
+			Token:       expression.Token,
+			Condition:   booleanLiteral(false),
+			Consequence: &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}},
+		}
+	}
+
+	if len(taken.Statements) == 1 {
+		if statement, ok := taken.Statements[0].(*ast.ExpressionStatement); ok {
+			return statement.Expression
+		}
+	}
+
+	return &ast.IfExpression{Token: expression.Token, Condition: booleanLiteral(true), Consequence: taken}
+}
+
+// optimizeFunction optimizes a function body in a scope of its own, which
+// sees the literals of the enclosing function unless it binds the same names.
+func (self *scope) optimizeFunction(function *ast.FunctionLiteral) {
+	inner := &scope{
+		bindings: countBindings(function.Body.Statements, function.Parameters),
+		inline:   make(map[string]ast.Expression),
+	}
+
+	if !self.global {
+		for name, value := range self.inline {
+			if inner.bindings[name] == 0 {
+				inner.inline[name] = value
+			}
+		}
+	}
+
+	function.Body.Statements = inner.optimizeStatements(function.Body.Statements, true)
+}
+
+func foldPrefix(expression *ast.PrefixExpression) ast.Expression {
+	switch right := expression.Right.(type) {
+	case *ast.IntegerLiteral:
+		switch expression.Operator {
+		case "-":
+			return integerLiteral(-right.Value)
+		case "!":
+			return booleanLiteral(false)
+		}
+	case *ast.Boolean:
+		if expression.Operator == "!" {
+			return booleanLiteral(!right.Value)
+		}
+	case *ast.StringLiteral:
+		if expression.Operator == "!" {
+			return booleanLiteral(false)
+		}
+	}
+
+	return expression
+}
+
+// foldInfix computes the infix expressions of literals the way the evaluator
+// does. Those that would be runtime errors are left for the runtime to report,
+// and so is == on strings, which compares objects and not their values.
+func foldInfix(expression *ast.InfixExpression) ast.Expression {
+	switch left := expression.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := expression.Right.(*ast.IntegerLiteral)
+		if !ok {
+			break
+		}
+
+		switch expression.Operator {
+		case "+":
+			return integerLiteral(left.Value + right.Value)
+		case "-":
+			return integerLiteral(left.Value - right.Value)
+		case "*":
+			return integerLiteral(left.Value * right.Value)
+		case "/":
+			if right.Value != 0 {
+				return integerLiteral(left.Value / right.Value)
+			}
+		case "<":
+			return booleanLiteral(left.Value < right.Value)
+		case ">":
+			return booleanLiteral(left.Value > right.Value)
+		case "==":
+			return booleanLiteral(left.Value == right.Value)
+		case "!=":
+			return booleanLiteral(left.Value != right.Value)
+		}
+	case *ast.Boolean:
+		right, ok := expression.Right.(*ast.Boolean)
+		if !ok {
+			break
+		}
+
+		switch expression.Operator {
+		case "==":
+			return booleanLiteral(left.Value == right.Value)
+		case "!=":
+			return booleanLiteral(left.Value != right.Value)
+		}
+	case *ast.StringLiteral:
+		switch right := expression.Right.(type) {
+		case *ast.StringLiteral:
+			if expression.Operator == "+" {
+				return stringLiteral(left.Value + right.Value)
+			}
+		case *ast.IntegerLiteral:
+			if expression.Operator == "*" && right.Value >= 0 &&
+				(len(left.Value) == 0 || right.Value <= maxFoldedStringLength/int64(len(left.Value))) {
+				return stringLiteral(strings.Repeat(left.Value, int(right.Value)))
+			}
+		}
+	}
+
+	return expression
+}
+
+func foldInterpolatedString(expression *ast.InterpolatedString) ast.Expression {
+	var out strings.Builder
+
+	for _, part := range expression.Parts {
+		switch part := part.(type) {
+		case *ast.StringLiteral:
+			out.WriteString(part.Value)
+		case *ast.IntegerLiteral:
+			out.WriteString(strconv.FormatInt(part.Value, 10))
+		case *ast.Boolean:
+			out.WriteString(strconv.FormatBool(part.Value))
+		default:
+			return expression
+		}
+	}
+
+	return stringLiteral(out.String())
+}
+
+// literalTruthiness tells whether a literal condition is truthy, ok being
+// false when the condition is not a literal.
+func literalTruthiness(condition ast.Expression) (truthy bool, ok bool) {
+	switch condition := condition.(type) {
+	case *ast.Boolean:
+		return condition.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// endsWithValue tells whether statements give a value to the block holding
+// them, so that splicing them leaves the value of the block unchanged.
+func endsWithValue(statements []ast.Statement) bool {
+	if len(statements) == 0 {
+		return false
+	}
+
+	switch statements[len(statements)-1].(type) {
+	case *ast.ExpressionStatement, *ast.ReturnStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// isInlinable tells whether a let binding expression can be inlined. Strings
+// cannot: each evaluation of a literal is a new string, which == tells apart
+// from the one bound to the name.
+func isInlinable(expression ast.Expression) bool {
+	switch expression.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean:
+		return true
+	default:
+		return false
+	}
+}
+
+func copyLiteral(literal ast.Expression) ast.Expression {
+	switch literal := literal.(type) {
+	case *ast.IntegerLiteral:
+		copied := *literal
+		return &copied
+	case *ast.Boolean:
+		copied := *literal
+		return &copied
+	default:
+		return literal
+	}
+}
+
+func integerLiteral(value int64) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10)},
+		Value: value,
+	}
+}
+
+func booleanLiteral(value bool) *ast.Boolean {
+	literal := strconv.FormatBool(value)
+	var tokenType token.TokenType = token.FALSE
+	if value {
+		tokenType = token.TRUE
+	}
+
+	return &ast.Boolean{Token: token.Token{Type: tokenType, Literal: literal}, Value: value}
+}
+
+func stringLiteral(value string) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value}, Value: value}
+}
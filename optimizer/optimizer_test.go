@@ -0,0 +1,155 @@
+package optimizer
+
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"testing"
+)
+
+func TestConstantFolding(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"60 * 60 * 24", "86400"},
+		{`"prefix-" + "suffix"`, "prefix-suffix"},
+		{"-(2 + 3)", "-5"},
+		{"!true; !5; !!false", "falsefalsefalse"},
+		{"1 < 2 == true", "true"},
+		{"10 / 0", "(10 / 0)"},
+		{"x + 1 * 2", "(x + 2)"},
+		{`"ab" * 3`, "ababab"},
+		{`"ab" * 100000`, "(ab * 100000)"},
+		{`"ab" * 4611686018427387904`, "(ab * 4611686018427387904)"},
+		{`"" * 9223372036854775807`, ""},
+		{`"a" == "a"`, "(a == a)"},
+		{`"total: ${2 * 21}, ${true}"`, "total: 42, true"},
+		{`"hello ${name}"`, "hello ${name}"},
+		{"[1 + 1, f(2 * 2)][0]", "([2, f(4)][0]"},
+		{"fn(x) { x * (3 - 1) }", "fn(x) (x * 2)"},
+	}
+
+	for _, tt := range tests {
+		testOptimizedString(t, tt.input, tt.expected)
+	}
+}
+
+func TestDeadBranchElimination(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (true) { 1 } else { 2 }", "1"},
+		{"if (1 > 2) { 1 } else { 2 }", "2"},
+		{"if (false) { 1 }; 3", "3"},
+		{"if (false) { 1 }", "iffalse "},
+		{"let a = if (false) { 1 };", "let a = iffalse ;"},
+		{"if (true) { let a = 1; a }; 2", "let a = 1;a2"},
+		{"if (true) { let a = 1; }", "iftrue let a = 1;"},
+		{`if ("") { 1 }`, "1"},
+		{"if (x) { 1 } else { 2 }", "ifx 1else 2"},
+	}
+
+	for _, tt := range tests {
+		testOptimizedString(t, tt.input, tt.expected)
+	}
+}
+
+func TestUnreachableCodeRemoval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return 1; 2; 3", "return 1;"},
+		{"fn() { f(); return 1; f() }", "fn() f()return 1;"},
+		{"fn() { if (true) { return 1; } f() }", "fn() return 1;"},
+		{"fn() { if (x) { return 1; 2 } f() }", "fn() ifx return 1;f()"},
+	}
+
+	for _, tt := range tests {
+		testOptimizedString(t, tt.input, tt.expected)
+	}
+}
+
+func TestLetInlining(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 5; a * 2", "let a = 5;10"},
+		{"let day = 60 * 60 * 24; let week = day * 7; week", "let day = 86400;let week = 604800;604800"},
+		{"let debug = false; if (debug) { log() } else { 1 }", "let debug = false;1"},
+		// rebound names keep their identifier
+		{"let a = 5; let a = 6; a", "let a = 5;let a = 6;a"},
+		{"let a = 5; if (x) { let a = 6; }; a", "let a = 5;ifx let a = 6;a"},
+		// uses before the let are left alone
+		{"a; let a = 5;", "alet a = 5;"},
+		// lets that are not literals, or strings which == compares by identity
+		{"let a = [1]; a", "let a = [1];a"},
+		{`let s = "a"; s == s`, "let s = a;(s == s)"},
+		// globals are not inlined in functions, locals are
+		{"let a = 5; fn() { a }", "let a = 5;fn() a"},
+		{"fn() { let a = 5; fn() { a + 1 } }", "fn() let a = 5;fn() 6"},
+		{"fn() { let a = 5; fn(a) { a } }", "fn() let a = 5;fn(a) a"},
+		{"fn() { let a = 5; fn() { let a = 6; a } }", "fn() let a = 5;fn() let a = 6;6"},
+		{"fn(a) { let a = 5; a }", "fn(a) let a = 5;a"},
+	}
+
+	for _, tt := range tests {
+		testOptimizedString(t, tt.input, tt.expected)
+	}
+}
+
+func TestOptimizedProgramsEvaluateTheSame(t *testing.T) {
+	inputs := []string{
+		"let seconds = 60 * 60 * 24; seconds / 2",
+		`let name = "monkey"; "hello " + name + "!"`,
+		`let greet = fn(name) { let greeting = "hi"; "${greeting} ${name}" }; greet("you")`,
+		"let f = fn(x) { if (x > 1) { return x; } if (true) { let y = x * 10; } y + 1 }; f(1) + f(2)",
+		"let f = fn() { if (false) { 1 } }; f()",
+		"let f = fn() { 1; if (true) { 2 }; }; f()",
+		"let f = fn() { let a = 2; let g = fn(b) { a * b }; g(21) }; f()",
+		"let a = 1; let f = fn() { a }; let a = 2; f()",
+		"if (true) { return 5; }; 10",
+		"10 / 0",
+		`"" * -1`,
+		`"ab" * 4611686018427387904`,
+		`let s = "a"; s == s`,
+		"let x = 5; let f = fn(x) { x * 2 }; f(4) + x",
+		"let xs = map([1, 2, 3], fn(x) { x * (2 + 1) }); xs[2]",
+	}
+
+	for _, input := range inputs {
+		expected := evaluate(parse(input))
+		actual := evaluate(Optimize(parse(input)))
+
+		if expected.Inspect() != actual.Inspect() {
+			t.Errorf("%s: optimized program gives %s, want=%s", input, actual.Inspect(), expected.Inspect())
+		}
+	}
+}
+
+func testOptimizedString(t *testing.T, input string, expected string) {
+	t.Helper()
+
+	optimized := Optimize(parse(input))
+	if optimized.String() != expected {
+		t.Errorf("%s: optimized to %q, want=%q", input, optimized.String(), expected)
+	}
+}
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+func evaluate(program *ast.Program) object.Object {
+	evaluated := evaluator.Eval(program, object.NewEnvironment())
+	if evaluated == nil {
+		return object.NULL
+	}
+
+	return evaluated
+}
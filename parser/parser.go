@@ -26,11 +26,21 @@ const (
 	INDEX
 )
 
+// Error is a parse error with the position of the token it is about.
+type Error struct {
+	Message string
+	Start   token.Position
+	End     token.Position
+}
+
+func (self Error) Error() string { return self.Message }
+
 type Parser struct {
 	lexer          *lexer.Lexer
 	currentToken   token.Token
 	peekToken      token.Token
 	errors         []string
+	detailed       []Error
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -66,6 +76,7 @@ func New(lexer *lexer.Lexer) *Parser {
 	parser.registerPrefix(token.IF, parser.parseIfExpression)
 	parser.registerPrefix(token.FUNCTION, parser.parseFunctionLiteral)
 	parser.registerPrefix(token.STRING, parser.parseStringLiteral)
+	parser.registerPrefix(token.TEMPLATE, parser.parseInterpolatedString)
 	parser.registerPrefix(token.LBRACKET, parser.parseArrayLiteral)
 	parser.registerPrefix(token.LBRACE, parser.parseHashLiteral)
 
@@ -106,7 +117,11 @@ func (self *Parser) ParseProgram() *ast.Program {
 func (self *Parser) parseStatement() ast.Statement {
 	switch self.currentToken.Type {
 	case token.LET:
-		return self.parseLetStatement()
+		// a nil *ast.LetStatement would make a statement that is not nil
+		if stmt := self.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return self.parseReturnStatement()
 	default:
@@ -198,8 +213,7 @@ func (self *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(self.currentToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", self.currentToken.Literal)
-		self.errors = append(self.errors, msg)
+		self.reportError(self.currentToken, fmt.Sprintf("could not parse %q as integer", self.currentToken.Literal))
 		return nil
 	}
 
@@ -267,6 +281,8 @@ func (self *Parser) parseBlockStatement() *ast.BlockStatement {
 		self.nextToken()
 	}
 
+	block.Close = self.currentToken
+
 	return block
 }
 
@@ -359,6 +375,7 @@ func (self *Parser) parseCallExpression(function ast.Expression) ast.Expression
 	expression := &ast.CallExpression{Token: self.currentToken, Function: function}
 
 	expression.Arguments = self.parseExpressionList(token.RPAREN)
+	expression.Close = self.currentToken
 
 	return expression
 }
@@ -391,7 +408,7 @@ func (self *Parser) parseCallArguments() []ast.Expression {
 
 func (self *Parser) noPrefixParseFnError(tok token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function found for %s found", tok)
-	self.errors = append(self.errors, msg)
+	self.reportError(self.currentToken, msg)
 }
 
 func (self *Parser) currentTokenIs(t token.TokenType) bool {
@@ -416,9 +433,19 @@ func (self *Parser) Errors() []string {
 	return self.errors
 }
 
+// DetailedErrors returns the errors of Errors along with where they are.
+func (self *Parser) DetailedErrors() []Error {
+	return self.detailed
+}
+
+func (self *Parser) reportError(tok token.Token, message string) {
+	self.errors = append(self.errors, message)
+	self.detailed = append(self.detailed, Error{Message: message, Start: tok.Start, End: tok.End})
+}
+
 func (self *Parser) peekErrors(t token.TokenType) {
 	message := fmt.Sprintf("expected next token to be %s, got %s instead", t, self.peekToken.Type)
-	self.errors = append(self.errors, message)
+	self.reportError(self.peekToken, message)
 }
 
 var precedences = map[token.TokenType]int{
@@ -458,10 +485,54 @@ func (self *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: self.currentToken, Value: self.currentToken.Literal}
 }
 
+func (self *Parser) parseInterpolatedString() ast.Expression {
+	interpolated := &ast.InterpolatedString{Token: self.currentToken}
+
+	parts, ok := lexer.TemplateParts(self.currentToken.Literal)
+	if !ok {
+		self.reportError(self.currentToken, fmt.Sprintf("unterminated interpolation in string %q", self.currentToken.Literal))
+		return nil
+	}
+
+	// the literal starts after the opening quote
+	literalStart := self.currentToken.Start.Advance(`"`)
+
+	for _, part := range parts {
+		partStart := literalStart.Advance(self.currentToken.Literal[:part.Offset])
+
+		if !part.IsExpression {
+			literalToken := token.Token{Type: token.STRING, Literal: part.Value, Start: partStart, End: partStart.Advance(part.Value)}
+			interpolated.Parts = append(interpolated.Parts, &ast.StringLiteral{Token: literalToken, Value: part.Value})
+			continue
+		}
+
+		embedded := New(lexer.NewAt(part.Value, partStart))
+		expression := embedded.parseExpression(LOWEST)
+
+		if len(embedded.Errors()) == 0 && !embedded.peekTokenIs(token.EOF) {
+			embedded.peekErrors(token.EOF)
+		}
+
+		if len(embedded.Errors()) != 0 {
+			for _, embeddedError := range embedded.DetailedErrors() {
+				embeddedError.Message = fmt.Sprintf("in interpolation ${%s}: %s", part.Value, embeddedError.Message)
+				self.errors = append(self.errors, embeddedError.Message)
+				self.detailed = append(self.detailed, embeddedError)
+			}
+			return nil
+		}
+
+		interpolated.Parts = append(interpolated.Parts, expression)
+	}
+
+	return interpolated
+}
+
 func (self *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: self.currentToken}
 
 	array.Elements = self.parseExpressionList(token.RBRACKET)
+	array.Close = self.currentToken
 
 	return array
 }
@@ -503,6 +574,8 @@ func (self *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 		return nil
 	}
 
+	expr.Close = self.currentToken
+
 	return expr
 }
 
@@ -536,5 +609,7 @@ func (self *Parser) parseHashLiteral() ast.Expression {
 		return nil
 	}
 
+	hash.Close = self.currentToken
+
 	return hash
 }
@@ -708,6 +708,76 @@ func TestStringLiteralExpression(t *testing.T) {
 
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"sum: ${a + b}, name: ${user["name"]}"`
+
+	myLexer := lexer.New(input)
+	myParser := New(myLexer)
+	program := myParser.ParseProgram()
+	checkParserErrors(t, myParser)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	interpolated, ok := stmt.Expression.(*ast.InterpolatedString)
+
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.InterpolatedString, got = %T", stmt.Expression)
+	}
+
+	if len(interpolated.Parts) != 4 {
+		t.Fatalf("len(interpolated.Parts) not 4, got = %d", len(interpolated.Parts))
+	}
+
+	literal, ok := interpolated.Parts[0].(*ast.StringLiteral)
+	if !ok || literal.Value != "sum: " {
+		t.Errorf("interpolated.Parts[0] is not the literal %q, got = %#v", "sum: ", interpolated.Parts[0])
+	}
+
+	testInfixExpression(t, interpolated.Parts[1], "a", "+", "b")
+
+	literal, ok = interpolated.Parts[2].(*ast.StringLiteral)
+	if !ok || literal.Value != ", name: " {
+		t.Errorf("interpolated.Parts[2] is not the literal %q, got = %#v", ", name: ", interpolated.Parts[2])
+	}
+
+	index, ok := interpolated.Parts[3].(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("interpolated.Parts[3] is not *ast.IndexExpression, got = %T", interpolated.Parts[3])
+	}
+
+	testIdentifier(t, index.Left, "user")
+
+	if interpolated.String() != `sum: ${(a + b)}, name: ${(user[name]}` {
+		t.Errorf("interpolated.String() wrong, got = %q", interpolated.String())
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"${a"`, `unterminated interpolation in string "${a\""`},
+		{`"${}"`, "in interpolation ${}: no prefix parse function found for EOF found"},
+		{`"${a b}"`, "in interpolation ${a b}: expected next token to be EOF, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		myParser := New(lexer.New(tt.input))
+		myParser.ParseProgram()
+
+		errors := myParser.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong parser error for %q, expected = %q, got = %q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := `[1, 2 * 2, 3 + 3]`
 
@@ -939,3 +1009,83 @@ func TestParsingHashLiteralWithExpressions(t *testing.T) {
 		testFn(value)
 	}
 }
+
+func TestNodePositions(t *testing.T) {
+	input := `let f = fn(x) {
+  x[0] + g(1, 2)
+};
+"a ${b} c"`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	function := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	sum := function.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	interpolated := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InterpolatedString)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program.Statements[0], "1:1", "3:2"},
+		{function, "1:9", "3:2"},
+		{function.Body, "1:15", "3:2"},
+		{sum, "2:3", "2:17"},
+		{sum.Left, "2:3", "2:7"},
+		{sum.Right, "2:10", "2:17"},
+		{interpolated, "4:1", "4:11"},
+		{interpolated.Parts[1], "4:6", "4:7"},
+		{program, "1:1", "4:11"},
+	}
+
+	for _, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedStart || tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("%q spans %s-%s, want=%s-%s", tt.node.String(), tt.node.Pos(), tt.node.End(), tt.expectedStart, tt.expectedEnd)
+		}
+	}
+}
+
+func TestDetailedErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedStart string
+		expectedEnd   string
+	}{
+		{"let = 1", "expected next token to be IDENT, got = instead", "1:5", "1:6"},
+		{"1 +\n  )", "no prefix parse function found for ) found", "2:3", "2:4"},
+		{"f(1, 2", "expected next token to be ), got EOF instead", "1:7", "1:7"},
+		{`"x ${a b}"`, "in interpolation ${a b}: expected next token to be EOF, got IDENT instead", "1:8", "1:9"},
+	}
+
+	for _, tt := range tests {
+		parser := New(lexer.New(tt.input))
+		parser.ParseProgram()
+
+		errors := parser.DetailedErrors()
+		if len(errors) == 0 || len(errors) != len(parser.Errors()) {
+			t.Errorf("%q: %d detailed errors for %d errors", tt.input, len(errors), len(parser.Errors()))
+			continue
+		}
+
+		if errors[0].Message != tt.expected || errors[0].Start.String() != tt.expectedStart || errors[0].End.String() != tt.expectedEnd {
+			t.Errorf("%q: wrong error. got=%s %s-%s, want=%s %s-%s", tt.input, errors[0].Message, errors[0].Start, errors[0].End, tt.expected, tt.expectedStart, tt.expectedEnd)
+		}
+	}
+}
+
+func TestStatementsAfterErrors(t *testing.T) {
+	program := New(lexer.New("let = 1; let x = 2; x")).ParseProgram()
+
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok && let == nil {
+			t.Fatalf("a statement that did not parse was kept as a nil *ast.LetStatement")
+		}
+	}
+
+	// walking the program must not trip over what did not parse
+	ast.Inspect(program, func(node ast.Node) bool { return true })
+}
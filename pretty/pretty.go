@@ -0,0 +1,259 @@
+// Package pretty prints Monkey values for people to read: strings quoted,
+// nested arrays and hashes broken over lines when they do not fit on one,
+// huge values cut, and, on terminals, colors by type.
+package pretty
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DEFAULT_WIDTH is the line width nested values are broken to fit in.
+	DEFAULT_WIDTH = 80
+	// MAX_ELEMENTS is the number of elements of an array, or pairs of a
+	// hash, shown before the rest is cut.
+	MAX_ELEMENTS = 100
+	// MAX_STRING_LENGTH is the number of characters of a string shown before
+	// the rest is cut.
+	MAX_STRING_LENGTH = 1000
+	// INDENT is added in front of the elements of a value broken over lines.
+	INDENT = "  "
+)
+
+// The ANSI escape sequences values are colored with.
+const (
+	RESET          = "\x1b[0m"
+	NUMBER_COLOR   = "\x1b[33m"
+	STRING_COLOR   = "\x1b[32m"
+	KEYWORD_COLOR  = "\x1b[35m"
+	FUNCTION_COLOR = "\x1b[36m"
+	ERROR_COLOR    = "\x1b[31m"
+	CUT_COLOR      = "\x1b[2m"
+)
+
+// Options tunes how values are printed. Zero values stand for the defaults.
+type Options struct {
+	Color           bool // color values by type with ANSI escape sequences
+	Width           int  // the line width, DEFAULT_WIDTH when 0
+	MaxElements     int  // the elements of an array or pairs of a hash shown, MAX_ELEMENTS when 0
+	MaxStringLength int  // the characters of a string shown, MAX_STRING_LENGTH when 0
+}
+
+// Sprint returns value as it is printed with options. A function is printed
+// whole when it is value itself, as its parameters only inside an array or a
+// hash.
+func Sprint(value object.Object, options Options) string {
+	if options.Width == 0 {
+		options.Width = DEFAULT_WIDTH
+	}
+	if options.MaxElements == 0 {
+		options.MaxElements = MAX_ELEMENTS
+	}
+	if options.MaxStringLength == 0 {
+		options.MaxStringLength = MAX_STRING_LENGTH
+	}
+
+	if returnValue, ok := value.(*object.ReturnValue); ok {
+		value = returnValue.Value
+	}
+
+	if function, ok := value.(*object.Function); ok {
+		return printer{options: options}.color(FUNCTION_COLOR, function.Inspect())
+	}
+
+	return printer{options: options}.print(value, "", 0)
+}
+
+type printer struct {
+	options Options
+}
+
+// print returns value starting at column of a line, broken over lines
+// indented by indent when it does not fit in the width left.
+func (self printer) print(value object.Object, indent string, column int) string {
+	if self.options.Width < 0 || column+self.width(value) <= self.options.Width {
+		return self.printFlat(value, indent)
+	}
+
+	switch value := value.(type) {
+	case *object.Array:
+		var lines []string
+		for _, element := range self.elements(value) {
+			lines = append(lines, indent+INDENT+element(indent+INDENT))
+		}
+		return "[\n" + strings.Join(lines, ",\n") + "\n" + indent + "]"
+
+	case *object.Hash:
+		var lines []string
+		for _, pair := range self.pairs(value) {
+			lines = append(lines, indent+INDENT+pair(indent+INDENT))
+		}
+		return "{\n" + strings.Join(lines, ",\n") + "\n" + indent + "}"
+	}
+
+	return self.printFlat(value, indent)
+}
+
+// printFlat returns value on a single line, but for the nested values that
+// still have to be broken.
+func (self printer) printFlat(value object.Object, indent string) string {
+	switch value := value.(type) {
+	case *object.Integer:
+		return self.color(NUMBER_COLOR, value.Inspect())
+
+	case *object.Boolean, *object.Null:
+		return self.color(KEYWORD_COLOR, value.Inspect())
+
+	case *object.String:
+		return self.printString(value.Value)
+
+	case *object.Error:
+		return self.color(ERROR_COLOR, value.Inspect())
+
+	case *object.Function:
+		parameters := make([]string, len(value.Parameters))
+		for i, parameter := range value.Parameters {
+			parameters[i] = parameter.Value
+		}
+		return self.color(FUNCTION_COLOR, fmt.Sprintf("fn(%s) {...}", strings.Join(parameters, ", ")))
+
+	case *object.Builtin, *object.Closure, *object.CompiledFunction:
+		return self.color(FUNCTION_COLOR, value.Inspect())
+
+	case *object.Array:
+		var elements []string
+		for _, element := range self.elements(value) {
+			elements = append(elements, element(indent))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+
+	case *object.Hash:
+		var pairs []string
+		for _, pair := range self.pairs(value) {
+			pairs = append(pairs, pair(indent))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+
+	return value.Inspect()
+}
+
+func (self printer) printString(value string) string {
+	runes := []rune(value)
+	if len(runes) <= self.options.MaxStringLength {
+		return self.color(STRING_COLOR, strconv.Quote(value))
+	}
+
+	shown := strconv.Quote(string(runes[:self.options.MaxStringLength]))
+	return self.color(STRING_COLOR, shown) + self.color(CUT_COLOR, fmt.Sprintf("... %d more characters", len(runes)-self.options.MaxStringLength))
+}
+
+// elements returns the functions printing the elements of array shown, at
+// an indentation, followed by one telling how many were cut.
+func (self printer) elements(array *object.Array) []func(indent string) string {
+	var elements []func(indent string) string
+
+	for i := 0; i < array.Len() && i < self.options.MaxElements; i++ {
+		element := array.Get(i)
+		elements = append(elements, func(indent string) string {
+			return self.print(element, indent, len(indent))
+		})
+	}
+
+	if array.Len() > self.options.MaxElements {
+		elements = append(elements, self.cut(array.Len()-self.options.MaxElements))
+	}
+
+	return elements
+}
+
+// pairs is elements for the pairs of hash, sorted by key.
+func (self printer) pairs(hash *object.Hash) []func(indent string) string {
+	pairs := SortedPairs(hash)
+
+	var printers []func(indent string) string
+
+	for i := 0; i < len(pairs) && i < self.options.MaxElements; i++ {
+		pair := pairs[i]
+		printers = append(printers, func(indent string) string {
+			key := self.printFlat(pair.Key, indent)
+			return key + ": " + self.print(pair.Value, indent, len(indent)+self.width(pair.Key)+2)
+		})
+	}
+
+	if len(pairs) > self.options.MaxElements {
+		printers = append(printers, self.cut(len(pairs)-self.options.MaxElements))
+	}
+
+	return printers
+}
+
+// width is the number of characters value takes on a single line.
+func (self printer) width(value object.Object) int {
+	flat := printer{options: self.options}
+	flat.options.Color = false
+	flat.options.Width = -1
+
+	return utf8.RuneCountInString(flat.printFlat(value, ""))
+}
+
+func (self printer) cut(count int) func(indent string) string {
+	return func(indent string) string {
+		return self.color(CUT_COLOR, fmt.Sprintf("... %d more", count))
+	}
+}
+
+func (self printer) color(color string, text string) string {
+	if !self.options.Color {
+		return text
+	}
+
+	return color + text + RESET
+}
+
+// Summary is value on a single line, a function as its parameters only, as
+// debuggers and traces show it.
+func Summary(value object.Object) string {
+	if function, ok := value.(*object.Function); ok {
+		names := make([]string, len(function.Parameters))
+		for i, parameter := range function.Parameters {
+			names[i] = parameter.Value
+		}
+		return "fn(" + strings.Join(names, ", ") + ") {...}"
+	}
+
+	return Sprint(value, Options{Width: -1})
+}
+
+// SortedPairs returns the pairs of hash in the order they are printed in,
+// sorted by key.
+func SortedPairs(hash *object.Hash) []object.HashPair {
+	pairs := hash.Pairs()
+	sort.Slice(pairs, func(i, j int) bool {
+		return less(pairs[i].Key, pairs[j].Key)
+	})
+	return pairs
+}
+
+// less orders the keys of a hash: booleans, then integers, then strings.
+func less(left object.Object, right object.Object) bool {
+	if left.Type() != right.Type() {
+		return left.Type() < right.Type()
+	}
+
+	switch left := left.(type) {
+	case *object.Integer:
+		return left.Value < right.(*object.Integer).Value
+	case *object.Boolean:
+		return !left.Value && right.(*object.Boolean).Value
+	case *object.String:
+		return left.Value < right.(*object.String).Value
+	}
+
+	return false
+}
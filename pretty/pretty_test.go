@@ -0,0 +1,84 @@
+package pretty
+
+import (
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"strings"
+	"testing"
+)
+
+func TestSprint(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  Options
+		expected string
+	}{
+		{`1`, Options{}, `1`},
+		{`"a\b"`, Options{}, `"a\\b"`},
+		{`[1, "two", true, if (false) { 1 }]`, Options{}, `[1, "two", true, null]`},
+		{`{"b": 2, "a": 1, 3: "c", true: [], false: {}}`, Options{}, `{false: {}, true: [], 3: "c", "a": 1, "b": 2}`},
+		{`fn(x, y) { x + y }`, Options{}, "fn(x, y) {\n(x + y)\n}"},
+		{`[fn(x, y) { x + y }, len]`, Options{}, `[fn(x, y) {...}, builtin function]`},
+		{`1 + true`, Options{}, `ERROR: type mismatch: INTEGER + BOOLEAN`},
+		{`fn() { return "a" }()`, Options{}, `"a"`},
+		{`[1, 2, 3, 4]`, Options{Width: 8}, "[\n  1,\n  2,\n  3,\n  4\n]"},
+		{`[1, 2, 3, 4]`, Options{Width: 12}, `[1, 2, 3, 4]`},
+		{`[[1, 2], [3, 4, 5, 6, 7, 8]]`, Options{Width: 16}, "[\n  [1, 2],\n  [\n    3,\n    4,\n    5,\n    6,\n    7,\n    8\n  ]\n]"},
+		{`{"key": [1, 2, 3]}`, Options{Width: 16}, "{\n  \"key\": [\n    1,\n    2,\n    3\n  ]\n}"},
+		{`{"k": [1, 2, 3], "l": 1}`, Options{Width: 16}, "{\n  \"k\": [1, 2, 3],\n  \"l\": 1\n}"},
+		{`[1, 2, 3, 4, 5]`, Options{MaxElements: 2}, `[1, 2, ... 3 more]`},
+		{`{1: 1, 2: 2, 3: 3}`, Options{MaxElements: 1}, `{1: 1, ... 2 more}`},
+		{`"abcdef"`, Options{MaxStringLength: 2}, `"ab"... 4 more characters`},
+		{`"héllo"`, Options{MaxStringLength: 2}, `"hé"... 3 more characters`},
+		{`[1, "a", true, len]`, Options{Color: true},
+			"[\x1b[33m1\x1b[0m, \x1b[32m\"a\"\x1b[0m, \x1b[35mtrue\x1b[0m, \x1b[36mbuiltin function\x1b[0m]"},
+		{`1 + true`, Options{Color: true}, "\x1b[31mERROR: type mismatch: INTEGER + BOOLEAN\x1b[0m"},
+		{`["aaaa", "bbbb"]`, Options{Color: true, Width: 16}, "[\x1b[32m\"aaaa\"\x1b[0m, \x1b[32m\"bbbb\"\x1b[0m]"},
+	}
+
+	for _, tt := range tests {
+		if got := Sprint(eval(t, tt.input), tt.options); got != tt.expected {
+			t.Errorf("wrong output for %s.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn(x, y) { x + y }`, `fn(x, y) {...}`},
+		{`[[1, 2], {"a": fn() { 1 }}]`, `[[1, 2], {"a": fn() {...}}]`},
+		{`"a" * 100`, `"` + strings.Repeat("a", 100) + `"`},
+	}
+
+	for _, tt := range tests {
+		if got := Summary(eval(t, tt.input)); got != tt.expected {
+			t.Errorf("wrong summary for %s.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSprintHugeValues(t *testing.T) {
+	got := Sprint(eval(t, `map(range(0, 100000), fn(x) { "x" * 100 })`), Options{})
+
+	if lines := strings.Count(got, "\n"); lines != MAX_ELEMENTS+2 {
+		t.Errorf("a huge array takes %d lines, want=%d", lines, MAX_ELEMENTS+2)
+	}
+	if !strings.HasSuffix(got, "  ... 99900 more\n]") {
+		t.Errorf("a huge array does not end with what was cut: %q", got[len(got)-40:])
+	}
+
+	got = Sprint(eval(t, `"x" * 100000`), Options{})
+	if len(got) != MAX_STRING_LENGTH+len(`""... 99000 more characters`) {
+		t.Errorf("a huge string is %d characters long", len(got))
+	}
+}
+
+func eval(t *testing.T, input string) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return evaluator.Eval(program, object.NewEnvironment())
+}
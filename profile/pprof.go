@@ -0,0 +1,206 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// The fields of the messages of profile.proto, the format of pprof, that the
+// profile is written with.
+const (
+	PROFILE_SAMPLE_TYPE    = 1
+	PROFILE_SAMPLE         = 2
+	PROFILE_LOCATION       = 4
+	PROFILE_FUNCTION       = 5
+	PROFILE_STRING_TABLE   = 6
+	PROFILE_TIME_NANOS     = 9
+	PROFILE_DURATION_NANOS = 10
+	PROFILE_PERIOD_TYPE    = 11
+	PROFILE_PERIOD         = 12
+
+	VALUE_TYPE_TYPE = 1
+	VALUE_TYPE_UNIT = 2
+
+	SAMPLE_LOCATION_ID = 1
+	SAMPLE_VALUE       = 2
+
+	LOCATION_ID   = 1
+	LOCATION_LINE = 4
+
+	LINE_FUNCTION_ID = 1
+	LINE_LINE        = 2
+
+	FUNCTION_ID          = 1
+	FUNCTION_NAME        = 2
+	FUNCTION_SYSTEM_NAME = 3
+	FUNCTION_FILENAME    = 4
+	FUNCTION_START_LINE  = 5
+)
+
+// The wire types of protobuf fields.
+const (
+	VARINT           = 0
+	LENGTH_DELIMITED = 2
+)
+
+// Write writes the profile to out, gzipped as pprof writes them. Each sample
+// has two values, the number of times the stack was sampled and the time it
+// stands for.
+func (self *Profiler) Write(out io.Writer) error {
+	zipped := gzip.NewWriter(out)
+	if _, err := zipped.Write(self.encode()); err != nil {
+		return err
+	}
+	return zipped.Close()
+}
+
+// encode returns the profile as a Profile message.
+func (self *Profiler) encode() []byte {
+	table := newStringTable()
+	profile := &encoder{}
+
+	valueType := func(field int, kind string, unit string) {
+		profile.message(field, func(message *encoder) {
+			message.int64(VALUE_TYPE_TYPE, table.index(kind))
+			message.int64(VALUE_TYPE_UNIT, table.index(unit))
+		})
+	}
+	valueType(PROFILE_SAMPLE_TYPE, "samples", "count")
+	valueType(PROFILE_SAMPLE_TYPE, "cpu", "nanoseconds")
+
+	samples := make([]*sample, 0, len(self.samples))
+	for _, sample := range self.samples {
+		samples = append(samples, sample)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return lessLocations(samples[i].locations, samples[j].locations)
+	})
+	for _, sample := range samples {
+		profile.message(PROFILE_SAMPLE, func(message *encoder) {
+			message.packed(SAMPLE_LOCATION_ID, sample.locations)
+			message.packed(SAMPLE_VALUE, []uint64{uint64(sample.count), uint64(sample.count * int64(PERIOD))})
+		})
+	}
+
+	locations := make([]location, len(self.locations))
+	for location, id := range self.locations {
+		locations[id-1] = location
+	}
+	for i, location := range locations {
+		profile.message(PROFILE_LOCATION, func(message *encoder) {
+			message.uint64(LOCATION_ID, uint64(i+1))
+			message.message(LOCATION_LINE, func(line *encoder) {
+				line.uint64(LINE_FUNCTION_ID, location.function.id)
+				line.int64(LINE_LINE, int64(location.line))
+			})
+		})
+	}
+
+	functions := self.Functions()
+	sort.Slice(functions, func(i, j int) bool { return functions[i].id < functions[j].id })
+	for _, function := range functions {
+		profile.message(PROFILE_FUNCTION, func(message *encoder) {
+			message.uint64(FUNCTION_ID, function.id)
+			message.int64(FUNCTION_NAME, table.index(function.Name))
+			message.int64(FUNCTION_SYSTEM_NAME, table.index(function.Name))
+			message.int64(FUNCTION_FILENAME, table.index(self.file))
+			message.int64(FUNCTION_START_LINE, int64(function.Line))
+		})
+	}
+
+	profile.int64(PROFILE_TIME_NANOS, self.start.UnixNano())
+	profile.int64(PROFILE_DURATION_NANOS, int64(self.duration))
+	valueType(PROFILE_PERIOD_TYPE, "cpu", "nanoseconds")
+	profile.int64(PROFILE_PERIOD, int64(PERIOD))
+
+	// The strings go last, once every message has added its own.
+	for _, value := range table.values {
+		profile.bytes(PROFILE_STRING_TABLE, []byte(value))
+	}
+
+	return profile.data
+}
+
+func lessLocations(a []uint64, b []uint64) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// stringTable is the strings a profile refers to by index, the first one
+// being empty.
+type stringTable struct {
+	values  []string
+	indexes map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{values: []string{""}, indexes: map[string]int64{"": 0}}
+}
+
+func (self *stringTable) index(value string) int64 {
+	if index, ok := self.indexes[value]; ok {
+		return index
+	}
+
+	index := int64(len(self.values))
+	self.values = append(self.values, value)
+	self.indexes[value] = index
+	return index
+}
+
+// encoder appends the fields of a protobuf message to data. Fields of value 0
+// are left out, as they are the default.
+type encoder struct {
+	data []byte
+}
+
+func (self *encoder) varint(value uint64) {
+	for value >= 0x80 {
+		self.data = append(self.data, byte(value)|0x80)
+		value >>= 7
+	}
+	self.data = append(self.data, byte(value))
+}
+
+func (self *encoder) key(field int, wireType int) {
+	self.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (self *encoder) uint64(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+	self.key(field, VARINT)
+	self.varint(value)
+}
+
+func (self *encoder) int64(field int, value int64) {
+	self.uint64(field, uint64(value))
+}
+
+func (self *encoder) bytes(field int, value []byte) {
+	self.key(field, LENGTH_DELIMITED)
+	self.varint(uint64(len(value)))
+	self.data = append(self.data, value...)
+}
+
+// packed appends values as a packed repeated field.
+func (self *encoder) packed(field int, values []uint64) {
+	packed := &encoder{}
+	for _, value := range values {
+		packed.varint(value)
+	}
+	self.bytes(field, packed.data)
+}
+
+// message appends the message encode appends the fields of.
+func (self *encoder) message(field int, encode func(message *encoder)) {
+	message := &encoder{}
+	encode(message)
+	self.bytes(field, message.data)
+}
@@ -0,0 +1,256 @@
+// Package profile samples the Monkey call stack while a script runs, the
+// functions being named after their let and the samples pointing at the line
+// of the statement each frame is evaluating. The profile is written in the
+// protobuf format of pprof, for go tool pprof and the tools reading it; the
+// calls and cumulative time of each function are reported besides.
+//
+// The profiler is told of the statements and calls as an evaluator.Debugger.
+// A clock ticking every PERIOD makes a sample due, which is taken at the next
+// statement, call or return, a builtin running long being seen from the
+// statement after it. The time measured is the time the script ran for, not
+// the CPU time of the process.
+package profile
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PERIOD is the time between two samples.
+const PERIOD = 10 * time.Millisecond
+
+// The names of the frame of the script itself, outside of any function, and
+// of functions no let named. pprof takes names in angle brackets for C++
+// template arguments and cuts them, so they are bare words.
+const (
+	PROGRAM   = "program"
+	ANONYMOUS = "anonymous"
+)
+
+// Function is a Monkey function of the profile. The closures made by
+// evaluating the same literal are the same function.
+type Function struct {
+	Name       string        // the name of its let, ANONYMOUS without one
+	Line       int           // of the body of the function, 1 for the program
+	Calls      int           // how many times it was called
+	Cumulative time.Duration // the time spent in it and in what it called
+
+	id     uint64
+	active int // the calls of it on the stack
+}
+
+// frame is a call of a function on the stack.
+type frame struct {
+	function *Function
+	line     int // of the statement being evaluated, that of the function before the first one
+	start    time.Time
+}
+
+// location is a line in a function, what samples point at.
+type location struct {
+	function *Function
+	line     int
+}
+
+// sample is the stacks that were sampled the same, by their locations
+// innermost first.
+type sample struct {
+	locations []uint64
+	count     int64
+}
+
+// Profiler is an evaluator.Debugger sampling the call stack of a script.
+type Profiler struct {
+	// Now tells the time calls start and return at, time.Now unless changed.
+	Now func() time.Time
+
+	file      string
+	functions map[*ast.BlockStatement]*Function
+	program   *Function
+	stack     []*frame
+	locations map[location]uint64
+	samples   map[string]*sample
+	due       atomic.Int64
+	start     time.Time
+	duration  time.Duration
+	clock     <-chan time.Time // ticking every PERIOD unless a test set it
+	stop      chan struct{}
+}
+
+// New returns a profiler of the script read from file, the name the profile
+// gives its source.
+func New(file string) *Profiler {
+	program := &Function{Name: PROGRAM, Line: 1, Calls: 1, id: 1}
+
+	return &Profiler{
+		Now:       time.Now,
+		file:      file,
+		functions: map[*ast.BlockStatement]*Function{},
+		program:   program,
+		locations: map[location]uint64{},
+		samples:   map[string]*sample{},
+	}
+}
+
+// Start starts the clock making samples due, once the profiler is the
+// debugger of the evaluation.
+func (self *Profiler) Start() {
+	self.start = self.Now()
+	self.stack = []*frame{{function: self.program, line: 1, start: self.start}}
+	self.program.active = 1
+
+	clock := self.clock
+	var ticker *time.Ticker
+	if clock == nil {
+		ticker = time.NewTicker(PERIOD)
+		clock = ticker.C
+	}
+
+	self.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		for {
+			select {
+			case <-clock:
+				self.Tick()
+			case <-stop:
+				return
+			}
+		}
+	}(self.stop)
+}
+
+// Stop stops the clock once the evaluation is over, taking the sample due.
+func (self *Profiler) Stop() {
+	if self.stop != nil {
+		close(self.stop)
+		self.stop = nil
+	}
+
+	self.sample()
+	self.duration = self.Now().Sub(self.start)
+	self.program.Cumulative = self.duration
+	self.program.active = 0
+}
+
+// Tick makes a sample due, the clock calls it every PERIOD.
+func (self *Profiler) Tick() {
+	self.due.Add(1)
+}
+
+func (self *Profiler) Statement(statement ast.Statement, env *object.Environment) *object.Error {
+	self.sample()
+	self.stack[len(self.stack)-1].line = statement.Pos().Line
+	return nil
+}
+
+func (self *Profiler) Call(fn *object.Function, env *object.Environment) {
+	self.sample()
+
+	function, ok := self.functions[fn.Body]
+	if !ok {
+		name := fn.Name
+		if name == "" {
+			name = ANONYMOUS
+		}
+		function = &Function{Name: name, Line: fn.Body.Pos().Line, id: uint64(len(self.functions) + 2)}
+		self.functions[fn.Body] = function
+	}
+
+	function.Calls++
+	function.active++
+	self.stack = append(self.stack, &frame{function: function, line: function.Line, start: self.Now()})
+}
+
+func (self *Profiler) Return(fn *object.Function, result object.Object) *object.Error {
+	self.sample()
+
+	top := self.stack[len(self.stack)-1]
+	self.stack = self.stack[:len(self.stack)-1]
+
+	top.function.active--
+	if top.function.active == 0 {
+		top.function.Cumulative += self.Now().Sub(top.start)
+	}
+
+	return nil
+}
+
+// sample records the stack as many times as samples are due.
+func (self *Profiler) sample() {
+	due := self.due.Swap(0)
+	if due == 0 {
+		return
+	}
+
+	ids := make([]uint64, len(self.stack))
+	keys := make([]string, len(self.stack))
+	for i := range self.stack {
+		frame := self.stack[len(self.stack)-1-i]
+		ids[i] = self.location(frame.function, frame.line)
+		keys[i] = fmt.Sprint(ids[i])
+	}
+
+	key := strings.Join(keys, ",")
+	if _, ok := self.samples[key]; !ok {
+		self.samples[key] = &sample{locations: ids}
+	}
+	self.samples[key].count += due
+}
+
+// location returns the id of line in function.
+func (self *Profiler) location(function *Function, line int) uint64 {
+	key := location{function: function, line: line}
+	if id, ok := self.locations[key]; ok {
+		return id
+	}
+
+	id := uint64(len(self.locations) + 1)
+	self.locations[key] = id
+	return id
+}
+
+// Functions returns the functions called, the program first, by cumulative
+// time from the longest.
+func (self *Profiler) Functions() []*Function {
+	functions := []*Function{self.program}
+	for _, function := range self.functions {
+		functions = append(functions, function)
+	}
+
+	sort.SliceStable(functions[1:], func(i, j int) bool {
+		a, b := functions[1+i], functions[1+j]
+		if a.Cumulative != b.Cumulative {
+			return a.Cumulative > b.Cumulative
+		}
+		return a.id < b.id
+	})
+
+	return functions
+}
+
+// Report writes the calls and cumulative time of the functions to out, as a
+// table.
+func (self *Profiler) Report(out io.Writer) error {
+	if _, err := fmt.Fprintf(out, "%8s %12s  %s\n", "calls", "cumulative", "function"); err != nil {
+		return err
+	}
+
+	for _, function := range self.Functions() {
+		_, err := fmt.Fprintf(out, "%8d %12s  %s %s:%d\n",
+			function.Calls, function.Cumulative.Round(time.Microsecond), function.Name, self.file, function.Line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
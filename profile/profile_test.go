@@ -0,0 +1,319 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const SCRIPT = `let double = fn(x) {
+  x * 2
+};
+let twice = fn(f, x) {
+  f(f(x))
+};
+twice(double, 1);
+map([1, 2], fn(x) {
+  x
+});
+`
+
+// tickingProfiler ticks the clock of the profiler as many times as ticks says
+// once a statement of a line was told, instead of every PERIOD.
+type tickingProfiler struct {
+	*Profiler
+	ticks map[int]int
+}
+
+func (self tickingProfiler) Statement(statement ast.Statement, env *object.Environment) *object.Error {
+	self.Profiler.Statement(statement, env)
+	for i := 0; i < self.ticks[statement.Pos().Line]; i++ {
+		self.Tick()
+	}
+	return nil
+}
+
+// profile runs SCRIPT with a profiler whose clock ticks at the lines of ticks
+// and whose time goes a millisecond further each time it is told.
+func profile(t *testing.T, ticks map[int]int) *Profiler {
+	t.Helper()
+
+	monkeyParser := parser.New(lexer.New(SCRIPT))
+	program := monkeyParser.ParseProgram()
+	if len(monkeyParser.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", monkeyParser.Errors())
+	}
+
+	profiler := New("script.mk")
+	profiler.clock = make(chan time.Time)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	profiler.Now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	profiler.Start()
+	evaluator.EvalWithOptions(program, object.NewEnvironment(), evaluator.Options{Debugger: tickingProfiler{profiler, ticks}})
+	profiler.Stop()
+
+	return profiler
+}
+
+func TestFunctions(t *testing.T) {
+	profiler := profile(t, nil)
+
+	var got []string
+	for _, function := range profiler.Functions() {
+		got = append(got, fmt.Sprintf("%s:%d %d %s", function.Name, function.Line, function.Calls, function.Cumulative))
+	}
+
+	// Calls start and return at the 2nd to the 11th millisecond, the program
+	// runs from the 1st to the 12th.
+	expected := []string{"program:1 1 11ms", "twice:4 1 5ms", "double:1 2 2ms", "anonymous:8 2 2ms"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("functions wrong.\ngot=%q\nwant=%q", got, expected)
+	}
+}
+
+func TestRecursionIsTimedOnce(t *testing.T) {
+	profiler := New("loop.mk")
+	profiler.clock = make(chan time.Time)
+	now := time.Time{}
+	profiler.Now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	profiler.Start()
+	evaluator.EvalWithOptions(parser.New(lexer.New("let loop = fn(n) { if (n > 0) { loop(n - 1) } }; loop(3)")).ParseProgram(), object.NewEnvironment(), evaluator.Options{Debugger: profiler})
+	profiler.Stop()
+
+	loop := profiler.Functions()[1]
+	if loop.Calls != 4 {
+		t.Errorf("loop was called %d times, want=4", loop.Calls)
+	}
+	// From the first call, at the 2nd millisecond, to its return, at the 6th,
+	// the returns of the calls inside it not reading the time.
+	if loop.Cumulative != 4*time.Millisecond {
+		t.Errorf("loop took %s, want=4ms", loop.Cumulative)
+	}
+}
+
+func TestReport(t *testing.T) {
+	var out bytes.Buffer
+	if err := profile(t, nil).Report(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `   calls   cumulative  function
+       1         11ms  program script.mk:1
+       1          5ms  twice script.mk:4
+       2          2ms  double script.mk:1
+       2          2ms  anonymous script.mk:8
+`
+	if out.String() != expected {
+		t.Errorf("report wrong.\ngot:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	// The ticks at line 2, once in each call of double, are sampled when it
+	// returns, those at line 8 when map calls the anonymous function.
+	profiler := profile(t, map[int]int{2: 1, 8: 3})
+
+	var out bytes.Buffer
+	if err := profiler.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	decoded := decodeProfile(t, &out)
+
+	expectedSamples := []string{
+		"double:2 twice:5 program:7 = 2 20000000",
+		"program:8 = 3 30000000",
+	}
+	if !reflect.DeepEqual(decoded.samples, expectedSamples) {
+		t.Errorf("samples wrong.\ngot=%q\nwant=%q", decoded.samples, expectedSamples)
+	}
+
+	expectedFunctions := []string{"program script.mk:1", "twice script.mk:4", "double script.mk:1", "anonymous script.mk:8"}
+	if !reflect.DeepEqual(decoded.functions, expectedFunctions) {
+		t.Errorf("functions wrong.\ngot=%q\nwant=%q", decoded.functions, expectedFunctions)
+	}
+
+	expectedTypes := []string{"samples/count", "cpu/nanoseconds"}
+	if !reflect.DeepEqual(decoded.sampleTypes, expectedTypes) {
+		t.Errorf("sample types wrong.\ngot=%q\nwant=%q", decoded.sampleTypes, expectedTypes)
+	}
+	if decoded.period != int64(PERIOD) || decoded.periodType != "cpu/nanoseconds" {
+		t.Errorf("period wrong. got=%d %s, want=%d cpu/nanoseconds", decoded.period, decoded.periodType, PERIOD)
+	}
+	if decoded.duration != int64(11*time.Millisecond) {
+		t.Errorf("duration wrong. got=%d, want=%d", decoded.duration, 11*time.Millisecond)
+	}
+}
+
+// decodedProfile is what the tests check of a profile, functions and samples
+// printed with their names and lines.
+type decodedProfile struct {
+	sampleTypes []string
+	samples     []string
+	functions   []string
+	periodType  string
+	period      int64
+	duration    int64
+}
+
+// field is a field of a protobuf message, with its value when it is a varint
+// or its bytes when it is length-delimited.
+type field struct {
+	number int
+	value  uint64
+	bytes  []byte
+}
+
+func decodeProfile(t *testing.T, in io.Reader) decodedProfile {
+	t.Helper()
+
+	unzipped, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(unzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := decodeMessage(t, data)
+
+	var table []string
+	for _, field := range fields {
+		if field.number == PROFILE_STRING_TABLE {
+			table = append(table, string(field.bytes))
+		}
+	}
+	valueType := func(data []byte) string {
+		valueType := decodeMessage(t, data)
+		return table[first(valueType, VALUE_TYPE_TYPE).value] + "/" + table[first(valueType, VALUE_TYPE_UNIT).value]
+	}
+
+	decoded := decodedProfile{}
+	functions := map[uint64]string{}
+	locations := map[uint64]string{}
+	var samples []field
+
+	for _, field := range fields {
+		switch field.number {
+		case PROFILE_SAMPLE_TYPE:
+			decoded.sampleTypes = append(decoded.sampleTypes, valueType(field.bytes))
+		case PROFILE_SAMPLE:
+			samples = append(samples, field)
+		case PROFILE_FUNCTION:
+			function := decodeMessage(t, field.bytes)
+			functions[first(function, FUNCTION_ID).value] = table[first(function, FUNCTION_NAME).value]
+			decoded.functions = append(decoded.functions, fmt.Sprintf("%s %s:%d",
+				table[first(function, FUNCTION_NAME).value],
+				table[first(function, FUNCTION_FILENAME).value],
+				first(function, FUNCTION_START_LINE).value))
+		case PROFILE_LOCATION:
+			location := decodeMessage(t, field.bytes)
+			line := decodeMessage(t, first(location, LOCATION_LINE).bytes)
+			locations[first(location, LOCATION_ID).value] = fmt.Sprintf("%d:%d", first(line, LINE_FUNCTION_ID).value, first(line, LINE_LINE).value)
+		case PROFILE_PERIOD_TYPE:
+			decoded.periodType = valueType(field.bytes)
+		case PROFILE_PERIOD:
+			decoded.period = int64(field.value)
+		case PROFILE_DURATION_NANOS:
+			decoded.duration = int64(field.value)
+		}
+	}
+
+	for _, sample := range samples {
+		fields := decodeMessage(t, sample.bytes)
+		var frames []string
+		for _, id := range decodePacked(t, first(fields, SAMPLE_LOCATION_ID).bytes) {
+			var function uint64
+			var line int
+			fmt.Sscanf(locations[id], "%d:%d", &function, &line)
+			frames = append(frames, fmt.Sprintf("%s:%d", functions[function], line))
+		}
+		values := decodePacked(t, first(fields, SAMPLE_VALUE).bytes)
+		decoded.samples = append(decoded.samples, fmt.Sprintf("%s = %d %d", strings.Join(frames, " "), values[0], values[1]))
+	}
+	sort.Strings(decoded.samples)
+
+	return decoded
+}
+
+func decodeMessage(t *testing.T, data []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("bad key in %v", data)
+		}
+		data = data[n:]
+
+		field := field{number: int(key >> 3)}
+		switch key & 7 {
+		case VARINT:
+			field.value, n = binary.Uvarint(data)
+			if n <= 0 {
+				t.Fatalf("bad varint in %v", data)
+			}
+			data = data[n:]
+		case LENGTH_DELIMITED:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				t.Fatalf("bad length in %v", data)
+			}
+			field.bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+func decodePacked(t *testing.T, data []byte) []uint64 {
+	t.Helper()
+
+	var values []uint64
+	for len(data) > 0 {
+		value, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("bad packed varint in %v", data)
+		}
+		values = append(values, value)
+		data = data[n:]
+	}
+
+	return values
+}
+
+// first returns the first field numbered number, zero when there is none as
+// protobuf leaves fields of value 0 out.
+func first(fields []field, number int) field {
+	for _, field := range fields {
+		if field.number == number {
+			return field
+		}
+	}
+	return field{number: number}
+}
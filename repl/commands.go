@@ -0,0 +1,236 @@
+package repl
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/dot"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MAX_SUMMARY_WIDTH is the number of characters of a value :env shows.
+const MAX_SUMMARY_WIDTH = 60
+
+type command struct {
+	name     string
+	argument string // what the command expects after its name, if anything
+	help     string
+	run      func(self *session, argument string)
+}
+
+// commands is filled in by init, :help listing them refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{":help", "", "list the commands", (*session).help},
+		{":env", "", "list the bindings of the environment", (*session).listBindings},
+		{":type", "expr", "print the type of the value of expr", (*session).printType},
+		{":ast", "expr", "print the syntax tree of expr", (*session).printTree},
+		{":tokens", "expr", "print the tokens of expr", (*session).printTokens},
+		{":load", "file", "evaluate the script in file", (*session).load},
+		{":save", "file", "write the inputs of the session and their values to file", (*session).save},
+		{":reset", "", "forget the bindings and the inputs of the session", (*session).reset},
+		{":time", "expr", "evaluate expr and print how long it took", (*session).time},
+		{":builtins", "", "list the builtin functions", (*session).listBuiltins},
+		{":dot", "[expr]", "print the environment, or the syntax tree of expr, as a Graphviz graph", (*session).dot},
+	}
+}
+
+// command runs the command input starts with.
+func (self *session) command(input string) {
+	input = strings.TrimSpace(input)
+
+	name, argument := input, ""
+	if i := strings.IndexFunc(input, unicode.IsSpace); i >= 0 {
+		name, argument = input[:i], strings.TrimSpace(input[i:])
+	}
+
+	for _, command := range commands {
+		if command.name != name {
+			continue
+		}
+
+		if command.argument != "" && !strings.HasPrefix(command.argument, "[") && argument == "" {
+			fmt.Fprintf(self.out, "usage: %s %s\n", command.name, command.argument)
+			return
+		}
+
+		command.run(self, argument)
+		return
+	}
+
+	fmt.Fprintf(self.out, "unknown command %s, :help lists the commands\n", name)
+}
+
+func (self *session) help(argument string) {
+	for _, command := range commands {
+		fmt.Fprintf(self.out, "%-16s %s\n", strings.TrimSpace(command.name+" "+command.argument), command.help)
+	}
+}
+
+func (self *session) listBindings(argument string) {
+	for _, binding := range self.env.Bindings() {
+		fmt.Fprintf(self.out, "%s: %s = %s\n", binding.Name, binding.Value.Type(), summary(binding.Value))
+	}
+}
+
+func (self *session) printType(argument string) {
+	evaluated, ok := self.evalScratch(argument)
+	if !ok || evaluated == nil {
+		return
+	}
+
+	if _, isError := evaluated.(*object.Error); isError {
+		fmt.Fprintln(self.out, evaluated.Inspect())
+		return
+	}
+
+	fmt.Fprintln(self.out, evaluated.Type())
+}
+
+func (self *session) printTree(argument string) {
+	program, ok := self.parse(argument)
+	if !ok {
+		return
+	}
+
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+
+		fmt.Fprintf(self.out, "%s%s\n", strings.Repeat("  ", depth), describe(node))
+		depth++
+		return true
+	})
+}
+
+// describe is the type of node, followed by what it holds besides its
+// children.
+func describe(node ast.Node) string {
+	description := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+
+	switch node := node.(type) {
+	case *ast.Identifier:
+		description += " " + node.Value
+	case *ast.IntegerLiteral:
+		description += " " + node.Token.Literal
+	case *ast.Boolean:
+		description += " " + node.Token.Literal
+	case *ast.StringLiteral:
+		description += " " + fmt.Sprintf("%q", node.Value)
+	case *ast.PrefixExpression:
+		description += " " + node.Operator
+	case *ast.InfixExpression:
+		description += " " + node.Operator
+	}
+
+	return description
+}
+
+func (self *session) printTokens(argument string) {
+	monkeyLexer := lexer.New(argument)
+
+	for {
+		tok := monkeyLexer.NextToken()
+		if tok.Type == token.EOF {
+			return
+		}
+
+		fmt.Fprintf(self.out, "%-6s %-9s %q\n", tok.Start, tok.Type, tok.Literal)
+	}
+}
+
+func (self *session) load(argument string) {
+	source, err := os.ReadFile(argument)
+	if err != nil {
+		fmt.Fprintln(self.out, err)
+		return
+	}
+
+	self.run(string(source))
+}
+
+func (self *session) save(argument string) {
+	if err := os.WriteFile(argument, self.transcript.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(self.out, err)
+		return
+	}
+
+	fmt.Fprintf(self.out, "saved the session to %s\n", argument)
+}
+
+func (self *session) reset(argument string) {
+	self.env = object.NewEnvironment()
+	self.transcript.Reset()
+}
+
+func (self *session) time(argument string) {
+	start := time.Now()
+	evaluated, ok := self.evalScratch(argument)
+	elapsed := time.Since(start)
+
+	if !ok {
+		return
+	}
+
+	if evaluated != nil {
+		self.print(evaluated)
+	}
+	fmt.Fprintf(self.out, "took %s\n", elapsed)
+}
+
+func (self *session) listBuiltins(argument string) {
+	names := make([]string, 0, len(evaluator.Builtins()))
+	for name := range evaluator.Builtins() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	line := ""
+	for _, name := range names {
+		if line != "" && len(line)+len(name)+1 > 80 {
+			fmt.Fprintln(self.out, line)
+			line = ""
+		}
+
+		if line != "" {
+			line += " "
+		}
+		line += name
+	}
+	fmt.Fprintln(self.out, line)
+}
+
+func (self *session) dot(argument string) {
+	if argument == "" {
+		fmt.Fprint(self.out, dot.Environment(self.env))
+		return
+	}
+
+	program, ok := self.parse(argument)
+	if !ok {
+		return
+	}
+
+	fmt.Fprint(self.out, dot.AST(program))
+}
+
+// summary is value on a single line, cut when longer than MAX_SUMMARY_WIDTH.
+func summary(value object.Object) string {
+	text := strings.Join(strings.Fields(value.Inspect()), " ")
+	if runes := []rune(text); len(runes) > MAX_SUMMARY_WIDTH {
+		text = string(runes[:MAX_SUMMARY_WIDTH-3]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,123 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		inputs   []string
+		expected string
+	}{
+		{[]string{"let x = 5;", ":env"}, "x: INTEGER = 5\n"},
+		{[]string{`let s = "` + strings.Repeat("a", 70) + `";`, ":env"}, "s: STRING = " + strings.Repeat("a", 57) + "...\n"},
+		{[]string{":type 1"}, "INTEGER\n"},
+		{[]string{":type fn(x) { x }"}, "FUNCTION\n"},
+		{[]string{":type 1 + true"}, "ERROR: type mismatch: INTEGER + BOOLEAN\n"},
+		{[]string{":type let y = 1; y", ":type y"}, "INTEGER\nERROR: identifier not found: y\n"},
+		{[]string{"let y = 1;", ":type let y = true; y", ":type y"}, "BOOLEAN\nINTEGER\n"},
+		{[]string{":type"}, "usage: :type expr\n"},
+		{[]string{":ast -a * 2"}, "Program\n  ExpressionStatement\n    InfixExpression *\n      PrefixExpression -\n        Identifier a\n      IntegerLiteral 2\n"},
+		{[]string{":ast let"}, "\texpected next token to be IDENT, got EOF instead\n"},
+		{[]string{`:tokens x("a")`}, "1:1    IDENT     \"x\"\n1:2    (         \"(\"\n1:3    STRING    \"a\"\n1:6    )         \")\"\n"},
+		{[]string{"let x = 1;", ":reset", ":env", ":type x"}, "ERROR: identifier not found: x\n"},
+		{[]string{":builtins"}, ""},
+		{[]string{":nope"}, "unknown command :nope, :help lists the commands\n"},
+		{[]string{":dot 1"}, "digraph AST {\n\tnode [shape=box, fontname=\"monospace\"];\n\tn0 [label=\"Program\\n1:1\"];\n\tn1 [label=\"ExpressionStatement\\n1:1\"];\n\tn0 -> n1;\n\tn2 [label=\"IntegerLiteral\\n1\\n1:1\"];\n\tn1 -> n2;\n}\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		session := newSession(&out, Options{})
+
+		for _, input := range tt.inputs {
+			if strings.HasPrefix(input, COMMAND_PREFIX) {
+				session.command(input)
+			} else {
+				session.run(input)
+			}
+		}
+
+		if tt.inputs[0] == ":builtins" {
+			for _, name := range []string{"len", "puts", "zip"} {
+				if !strings.Contains(out.String(), name) {
+					t.Errorf(":builtins does not list %s: %s", name, out.String())
+				}
+			}
+			continue
+		}
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=%q", tt.inputs, tt.expected, out.String())
+		}
+	}
+}
+
+func TestHelpListsEveryCommand(t *testing.T) {
+	var out bytes.Buffer
+	newSession(&out, Options{}).command(":help")
+
+	for _, name := range []string{":help", ":env", ":type", ":ast", ":tokens", ":load", ":save", ":reset", ":time", ":builtins", ":dot"} {
+		if !strings.Contains(out.String(), name) {
+			t.Errorf(":help does not list %s", name)
+		}
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.mk")
+
+	var out bytes.Buffer
+	session := newSession(&out, Options{})
+	session.run("let double = fn(x) {\n    x * 2\n};")
+	session.run("double(21)")
+	session.run("let broken =")
+	session.command(":save " + path)
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("session not saved: %s", err)
+	}
+
+	expected := "let double = fn(x) {\n    x * 2\n};\ndouble(21)\n// 42\n"
+	if string(saved) != expected {
+		t.Errorf("wrong transcript.\nexpected=%q\ngot=%q", expected, saved)
+	}
+
+	out.Reset()
+	loaded := newSession(&out, Options{})
+	loaded.command(":load " + path)
+	loaded.command(":env")
+
+	if out.String() != "42\ndouble: FUNCTION = fn(x) { (x * 2) }\n" {
+		t.Errorf("wrong output after :load. got=%q", out.String())
+	}
+
+	out.Reset()
+	loaded.command(":load " + filepath.Join(t.TempDir(), "missing.mk"))
+	if !strings.Contains(out.String(), "no such file") {
+		t.Errorf("wrong output for a missing file: %q", out.String())
+	}
+}
+
+func TestTime(t *testing.T) {
+	var out bytes.Buffer
+	newSession(&out, Options{}).command(":time 1 + 2")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || lines[0] != "3" || !strings.HasPrefix(lines[1], "took ") {
+		t.Errorf("wrong output for :time: %q", out.String())
+	}
+
+	out.Reset()
+	session := newSession(&out, Options{})
+	session.command(":time let y = 1;")
+	session.command(":env")
+	if strings.Contains(out.String(), "y: ") {
+		t.Errorf(":time kept the binding it created: %q", out.String())
+	}
+}
@@ -0,0 +1,64 @@
+package repl
+
+import (
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/token"
+)
+
+// continuing are the tokens that cannot end a program, more must follow them.
+var continuing = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.LT:       true,
+	token.GT:       true,
+	token.BANG:     true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.COMMA:    true,
+	token.COLON:    true,
+	token.LET:      true,
+	token.FUNCTION: true,
+	token.IF:       true,
+	token.ELSE:     true,
+}
+
+// isIncomplete reports whether input is the start of a program rather than a
+// program, complete or wrong: a string is not closed, a parenthesis, bracket
+// or brace is left open, or the last token is an operator or a keyword
+// expecting more.
+func isIncomplete(input string) bool {
+	monkeyLexer := lexer.New(input)
+
+	depth := 0
+	var last token.Token
+
+	for {
+		tok := monkeyLexer.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+			if depth < 0 {
+				// more input cannot fix a closing one too many
+				return false
+			}
+		case token.STRING, token.TEMPLATE:
+			// the lexer stops unclosed strings past the end of input
+			if tok.End.Offset > len(input) {
+				return true
+			}
+		}
+
+		last = tok
+	}
+
+	return depth > 0 || continuing[last.Type]
+}
@@ -0,0 +1,39 @@
+package repl
+
+import "testing"
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", false},
+		{"1 + 2", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n    x\n}", false},
+		{"[1, 2,", true},
+		{"[1, 2", true},
+		{"puts(", true},
+		{"{\"a\": 1,\n\"b\":", true},
+		{"1 +", true},
+		{"let x =", true},
+		{"let", true},
+		{"if (a) { b } else", true},
+		{"!", true},
+		{`"hello`, true},
+		{`"hello ${name`, true},
+		{`"hello ${ "world" }`, true},
+		{`"hello ${ "world" }"`, false},
+		{"a }", false},
+		{"(a))(", false},
+		{"// a comment {", false},
+		{"f(1) // (", false},
+		{"let = 1", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) = %t, want=%t", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -2,45 +2,134 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
-	"github.com/Neal-C/interpreter-in-go/evaluator"
-	"github.com/Neal-C/interpreter-in-go/lexer"
-	"github.com/Neal-C/interpreter-in-go/object"
-	"github.com/Neal-C/interpreter-in-go/parser"
+	"github.com/Neal-C/interpreter-in-go/lineedit"
 	"io"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT asks for the rest of an incomplete input.
+const CONTINUATION_PROMPT = ".. "
+
+// COMMAND_PREFIX starts the commands of the REPL, :help lists them.
+const COMMAND_PREFIX = ":"
+
+// Options tunes how the REPL runs the lines it reads.
+type Options struct {
+	Optimize      bool   // run the optimizer on each line before evaluating it
+	DumpOptimized bool   // print the optimized program of each line, implies Optimize
+	HistoryFile   string // where the lines typed on a terminal are kept, if anywhere
+	Color         bool   // color the values printed, for a terminal
+}
+
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	StartWithOptions(in, out, Options{})
+}
+
+// StartWithOptions reads inputs from in until its end, writing the prompts,
+// the values and what puts prints to out.
+func StartWithOptions(in io.Reader, out io.Writer, options Options) {
+	session := newSession(out, options)
+
+	var reader lineReader = plainReader{bufio.NewScanner(in), out}
+	if file, ok := in.(*os.File); ok && lineedit.IsTerminal(file) {
+		editor := lineedit.New(file, out)
+		editor.Complete = session.complete
+		if options.HistoryFile != "" {
+			editor.SetHistoryFile(options.HistoryFile)
+		}
+		reader = editorReader{editor}
+	}
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, err := readInput(reader)
+		if err == lineedit.ErrInterrupted {
+			continue
+		}
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
-		monkeyLexer := lexer.New(line)
-		monkeyParser := parser.New(monkeyLexer)
-		program := monkeyParser.ParseProgram()
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
 
-		if len(monkeyParser.Errors()) != 0 {
-			printParseErrors(out, monkeyParser.Errors())
+		if strings.HasPrefix(input, COMMAND_PREFIX) {
+			session.command(input)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		session.run(input)
+	}
 
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+}
+
+// lineReader reads the lines of the REPL, returning io.EOF at the end of
+// input.
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// plainReader reads lines as they come, when the input is not a terminal.
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (self plainReader) readLine(prompt string) (string, error) {
+	io.WriteString(self.out, prompt)
+	if !self.scanner.Scan() {
+		if err := self.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return self.scanner.Text(), nil
+}
+
+// editorReader reads lines from a terminal with a line editor, remembering
+// them in its history.
+type editorReader struct {
+	editor *lineedit.Editor
+}
+
+func (self editorReader) readLine(prompt string) (string, error) {
+	line, err := self.editor.ReadLine(prompt)
+	if err == nil {
+		self.editor.AddHistory(line)
+	}
+
+	return line, err
+}
+
+// readInput reads a line, and the following ones while they do not complete
+// it. An empty line ends the input as it is, to get out of a mistake.
+func readInput(reader lineReader) (string, error) {
+	input, err := reader.readLine(PROMPT)
+	if err != nil {
+		return "", err
+	}
+
+	for isIncomplete(input) {
+		line, err := reader.readLine(CONTINUATION_PROMPT)
+		if err == io.EOF {
+			break
 		}
+		if err != nil {
+			return "", err
+		}
+
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+
+		input += "\n" + line
 	}
 
+	return input, nil
 }
 
 func printParseErrors(writer io.Writer, errors []string) {
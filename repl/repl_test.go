@@ -0,0 +1,44 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  Options
+		expected string
+	}{
+		{"1 + 2\n", Options{}, ">> 3\n>> "},
+		{`"a" + "b"` + "\n", Options{}, ">> \"ab\"\n>> "},
+		{"puts(\"hi\")\n", Options{}, ">> hi\nnull\n>> "},
+		{"let f = fn(x) {\nx * 2\n};\nf(4)\n", Options{}, ">> .. .. >> 8\n>> "},
+		{"\n  \n1\n", Options{}, ">> >> >> 1\n>> "},
+		{"let\n", Options{}, ">> .. \texpected next token to be IDENT, got EOF instead\n>> "},
+		{":type [1]\n", Options{}, ">> ARRAY\n>> "},
+		{"[1, true]\n", Options{Color: true}, ">> [\x1b[33m1\x1b[0m, \x1b[35mtrue\x1b[0m]\n>> "},
+		{"1 + 1\n", Options{DumpOptimized: true}, ">> 2\n2\n>> "},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		StartWithOptions(strings.NewReader(tt.input), &out, tt.options)
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
+
+func TestStartPrintsHugeValuesPretty(t *testing.T) {
+	var out bytes.Buffer
+	StartWithOptions(strings.NewReader("range(0, 1000)\n"), &out, Options{})
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n>> "), "\n")
+	if lines[0] != ">> [" || lines[1] != "  0," || lines[len(lines)-2] != "  ... 900 more" || lines[len(lines)-1] != "]" {
+		t.Errorf("a huge array is not broken over lines and cut: %q", out.String())
+	}
+}
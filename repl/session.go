@@ -0,0 +1,147 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/optimizer"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"github.com/Neal-C/interpreter-in-go/pretty"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// session is what the REPL keeps from one input to the next: the environment
+// inputs are evaluated in, and the transcript of the inputs and their values.
+type session struct {
+	out        io.Writer
+	options    Options
+	env        *object.Environment
+	transcript bytes.Buffer
+}
+
+func newSession(out io.Writer, options Options) *session {
+	return &session{out: out, options: options, env: object.NewEnvironment()}
+}
+
+// parse parses input, printing the errors when it does not parse.
+func (self *session) parse(input string) (*ast.Program, bool) {
+	monkeyParser := parser.New(lexer.New(input))
+	program := monkeyParser.ParseProgram()
+
+	if len(monkeyParser.Errors()) != 0 {
+		printParseErrors(self.out, monkeyParser.Errors())
+		return nil, false
+	}
+
+	return program, true
+}
+
+// eval evaluates input in the environment of the session. ok is false when
+// input does not parse.
+func (self *session) eval(input string) (evaluated object.Object, ok bool) {
+	return self.evalIn(input, self.env)
+}
+
+// evalScratch evaluates input in an environment enclosed by the one of the
+// session, so that the bindings it creates are dropped with it rather than
+// kept out of the transcript.
+func (self *session) evalScratch(input string) (evaluated object.Object, ok bool) {
+	return self.evalIn(input, object.NewEnclosedEnvironment(self.env))
+}
+
+func (self *session) evalIn(input string, env *object.Environment) (evaluated object.Object, ok bool) {
+	program, ok := self.parse(input)
+	if !ok {
+		return nil, false
+	}
+
+	if self.options.Optimize || self.options.DumpOptimized {
+		optimizer.Optimize(program)
+	}
+
+	if self.options.DumpOptimized {
+		io.WriteString(self.out, program.String())
+		io.WriteString(self.out, "\n")
+	}
+
+	evaluator.Resolve(program, env)
+	return evaluator.EvalWithOptions(program, env, evaluator.Options{Output: self.out}), true
+}
+
+// run evaluates input and prints its value. Both go to the transcript, the
+// value in comments so that the transcript can be loaded back.
+func (self *session) run(input string) {
+	evaluated, ok := self.eval(input)
+	if !ok {
+		return
+	}
+
+	self.transcript.WriteString(input)
+	self.transcript.WriteString("\n")
+
+	if evaluated != nil {
+		self.print(evaluated)
+
+		for _, line := range strings.Split(pretty.Sprint(evaluated, pretty.Options{}), "\n") {
+			fmt.Fprintf(&self.transcript, "// %s\n", line)
+		}
+	}
+}
+
+// print prints value pretty, in colors when the options say so.
+func (self *session) print(value object.Object) {
+	io.WriteString(self.out, pretty.Sprint(value, pretty.Options{Color: self.options.Color}))
+	io.WriteString(self.out, "\n")
+}
+
+// complete returns the names the identifier before cursor may be completed
+// with: bindings of the environment, builtins and keywords, or commands at the
+// start of the line.
+func (self *session) complete(line string, cursor int) (int, []string) {
+	start := cursor
+	for start > 0 && isIdentifierCharacter(line[start-1]) {
+		start--
+	}
+
+	var names []string
+	if start == 1 && strings.HasPrefix(line, COMMAND_PREFIX) {
+		start = 0
+		for _, command := range commands {
+			names = append(names, command.name)
+		}
+	} else {
+		for _, binding := range self.env.Bindings() {
+			names = append(names, binding.Name)
+		}
+		for name := range evaluator.Builtins() {
+			names = append(names, name)
+		}
+		names = append(names, token.Keywords()...)
+	}
+
+	word := line[start:cursor]
+	if word == "" {
+		return start, nil
+	}
+
+	var candidates []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) && !slices.Contains(candidates, name) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+
+	return start, candidates
+}
+
+func isIdentifierCharacter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
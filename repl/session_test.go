@@ -0,0 +1,38 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	var out bytes.Buffer
+	session := newSession(&out, Options{})
+	session.run("let length = 3; let lettuce = 4;")
+
+	tests := []struct {
+		line          string
+		cursor        int
+		expectedStart int
+		expected      []string
+	}{
+		{"le", 2, 0, []string{"len", "length", "let", "lettuce"}},
+		{"1 + lett", 8, 4, []string{"lettuce"}},
+		{"puts(le)", 7, 5, []string{"len", "length", "let", "lettuce"}},
+		{"ret", 3, 0, []string{"return"}},
+		{"fir", 3, 0, []string{"first"}},
+		{"zzz", 3, 0, nil},
+		{"1 + ", 4, 4, nil},
+		{":ty", 3, 0, []string{":type"}},
+		{":t", 2, 0, []string{":time", ":tokens", ":type"}},
+		{":type le", 8, 6, []string{"len", "length", "let", "lettuce"}},
+	}
+
+	for _, tt := range tests {
+		start, candidates := session.complete(tt.line, tt.cursor)
+		if start != tt.expectedStart || strings.Join(candidates, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("complete(%q, %d) = %d, %q, want=%d, %q", tt.line, tt.cursor, start, candidates, tt.expectedStart, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package resolver binds the identifiers of a program to the slots of the
+// scopes defining them, so that the evaluator finds their values by index
+// instead of looking up their names in every enclosing environment.
+package resolver
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/object"
+)
+
+type resolver struct {
+	scope    *ast.Scope
+	builtins map[string]*object.Builtin
+
+	errors     []string
+	unresolved map[string]bool
+}
+
+// Resolve resolves program against global, the scope of the environment it
+// will be evaluated in, which gains a slot for each of its top-level lets.
+//
+// The names of a scope are all known before any identifier is resolved, so a
+// function can refer to a let that follows it. Identifiers that are neither
+// bound in a scope nor builtins are left unresolved, to be looked up by name
+// at runtime: the REPL may bind them in a later line. They are reported as
+// errors, once per name, for callers that want to know before execution.
+func Resolve(program *ast.Program, global *ast.Scope, builtins map[string]*object.Builtin) []string {
+	self := &resolver{
+		scope:      global,
+		builtins:   builtins,
+		unresolved: make(map[string]bool),
+	}
+
+	self.declareStatements(program.Statements)
+	for _, statement := range program.Statements {
+		self.resolve(statement)
+	}
+
+	return self.errors
+}
+
+func (self *resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		self.resolve(node.Value)
+		self.resolveIdentifier(node.Name)
+	case *ast.ReturnStatement:
+		if node.ReturnValue != nil {
+			self.resolve(node.ReturnValue)
+		}
+	case *ast.ExpressionStatement:
+		if node.Expression != nil {
+			self.resolve(node.Expression)
+		}
+	case *ast.BlockStatement:
+		for _, statement := range node.Statements {
+			self.resolve(statement)
+		}
+	case *ast.Identifier:
+		self.resolveIdentifier(node)
+	case *ast.PrefixExpression:
+		self.resolve(node.Right)
+	case *ast.InfixExpression:
+		self.resolve(node.Left)
+		self.resolve(node.Right)
+	case *ast.IfExpression:
+		self.resolve(node.Condition)
+		self.resolve(node.Consequence)
+		if node.Alternative != nil {
+			self.resolve(node.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		self.resolveFunction(node)
+	case *ast.CallExpression:
+		self.resolve(node.Function)
+		for _, argument := range node.Arguments {
+			self.resolve(argument)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			self.resolve(element)
+		}
+	case *ast.IndexExpression:
+		self.resolve(node.Left)
+		self.resolve(node.Index)
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			self.resolve(key)
+			self.resolve(value)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			self.resolve(part)
+		}
+	}
+}
+
+func (self *resolver) resolveFunction(function *ast.FunctionLiteral) {
+	scope := ast.NewScope(self.scope)
+	for _, parameter := range function.Parameters {
+		scope.Define(parameter.Value)
+	}
+
+	outer := self.scope
+	self.scope = scope
+
+	self.declareStatements(function.Body.Statements)
+	for _, parameter := range function.Parameters {
+		self.resolveIdentifier(parameter)
+	}
+	self.resolve(function.Body)
+
+	self.scope = outer
+	function.Scope = scope
+}
+
+func (self *resolver) resolveIdentifier(identifier *ast.Identifier) {
+	identifier.Scope = nil
+
+	depth := 0
+	for scope := self.scope; scope != nil; scope = scope.Outer {
+		if slot, ok := scope.Lookup(identifier.Value); ok {
+			identifier.Scope = scope
+			identifier.Depth = depth
+			identifier.Slot = slot
+			return
+		}
+		depth++
+	}
+
+	if _, ok := self.builtins[identifier.Value]; ok {
+		return
+	}
+
+	if !self.unresolved[identifier.Value] {
+		self.unresolved[identifier.Value] = true
+		self.errors = append(self.errors, fmt.Sprintf("identifier not found: %s", identifier.Value))
+	}
+}
+
+// declareStatements defines in the current scope the names bound by the lets
+// of statements, those nested in blocks included but not those of functions.
+func (self *resolver) declareStatements(statements []ast.Statement) {
+	for _, statement := range statements {
+		self.declare(statement)
+	}
+}
+
+func (self *resolver) declare(node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			self.scope.Define(node.Name.Value)
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return true
+	})
+}
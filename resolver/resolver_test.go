@@ -0,0 +1,178 @@
+package resolver
+
+import (
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"testing"
+)
+
+var testBuiltins = map[string]*object.Builtin{"len": {}}
+
+type expectedIdentifier struct {
+	name     string
+	resolved bool
+	depth    int
+	slot     int
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []expectedIdentifier
+	}{
+		{
+			"let a = 1; let b = a;",
+			[]expectedIdentifier{{"a", true, 0, 0}, {"b", true, 0, 1}, {"a", true, 0, 0}},
+		},
+		{
+			"let f = fn(x, y) { let z = x; fn() { y + z + f } };",
+			[]expectedIdentifier{
+				{"f", true, 0, 0},
+				{"x", true, 0, 0}, {"y", true, 0, 1},
+				{"z", true, 0, 2}, {"x", true, 0, 0},
+				{"y", true, 1, 1}, {"z", true, 1, 2}, {"f", true, 2, 0},
+			},
+		},
+		{
+			// lets are known before identifiers are resolved, blocks included
+			"fn() { g(); let g = fn() { h }; if (true) { let h = 1; } };",
+			[]expectedIdentifier{
+				{"g", true, 0, 0}, {"g", true, 0, 0}, {"h", true, 1, 1}, {"h", true, 0, 1},
+			},
+		},
+		{
+			"len(missing)",
+			[]expectedIdentifier{{"len", false, 0, 0}, {"missing", false, 0, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		Resolve(program, ast.NewScope(nil), testBuiltins)
+
+		identifiers := collectIdentifiers(program)
+		if len(identifiers) != len(tt.expected) {
+			t.Fatalf("%s: wrong number of identifiers. got=%d, want=%d", tt.input, len(identifiers), len(tt.expected))
+		}
+
+		for i, expected := range tt.expected {
+			identifier := identifiers[i]
+
+			if identifier.Value != expected.name {
+				t.Errorf("%s: identifier %d is %s, want=%s", tt.input, i, identifier.Value, expected.name)
+				continue
+			}
+
+			if (identifier.Scope != nil) != expected.resolved {
+				t.Errorf("%s: identifier %d (%s) resolved=%t, want=%t", tt.input, i, identifier.Value, identifier.Scope != nil, expected.resolved)
+				continue
+			}
+
+			if expected.resolved && (identifier.Depth != expected.depth || identifier.Slot != expected.slot) {
+				t.Errorf("%s: identifier %d (%s) resolved to (%d, %d), want=(%d, %d)", tt.input, i, identifier.Value,
+					identifier.Depth, identifier.Slot, expected.depth, expected.slot)
+			}
+		}
+	}
+}
+
+func TestUnresolvedIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; a + len([])", nil},
+		{"foo + bar + foo", []string{"identifier not found: foo", "identifier not found: bar"}},
+		{"let f = fn(x) { x + y }", []string{"identifier not found: y"}},
+		{"let f = fn() { fn() { later } }; let later = 1;", nil},
+	}
+
+	for _, tt := range tests {
+		errors := Resolve(parse(tt.input), ast.NewScope(nil), testBuiltins)
+
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%s: wrong errors. got=%q, want=%q", tt.input, errors, tt.expected)
+			continue
+		}
+
+		for i, expected := range tt.expected {
+			if errors[i] != expected {
+				t.Errorf("%s: wrong error %d. got=%q, want=%q", tt.input, i, errors[i], expected)
+			}
+		}
+	}
+}
+
+func TestIncrementalGlobalScope(t *testing.T) {
+	global := ast.NewScope(nil)
+
+	if errors := Resolve(parse("let a = 1; let b = 2;"), global, testBuiltins); len(errors) != 0 {
+		t.Fatalf("unexpected errors: %q", errors)
+	}
+
+	program := parse("let c = b; let a = 3;")
+	if errors := Resolve(program, global, testBuiltins); len(errors) != 0 {
+		t.Fatalf("unexpected errors: %q", errors)
+	}
+
+	identifiers := collectIdentifiers(program)
+	slots := []int{2, 1, 0}
+	for i, slot := range slots {
+		if identifiers[i].Scope != global || identifiers[i].Slot != slot {
+			t.Errorf("identifier %s resolved to slot %d, want=%d", identifiers[i].Value, identifiers[i].Slot, slot)
+		}
+	}
+
+	if global.Len() != 3 {
+		t.Errorf("global scope has %d names, want=3", global.Len())
+	}
+}
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+// collectIdentifiers returns the identifiers of the program in source order,
+// the names of lets and the parameters of functions included.
+func collectIdentifiers(program *ast.Program) []*ast.Identifier {
+	var identifiers []*ast.Identifier
+
+	var collect func(node ast.Node)
+	collect = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			identifiers = append(identifiers, node.Name)
+			collect(node.Value)
+		case *ast.ExpressionStatement:
+			collect(node.Expression)
+		case *ast.BlockStatement:
+			for _, statement := range node.Statements {
+				collect(statement)
+			}
+		case *ast.Identifier:
+			identifiers = append(identifiers, node)
+		case *ast.InfixExpression:
+			collect(node.Left)
+			collect(node.Right)
+		case *ast.IfExpression:
+			collect(node.Condition)
+			collect(node.Consequence)
+		case *ast.FunctionLiteral:
+			identifiers = append(identifiers, node.Parameters...)
+			collect(node.Body)
+		case *ast.CallExpression:
+			collect(node.Function)
+			for _, argument := range node.Arguments {
+				collect(argument)
+			}
+		}
+	}
+
+	for _, statement := range program.Statements {
+		collect(statement)
+	}
+
+	return identifiers
+}
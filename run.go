@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/compiler"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/optimizer"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"github.com/Neal-C/interpreter-in-go/profile"
+	"github.com/Neal-C/interpreter-in-go/trace"
+	"github.com/Neal-C/interpreter-in-go/vm"
+	"io"
+	"os"
+)
+
+// runOptions are the flags that apply to whatever a script comes from.
+type runOptions struct {
+	optimize      bool
+	dumpOptimized bool   // print the optimized program before running it, implies optimize
+	trace         string // the file to write a JSON-lines trace of the evaluation to, none when empty
+	cpuProfile    string // the file to write a pprof profile of the Monkey functions to, none when empty
+	vm            bool   // run on the bytecode virtual machine instead of the evaluator
+}
+
+// runCommand is the run command, which the run flags may follow, as in
+// monkey run -cpuprofile cpu.pprof script.mk.
+func runCommand(args []string, options runOptions, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.StringVar(&options.trace, "trace", options.trace, "write a JSON-lines trace of the evaluation to `file`")
+	flags.StringVar(&options.cpuProfile, "cpuprofile", options.cpuProfile, "write a pprof profile of the Monkey functions to `file`")
+	flags.BoolVar(&options.vm, "vm", options.vm, "run on the bytecode virtual machine")
+	flags.SetOutput(errOut)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	return runFile(flags.Args(), options, out, errOut)
+}
+
+// runFile runs the script at args[0], standard input when it is "-", passing
+// it the rest of args.
+func runFile(args []string, options runOptions, out io.Writer, errOut io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(errOut, "run: no script given")
+		return 2
+	}
+
+	sources, ok := readSources(args[:1], errOut)
+	if !ok {
+		return 2
+	}
+
+	return runSource(sources[0].path, sources[0].text, args[1:], false, options, out, errOut)
+}
+
+// source is a script and the path it was read from, "-" for standard input.
+type source struct {
+	path string
+	text string
+}
+
+// readSources reads the files at paths, standard input for "-" or when there
+// are none. The files that cannot be read are reported to errOut and left out,
+// ok being false then.
+func readSources(paths []string, errOut io.Writer) (sources []source, ok bool) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	ok = true
+	for _, path := range paths {
+		var text []byte
+		var err error
+		if path == "-" {
+			text, err = io.ReadAll(os.Stdin)
+		} else {
+			text, err = os.ReadFile(path)
+		}
+		if err != nil {
+			fmt.Fprintf(errOut, "%s: %s\n", path, err)
+			ok = false
+			continue
+		}
+
+		sources = append(sources, source{path: path, text: string(text)})
+	}
+
+	return sources, ok
+}
+
+// parse parses the script source read from name, reporting its errors to
+// errOut, ok being false then.
+func parse(name string, source string, errOut io.Writer) (program *ast.Program, ok bool) {
+	monkeyParser := parser.New(lexer.New(source))
+	program = monkeyParser.ParseProgram()
+
+	for _, msg := range monkeyParser.Errors() {
+		fmt.Fprintf(errOut, "%s: %s\n", name, msg)
+	}
+
+	return program, len(monkeyParser.Errors()) == 0
+}
+
+// scriptEnvironment returns the environment a script runs in, with args bound
+// to the array args.
+func scriptEnvironment(args []string) *object.Environment {
+	env := object.NewEnvironment()
+	env.Set("args", argsArray(args))
+
+	return env
+}
+
+func argsArray(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+
+	return object.NewArray(elements)
+}
+
+// runOnVM compiles program and runs it on the virtual machine, with args bound
+// as in scriptEnvironment and puts printing to out. It returns the value of the
+// program, or the error that stopped it.
+func runOnVM(program *ast.Program, args []string, out io.Writer) object.Object {
+	symbolTable := compiler.NewSymbolTable()
+	globals := make([]object.Object, vm.GlobalsSize)
+	globals[symbolTable.Define("args").Index] = argsArray(args)
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{}, evaluator.Builtins())
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+	machine.SetOutput(out)
+	if err := machine.Run(); err != nil {
+		if errObj, ok := err.(*object.Error); ok {
+			return errObj
+		}
+		return &object.Error{Message: err.Error()}
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+// runSource runs the script source, read from name, with args bound to the
+// array args, printing what puts prints to out. When printResult is set, the
+// value of the script is printed unless it is null. The exit status is 2 when the script does not parse, 1
+// when it evaluates to an error.
+func runSource(name string, source string, args []string, printResult bool, options runOptions, out io.Writer, errOut io.Writer) int {
+	program, ok := parse(name, source, errOut)
+	if !ok {
+		return 2
+	}
+
+	if options.optimize || options.dumpOptimized {
+		optimizer.Optimize(program)
+	}
+
+	if options.dumpOptimized {
+		io.WriteString(out, program.String())
+		io.WriteString(out, "\n")
+	}
+
+	if options.vm && (options.trace != "" || options.cpuProfile != "") {
+		fmt.Fprintln(errOut, "-trace and -cpuprofile follow the evaluator, they cannot be used with -vm")
+		return 2
+	}
+
+	evalOptions := evaluator.Options{Output: out}
+
+	if options.trace != "" {
+		tracer, stop, err := startTrace(options.trace)
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			return 2
+		}
+		defer func() {
+			if err := stop(); err != nil {
+				fmt.Fprintf(errOut, "%s: %s\n", options.trace, err)
+			}
+		}()
+		evalOptions.Tracer = tracer
+	}
+
+	if options.cpuProfile != "" {
+		profiler, stop, err := startProfile(name, options.cpuProfile)
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			return 2
+		}
+		defer func() {
+			if err := stop(errOut); err != nil {
+				fmt.Fprintf(errOut, "%s: %s\n", options.cpuProfile, err)
+			}
+		}()
+		evalOptions.Debugger = profiler
+	}
+
+	var evaluated object.Object
+	if options.vm {
+		evaluated = runOnVM(program, args, out)
+	} else {
+		env := scriptEnvironment(args)
+		evaluator.Resolve(program, env)
+		evaluated = evaluator.EvalWithOptions(program, env, evalOptions)
+	}
+
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintf(errOut, "%s: %s\n", name, err.Message)
+		return 1
+	}
+
+	if printResult && evaluated != nil && evaluated != object.NULL {
+		fmt.Fprintln(out, evaluated.Inspect())
+	}
+
+	return 0
+}
+
+// startTrace creates the tracer that writes a trace of the evaluation to the
+// file at path, until the returned stop is called.
+func startTrace(path string) (tracer *trace.Tracer, stop func() error, err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buffered := bufio.NewWriter(file)
+	tracer = trace.New(buffered)
+
+	return tracer, func() error {
+		err := tracer.Err()
+		if flushErr := buffered.Flush(); err == nil {
+			err = flushErr
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// startProfile starts the profiler that samples the Monkey call stack of the
+// script read from name, until the returned stop writes the profile to the
+// file at path and reports the calls of each function to report.
+func startProfile(name string, path string) (profiler *profile.Profiler, stop func(report io.Writer) error, err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	profiler = profile.New(name)
+	profiler.Start()
+
+	return profiler, func(report io.Writer) error {
+		profiler.Stop()
+
+		err := profiler.Write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		return profiler.Report(report)
+	}, nil
+}
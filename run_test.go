@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunSource(t *testing.T) {
+	tests := []struct {
+		source         string
+		args           []string
+		expectedStatus int
+		expectedOut    string
+		expectedErr    string
+	}{
+		{"1 + 2", nil, 0, "3\n", ""},
+		{"puts", nil, 0, "builtin function\n", ""},
+		{"let x = 1;", nil, 0, "", ""},
+		{`puts("a", 1); 2`, nil, 0, "a\n1\n2\n", ""},
+		{"if (false) { 1 }", nil, 0, "", ""},
+		{"1; let x = 2;", nil, 0, "", ""},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", nil, 0, "610\n", ""},
+		{"len(args)", nil, 0, "0\n", ""},
+		{`args[1] + "!"`, []string{"a", "b"}, 0, "b!\n", ""},
+		{"#!/usr/bin/env monkey\nargs", []string{"x"}, 0, "[x]\n", ""},
+		{"fn(x) { x + true }(1)", nil, 1, "", "test: type mismatch: INTEGER + BOOLEAN\n"},
+		{"let = 1", nil, 2, "", "test: expected next token to be IDENT, got = instead\ntest: no prefix parse function found for = found\n"},
+	}
+
+	for _, options := range []runOptions{{}, {vm: true}} {
+		for _, tt := range tests {
+			var out, errOut bytes.Buffer
+			status := runSource("test", tt.source, tt.args, true, options, &out, &errOut)
+
+			if status != tt.expectedStatus {
+				t.Errorf("%q (vm %t) exited with %d, want=%d", tt.source, options.vm, status, tt.expectedStatus)
+			}
+			if out.String() != tt.expectedOut {
+				t.Errorf("%q (vm %t) printed %q, want=%q", tt.source, options.vm, out.String(), tt.expectedOut)
+			}
+			if errOut.String() != tt.expectedErr {
+				t.Errorf("%q (vm %t) reported %q, want=%q", tt.source, options.vm, errOut.String(), tt.expectedErr)
+			}
+		}
+	}
+}
+
+func TestRunDumpOptimized(t *testing.T) {
+	var out, errOut bytes.Buffer
+	status := runSource("test", "let a = 2; a * 3", nil, true, runOptions{dumpOptimized: true}, &out, &errOut)
+
+	if status != 0 {
+		t.Errorf("exited with %d: %s", status, errOut.String())
+	}
+	if expected := "let a = 2;6\n6\n"; out.String() != expected {
+		t.Errorf("printed %q, want=%q", out.String(), expected)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	if err := os.WriteFile(path, []byte("#!/usr/bin/env monkey\nlet x = args[0];\nx\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	if status := runFile([]string{path, "a"}, runOptions{optimize: true}, &out, &errOut); status != 0 {
+		t.Errorf("script exited with %d: %s", status, errOut.String())
+	}
+	if out.Len() != 0 {
+		t.Errorf("the value of a script was printed: %q", out.String())
+	}
+
+	if status := runFile([]string{filepath.Join(t.TempDir(), "missing.mk")}, runOptions{}, &out, &errOut); status != 2 {
+		t.Errorf("a missing script exited with %d, want=2", status)
+	}
+
+	if status := runFile(nil, runOptions{}, &out, &errOut); status != 2 {
+		t.Errorf("no script exited with %d, want=2", status)
+	}
+}
+
+func TestRunTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+
+	var out, errOut bytes.Buffer
+	if status := runSource("test", "let f = fn(x) { x }; f(1)", nil, true, runOptions{trace: path}, &out, &errOut); status != 0 {
+		t.Fatalf("script exited with %d: %s", status, errOut.String())
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(written), "\n"), "\n")
+	if !strings.HasPrefix(lines[0], `{"event":"enter","kind":"Program"`) {
+		t.Errorf("the trace does not enter the program first, got=%s", lines[0])
+	}
+	if !strings.Contains(string(written), `{"event":"call","function":"f","args":["1"]`) {
+		t.Errorf("the trace does not call f:\n%s", written)
+	}
+
+	status := runSource("test", "1", nil, true, runOptions{trace: filepath.Join(t.TempDir(), "missing", "trace.jsonl")}, &out, &errOut)
+	if status != 2 {
+		t.Errorf("a trace that cannot be created exited with %d, want=2", status)
+	}
+}
+
+func TestRunProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	if err := os.WriteFile(path, []byte("let f = fn(x) { x };\nf(1);\nf(2);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	profilePath := filepath.Join(t.TempDir(), "cpu.pprof")
+
+	var out, errOut bytes.Buffer
+	if status := runCommand([]string{"-cpuprofile", profilePath, path}, runOptions{}, &out, &errOut); status != 0 {
+		t.Fatalf("script exited with %d: %s", status, errOut.String())
+	}
+
+	if info, err := os.Stat(profilePath); err != nil || info.Size() == 0 {
+		t.Errorf("no profile was written: %v", err)
+	}
+	lines := strings.Split(errOut.String(), "\n")
+	if len(lines) < 3 || !strings.Contains(lines[0], "calls") || !strings.Contains(lines[2], "2 ") || !strings.HasSuffix(lines[2], "f "+path+":1") {
+		t.Errorf("calls not reported:\n%s", errOut.String())
+	}
+
+	if status := runCommand([]string{"-cpuprofile"}, runOptions{}, &out, &errOut); status != 2 {
+		t.Errorf("a flag without its file exited with %d, want=2", status)
+	}
+}
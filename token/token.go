@@ -1,15 +1,55 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Start   Position  `json:"start"` // of the first character of the token
+	End     Position  `json:"end"`   // just past the last character of the token
+}
+
+// Position is a place in the source. Offset counts bytes from the start of the
+// input, Line and Column count from 1, columns in bytes. The zero Position is
+// that of tokens made up rather than read from a source.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (self Position) IsValid() bool {
+	return self.Line > 0
+}
+
+// Advance returns the position just past text, when text starts at self.
+func (self Position) Advance(text string) Position {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			self.Line++
+			self.Column = 1
+		} else {
+			self.Column++
+		}
+	}
+	self.Offset += len(text)
+
+	return self
+}
+
+func (self Position) String() string {
+	return fmt.Sprintf("%d:%d", self.Line, self.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // from // to the end of the line, skipped by the lexer
 
 	// Identifiers + literals
 
@@ -54,6 +94,7 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	STRING   = "STRING"
+	TEMPLATE = "TEMPLATE" // a string containing ${...} interpolations
 )
 
 var keywords = map[string]TokenType{
@@ -66,6 +107,17 @@ var keywords = map[string]TokenType{
 	"return": RETURN,
 }
 
+// Keywords returns the keywords of the language, sorted.
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 func LookUpIdent(ident string) TokenType {
 	if tok, ok := keywords[ident]; ok {
 		return tok
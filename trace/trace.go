@@ -0,0 +1,158 @@
+// Package trace writes what the evaluator does as JSON lines, one event per
+// line, to explain after the fact how a script came to its result.
+//
+// A node is entered before it is evaluated and exited once it evaluated to
+// its result, the events between the two being those of its children. The
+// calls of functions and builtins, and the errors created, are told where
+// they happen. Each event holds its depth, the number of nodes entered and
+// not yet exited, and the time it happened at.
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/pretty"
+	"github.com/Neal-C/interpreter-in-go/token"
+	"io"
+	"strings"
+	"time"
+)
+
+// The events of a trace.
+const (
+	ENTER  = "enter"
+	EXIT   = "exit"
+	CALL   = "call"
+	RETURN = "return"
+	ERROR  = "error"
+)
+
+// Span is where a node is in the source, from its first character to the one
+// just past its last.
+type Span struct {
+	Start token.Position `json:"start"`
+	End   token.Position `json:"end"`
+}
+
+// Event is a line of a trace.
+type Event struct {
+	Event    string    `json:"event"`
+	Kind     string    `json:"kind,omitempty"`     // the Go type of the node in package ast, for enter and exit
+	Span     *Span     `json:"span,omitempty"`     // of the node, for enter and exit
+	Function string    `json:"function,omitempty"` // the name of the function, for call and return
+	Args     []string  `json:"args,omitempty"`     // the summaries of the arguments, for call
+	Result   string    `json:"result,omitempty"`   // the summary of the result, for exit and return
+	Type     string    `json:"type,omitempty"`     // the type of the result
+	Message  string    `json:"message,omitempty"`  // of the error, for error
+	Depth    int       `json:"depth"`
+	Time     time.Time `json:"time"`
+}
+
+// Tracer is an evaluator.Tracer writing a trace.
+type Tracer struct {
+	// Now tells the time of the events, time.Now unless changed.
+	Now func() time.Time
+
+	encoder  *json.Encoder
+	depth    int
+	builtins map[*object.Builtin]string
+	err      error
+}
+
+// New returns a tracer writing to out. Wrap out in a bufio.Writer when
+// writing to a file, a trace has many lines.
+func New(out io.Writer) *Tracer {
+	builtins := map[*object.Builtin]string{}
+	for name, builtin := range evaluator.Builtins() {
+		builtins[builtin] = name
+	}
+
+	return &Tracer{Now: time.Now, encoder: json.NewEncoder(out), builtins: builtins}
+}
+
+// Err returns the first error writing the trace met, after which nothing
+// more was written.
+func (self *Tracer) Err() error {
+	return self.err
+}
+
+func (self *Tracer) Enter(node ast.Node, env *object.Environment) {
+	self.write(Event{Event: ENTER, Kind: kind(node), Span: span(node)})
+	self.depth++
+}
+
+func (self *Tracer) Exit(node ast.Node, result object.Object) {
+	self.depth--
+	event := Event{Event: EXIT, Kind: kind(node), Span: span(node)}
+	event.Result, event.Type = summary(result)
+	self.write(event)
+}
+
+func (self *Tracer) Call(fn object.Object, args []object.Object) {
+	event := Event{Event: CALL, Function: self.name(fn), Args: make([]string, len(args))}
+	for i, arg := range args {
+		event.Args[i], _ = summary(arg)
+	}
+	self.write(event)
+}
+
+func (self *Tracer) Return(fn object.Object, result object.Object) {
+	event := Event{Event: RETURN, Function: self.name(fn)}
+	event.Result, event.Type = summary(result)
+	self.write(event)
+}
+
+func (self *Tracer) Error(err *object.Error) {
+	self.write(Event{Event: ERROR, Message: err.Message})
+}
+
+func (self *Tracer) write(event Event) {
+	if self.err != nil {
+		return
+	}
+
+	event.Depth = self.depth
+	event.Time = self.Now()
+	self.err = self.encoder.Encode(event)
+}
+
+// name is the name of fn, <anonymous> for a function no let named.
+func (self *Tracer) name(fn object.Object) string {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name == "" {
+			return "<anonymous>"
+		}
+		return fn.Name
+	case *object.Builtin:
+		if name, ok := self.builtins[fn]; ok {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("<%s>", strings.ToLower(string(fn.Type())))
+}
+
+func kind(node ast.Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+func span(node ast.Node) *Span {
+	return &Span{Start: node.Pos(), End: node.End()}
+}
+
+// summary is result on a single line and its type, the value it returns for a
+// return statement. A statement without a value has neither.
+func summary(result object.Object) (string, string) {
+	if result == nil {
+		return "", ""
+	}
+	if returned, ok := result.(*object.ReturnValue); ok {
+		result = returned.Value
+	}
+
+	return pretty.Summary(result), string(result.Type())
+}
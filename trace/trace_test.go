@@ -0,0 +1,151 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+var EPOCH = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// trace evaluates input with a tracer writing to out, the clock ticking a
+// millisecond per event.
+func trace(t *testing.T, input string, out *bytes.Buffer) *Tracer {
+	t.Helper()
+
+	monkeyParser := parser.New(lexer.New(input))
+	program := monkeyParser.ParseProgram()
+	if len(monkeyParser.Errors()) != 0 {
+		t.Fatalf("%q: parser errors: %v", input, monkeyParser.Errors())
+	}
+
+	tracer := New(out)
+	now := EPOCH
+	tracer.Now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	evaluator.EvalWithOptions(program, object.NewEnvironment(), evaluator.Options{Tracer: tracer})
+
+	return tracer
+}
+
+func events(t *testing.T, out *bytes.Buffer) []Event {
+	t.Helper()
+
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("%s is not an event: %s", line, err)
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+	trace(t, `len("ab")`, &out)
+
+	expected := `{"event":"enter","kind":"Program","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":9,"line":1,"column":10}},"depth":0,"time":"2024-01-02T03:04:05.001Z"}
+{"event":"enter","kind":"ExpressionStatement","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":9,"line":1,"column":10}},"depth":1,"time":"2024-01-02T03:04:05.002Z"}
+{"event":"enter","kind":"CallExpression","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":9,"line":1,"column":10}},"depth":2,"time":"2024-01-02T03:04:05.003Z"}
+{"event":"enter","kind":"Identifier","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":3,"line":1,"column":4}},"depth":3,"time":"2024-01-02T03:04:05.004Z"}
+{"event":"exit","kind":"Identifier","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":3,"line":1,"column":4}},"result":"builtin function","type":"BUILTIN","depth":3,"time":"2024-01-02T03:04:05.005Z"}
+{"event":"enter","kind":"StringLiteral","span":{"start":{"offset":4,"line":1,"column":5},"end":{"offset":8,"line":1,"column":9}},"depth":3,"time":"2024-01-02T03:04:05.006Z"}
+{"event":"exit","kind":"StringLiteral","span":{"start":{"offset":4,"line":1,"column":5},"end":{"offset":8,"line":1,"column":9}},"result":"\"ab\"","type":"STRING","depth":3,"time":"2024-01-02T03:04:05.007Z"}
+{"event":"call","function":"len","args":["\"ab\""],"depth":3,"time":"2024-01-02T03:04:05.008Z"}
+{"event":"return","function":"len","result":"2","type":"INTEGER","depth":3,"time":"2024-01-02T03:04:05.009Z"}
+{"event":"exit","kind":"CallExpression","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":9,"line":1,"column":10}},"result":"2","type":"INTEGER","depth":2,"time":"2024-01-02T03:04:05.01Z"}
+{"event":"exit","kind":"ExpressionStatement","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":9,"line":1,"column":10}},"result":"2","type":"INTEGER","depth":1,"time":"2024-01-02T03:04:05.011Z"}
+{"event":"exit","kind":"Program","span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":9,"line":1,"column":10}},"result":"2","type":"INTEGER","depth":0,"time":"2024-01-02T03:04:05.012Z"}
+`
+	if out.String() != expected {
+		t.Errorf("trace wrong.\ngot:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestTraceEvents(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // the events other than enter and exit, as event function/message result
+	}{
+		{
+			"let double = fn(x) { x * 2 }; double(3)",
+			[]string{"call double [3]", "return double 6"},
+		},
+		{
+			"map([1], fn(x) { return x; })",
+			[]string{"call map [[1] fn(x) {...}]", "call <anonymous> [1]", "return <anonymous> 1", "return map [1]"},
+		},
+		{
+			"let f = fn() { 1 + true }; f()",
+			[]string{"call f []", "error type mismatch: INTEGER + BOOLEAN", "return f ERROR: type mismatch: INTEGER + BOOLEAN"},
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		trace(t, tt.input, &out)
+
+		var got []string
+		depth := 0
+		for _, event := range events(t, &out) {
+			switch event.Event {
+			case ENTER:
+				if event.Depth != depth {
+					t.Errorf("%q: %s entered at depth %d, want=%d", tt.input, event.Kind, event.Depth, depth)
+				}
+				depth++
+			case EXIT:
+				depth--
+				if event.Depth != depth {
+					t.Errorf("%q: %s exited at depth %d, want=%d", tt.input, event.Kind, event.Depth, depth)
+				}
+			case CALL:
+				got = append(got, "call "+event.Function+" ["+strings.Join(event.Args, " ")+"]")
+			case RETURN:
+				got = append(got, "return "+event.Function+" "+event.Result)
+			case ERROR:
+				got = append(got, "error "+event.Message)
+			}
+		}
+
+		if depth != 0 {
+			t.Errorf("%q: %d nodes were not exited", tt.input, depth)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: events wrong.\ngot=%q\nwant=%q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestTraceWriteError(t *testing.T) {
+	tracer := New(failingWriter{})
+
+	evaluated := evaluator.EvalWithOptions(parser.New(lexer.New("1 + 2")).ParseProgram(), object.NewEnvironment(), evaluator.Options{Tracer: tracer})
+
+	if evaluated.Inspect() != "3" {
+		t.Errorf("the evaluation was changed, got=%s", evaluated.Inspect())
+	}
+	if tracer.Err() == nil || tracer.Err().Error() != "disk full" {
+		t.Errorf("error wrong. got=%v, want=disk full", tracer.Err())
+	}
+}
@@ -0,0 +1,24 @@
+package vm
+
+import (
+	"github.com/Neal-C/interpreter-in-go/code"
+	"github.com/Neal-C/interpreter-in-go/object"
+)
+
+type Frame struct {
+	closure     *object.Closure
+	ip          int
+	basePointer int
+}
+
+func NewFrame(closure *object.Closure, basePointer int) *Frame {
+	return &Frame{
+		closure:     closure,
+		ip:          -1,
+		basePointer: basePointer,
+	}
+}
+
+func (self *Frame) Instructions() code.Instructions {
+	return self.closure.Fn.Instructions
+}
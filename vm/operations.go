@@ -0,0 +1,168 @@
+package vm
+
+import (
+	"github.com/Neal-C/interpreter-in-go/code"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"strings"
+)
+
+// The operations below follow the evaluator's rules and error messages to the
+// letter, so that a program behaves the same on both backends.
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+func executeInfixOperation(op code.Opcode, left object.Object, right object.Object) object.Object {
+	operator := infixOperators[op]
+
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return executeIntegerOperation(operator, left, right)
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return executeStringOperation(operator, left, right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return executeStringOperation(operator, left, right)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func executeIntegerOperation(operator string, left object.Object, right object.Object) object.Object {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return object.NewInteger(leftValue + rightValue)
+	case "-":
+		return object.NewInteger(leftValue - rightValue)
+	case "*":
+		return object.NewInteger(leftValue * rightValue)
+	case "/":
+		if rightValue == 0 {
+			return newError("division by zero: %d / %d", leftValue, rightValue)
+		}
+		return object.NewInteger(leftValue / rightValue)
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func executeStringOperation(operator string, left object.Object, right object.Object) object.Object {
+	switch {
+	case operator == "+" && right.Type() == object.STRING_OBJ:
+		leftValue := left.(*object.String).Value
+		rightValue := right.(*object.String).Value
+		return &object.String{Value: leftValue + rightValue}
+	case operator == "*" && right.Type() == object.INTEGER_OBJ:
+		count := right.(*object.Integer).Value
+		if count < 0 {
+			return newError("negative repeat count: %d", count)
+		}
+		if length := len(left.(*object.String).Value); length > 0 && count > int64(object.MAX_STRING_LENGTH/length) {
+			return newError("repeat count too large: %d", count)
+		}
+		return &object.String{Value: strings.Repeat(left.(*object.String).Value, int(count))}
+	case right.Type() != object.STRING_OBJ:
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func executeBangOperator(operand object.Object) object.Object {
+	switch operand {
+	case TRUE:
+		return FALSE
+	case FALSE:
+		return TRUE
+	case NULL:
+		return TRUE
+	default:
+		return FALSE
+	}
+}
+
+func executeMinusOperator(operand object.Object) object.Object {
+	integer, ok := operand.(*object.Integer)
+	if !ok {
+		return newError("unknown operator: -%s", operand.Type())
+	}
+
+	return object.NewInteger(-integer.Value)
+}
+
+func executeIndexExpression(left object.Object, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		array := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(array.Len()) {
+			return NULL
+		}
+		return array.Get(int(idx))
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		runes := []rune(left.(*object.String).Value)
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(runes)) {
+			return NULL
+		}
+		return &object.String{Value: string(runes[idx])}
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		pair, ok := left.(*object.Hash).Get(key.HashKey())
+		if !ok {
+			return NULL
+		}
+		return pair.Value
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+
+	return FALSE
+}
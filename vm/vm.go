@@ -0,0 +1,491 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/code"
+	"github.com/Neal-C/interpreter-in-go/compiler"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"io"
+	"os"
+)
+
+const (
+	StackSize    = 2048
+	MaxStackSize = 1 << 22
+	GlobalsSize  = 65536
+	MaxFrames    = 1 << 20
+)
+
+var (
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+)
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // always points to the next free slot, the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	lastPopped object.Object
+
+	output io.Writer
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsStore creates a vm sharing globals with a previous run, as the REPL does.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+
+	frames := make([]*Frame, 1, 64)
+	frames[0] = NewFrame(mainClosure, 0)
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.GlobalNames,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+
+		output: os.Stdout,
+	}
+}
+
+// SetOutput makes puts print to out rather than to standard output.
+func (self *VM) SetOutput(out io.Writer) {
+	self.output = out
+}
+
+// LastPoppedStackElem is the result of the program: the value of its last
+// statement, nil for a let, or the value of a top-level return.
+func (self *VM) LastPoppedStackElem() object.Object {
+	return self.lastPopped
+}
+
+// Run executes the program. Monkey runtime errors stop the execution and are
+// returned as *object.Error.
+func (self *VM) Run() error {
+	return self.run(0)
+}
+
+// run executes instructions until the frame count drops to baseFrame, which
+// lets builtins call back into a closure and get its result.
+func (self *VM) run(baseFrame int) error {
+	for self.framesIndex > baseFrame {
+		frame := self.currentFrame()
+		instructions := frame.Instructions()
+
+		if frame.ip >= len(instructions)-1 {
+			// only the main frame runs off its end, function bodies always return
+			return nil
+		}
+
+		frame.ip++
+		ip := frame.ip
+		op := code.Opcode(instructions[ip])
+
+		var err error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(instructions[ip+1:])
+			frame.ip += 2
+
+			constant := self.constants[constIndex]
+			if str, ok := constant.(*object.String); ok {
+				// strings compare by identity: as in the evaluator, each
+				// evaluation of a literal is a string of its own
+				constant = &object.String{Value: str.Value}
+			}
+			err = self.push(constant)
+		case code.OpPop:
+			self.lastPopped = self.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			right := self.pop()
+			left := self.pop()
+			err = self.pushResult(executeInfixOperation(op, left, right))
+		case code.OpTrue:
+			err = self.push(TRUE)
+		case code.OpFalse:
+			err = self.push(FALSE)
+		case code.OpNull:
+			err = self.push(NULL)
+		case code.OpBang:
+			err = self.push(executeBangOperator(self.pop()))
+		case code.OpMinus:
+			err = self.pushResult(executeMinusOperator(self.pop()))
+		case code.OpJump:
+			position := int(code.ReadUint16(instructions[ip+1:]))
+			frame.ip = position - 1
+		case code.OpJumpNotTruthy:
+			position := int(code.ReadUint16(instructions[ip+1:]))
+			frame.ip += 2
+
+			if !isTruthy(self.pop()) {
+				frame.ip = position - 1
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(instructions[ip+1:])
+			frame.ip += 2
+			self.globals[globalIndex] = self.pop()
+			// a program ending with a let has no value, as in the evaluator
+			self.lastPopped = nil
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(instructions[ip+1:])
+			frame.ip += 2
+
+			value := self.globals[globalIndex]
+			if value == nil {
+				return newError("identifier not found: %s", self.globalName(int(globalIndex)))
+			}
+			err = self.push(value)
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			frame.ip += 1
+
+			slot := frame.basePointer + int(localIndex)
+			if cell, ok := self.stack[slot].(*object.Cell); ok {
+				cell.Value = self.pop()
+			} else {
+				self.stack[slot] = self.pop()
+			}
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			frame.ip += 1
+
+			value := self.stack[frame.basePointer+int(localIndex)]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if value == nil {
+				return newError("identifier not found: %s", frame.closure.Fn.LocalNames[localIndex])
+			}
+			err = self.push(value)
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(instructions[ip+1:])
+			frame.ip += 1
+
+			value := frame.closure.Free[freeIndex]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if value == nil {
+				return newError("identifier not found: %s", frame.closure.Fn.FreeNames[freeIndex])
+			}
+			err = self.push(value)
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			frame.ip += 1
+
+			slot := frame.basePointer + int(localIndex)
+			if _, ok := self.stack[slot].(*object.Cell); !ok {
+				self.stack[slot] = &object.Cell{Value: self.stack[slot]}
+			}
+			err = self.push(self.stack[slot])
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(instructions[ip+1:])
+			frame.ip += 1
+			err = self.push(frame.closure.Free[freeIndex])
+		case code.OpCurrentClosure:
+			err = self.push(frame.closure)
+		case code.OpArray:
+			numElements := int(code.ReadUint16(instructions[ip+1:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, self.stack[self.sp-numElements:self.sp])
+			self.sp -= numElements
+
+			err = self.push(object.NewArray(elements))
+		case code.OpHash:
+			numElements := int(code.ReadUint16(instructions[ip+1:]))
+			frame.ip += 2
+
+			hash, errObj := self.buildHash(self.sp-numElements, self.sp)
+			if errObj != nil {
+				return errObj
+			}
+			self.sp -= numElements
+
+			err = self.push(hash)
+		case code.OpIndex:
+			index := self.pop()
+			left := self.pop()
+			err = self.pushResult(executeIndexExpression(left, index))
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(instructions[ip+1:]))
+			frame.ip += 2
+
+			var out bytes.Buffer
+			for _, part := range self.stack[self.sp-numParts : self.sp] {
+				out.WriteString(part.Inspect())
+			}
+			self.sp -= numParts
+
+			err = self.push(&object.String{Value: out.String()})
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(instructions[ip+1:]))
+			frame.ip += 1
+			err = self.executeCall(numArgs)
+		case code.OpReturnValue:
+			err = self.returnFromFrame(self.pop())
+		case code.OpReturn:
+			err = self.returnFromFrame(NULL)
+		case code.OpClosure:
+			constIndex := int(code.ReadUint16(instructions[ip+1:]))
+			numFree := int(code.ReadUint8(instructions[ip+3:]))
+			frame.ip += 3
+			err = self.pushClosure(constIndex, numFree)
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *VM) globalName(index int) string {
+	if index < len(self.globalNames) {
+		return self.globalNames[index]
+	}
+
+	return fmt.Sprintf("global %d", index)
+}
+
+func (self *VM) currentFrame() *Frame {
+	return self.frames[self.framesIndex-1]
+}
+
+func (self *VM) pushFrame(frame *Frame) error {
+	if self.framesIndex >= MaxFrames {
+		return newError("stack overflow: more than %d nested calls", MaxFrames)
+	}
+
+	if self.framesIndex < len(self.frames) {
+		self.frames[self.framesIndex] = frame
+	} else {
+		self.frames = append(self.frames, frame)
+	}
+	self.framesIndex++
+
+	return nil
+}
+
+func (self *VM) popFrame() *Frame {
+	self.framesIndex--
+	return self.frames[self.framesIndex]
+}
+
+// returnFromFrame leaves the current function with value as its result.
+// A return at the top level ends the program with that value.
+func (self *VM) returnFromFrame(value object.Object) error {
+	if self.framesIndex == 1 {
+		self.lastPopped = value
+		frame := self.currentFrame()
+		frame.ip = len(frame.Instructions()) - 1
+		return nil
+	}
+
+	frame := self.popFrame()
+	self.sp = frame.basePointer - 1
+
+	return self.push(value)
+}
+
+func (self *VM) push(obj object.Object) error {
+	if err := self.ensureStack(self.sp + 1); err != nil {
+		return err
+	}
+
+	self.stack[self.sp] = obj
+	self.sp++
+
+	return nil
+}
+
+// pushResult pushes the result of an operation, unless it is an error which
+// then stops the execution like it does in the evaluator.
+func (self *VM) pushResult(obj object.Object) error {
+	if errObj, ok := obj.(*object.Error); ok {
+		return errObj
+	}
+
+	return self.push(obj)
+}
+
+func (self *VM) pop() object.Object {
+	obj := self.stack[self.sp-1]
+	self.sp--
+	return obj
+}
+
+// ensureStack grows the stack so that it holds at least size slots.
+func (self *VM) ensureStack(size int) error {
+	if size <= len(self.stack) {
+		return nil
+	}
+
+	if size > MaxStackSize {
+		return newError("stack overflow: more than %d values on the stack", MaxStackSize)
+	}
+
+	grown := make([]object.Object, max(size, min(2*len(self.stack), MaxStackSize)))
+	copy(grown, self.stack)
+	self.stack = grown
+
+	return nil
+}
+
+func (self *VM) pushClosure(constIndex int, numFree int) error {
+	function, ok := self.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", self.constants[constIndex])
+	}
+
+	free := make([]object.Object, numFree)
+	copy(free, self.stack[self.sp-numFree:self.sp])
+	self.sp -= numFree
+
+	return self.push(&object.Closure{Fn: function, Free: free})
+}
+
+func (self *VM) executeCall(numArgs int) error {
+	callee := self.stack[self.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return self.callClosure(callee, numArgs)
+	case *object.Builtin:
+		args := make([]object.Object, numArgs)
+		copy(args, self.stack[self.sp-numArgs:self.sp])
+
+		result := callee.Fn(self, args...)
+		self.sp = self.sp - numArgs - 1
+
+		if result == nil {
+			result = NULL
+		}
+		return self.pushResult(result)
+	default:
+		return newError("fn is not a function: %s ", callee.Type())
+	}
+}
+
+// callClosure sets up a frame for a closure whose arguments are on top of the stack.
+// Like in the evaluator, missing arguments are an error and extra ones are ignored.
+func (self *VM) callClosure(closure *object.Closure, numArgs int) error {
+	numParameters := closure.Fn.NumParameters
+
+	if numArgs < numParameters {
+		return newError("wrong number of arguments. got=%d, want=%d", numArgs, numParameters)
+	}
+
+	self.sp -= numArgs - numParameters
+
+	frame := NewFrame(closure, self.sp-numParameters)
+	if err := self.pushFrame(frame); err != nil {
+		return err
+	}
+
+	if err := self.ensureStack(frame.basePointer + closure.Fn.NumLocals); err != nil {
+		return err
+	}
+	self.sp = frame.basePointer + closure.Fn.NumLocals
+	// the locals no let bound yet are not found, rather than what a previous
+	// call left in their slots
+	clear(self.stack[frame.basePointer+numParameters : self.sp])
+
+	return nil
+}
+
+// Call is the object.Runtime the vm hands to builtins: it runs a closure to
+// completion on top of the current stack and returns its result.
+func (self *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		return fn.Fn(self, args...)
+	case *object.Closure:
+		baseFrame, baseSp := self.framesIndex, self.sp
+
+		if err := self.runClosure(fn, args, baseFrame); err != nil {
+			self.framesIndex, self.sp = baseFrame, baseSp
+			return errorObject(err)
+		}
+
+		return self.pop()
+	default:
+		return newError("fn is not a function: %s ", fn.Type())
+	}
+}
+
+// Output is where the builtins the vm calls print.
+func (self *VM) Output() io.Writer {
+	return self.output
+}
+
+func (self *VM) runClosure(closure *object.Closure, args []object.Object, baseFrame int) error {
+	if err := self.push(closure); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := self.push(arg); err != nil {
+			return err
+		}
+	}
+
+	if err := self.callClosure(closure, len(args)); err != nil {
+		return err
+	}
+
+	return self.run(baseFrame)
+}
+
+func (self *VM) buildHash(startIndex int, endIndex int) (object.Object, *object.Error) {
+	hash := &object.Hash{}
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := self.stack[i]
+		value := self.stack[i+1]
+
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", key.Type())
+		}
+
+		hash = hash.Set(hashable.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+func newError(format string, others ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, others...)}
+}
+
+func errorObject(err error) *object.Error {
+	if errObj, ok := err.(*object.Error); ok {
+		return errObj
+	}
+
+	return &object.Error{Message: err.Error()}
+}
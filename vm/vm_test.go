@@ -0,0 +1,191 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/Neal-C/interpreter-in-go/ast"
+	"github.com/Neal-C/interpreter-in-go/compiler"
+	"github.com/Neal-C/interpreter-in-go/evaluator"
+	"github.com/Neal-C/interpreter-in-go/lexer"
+	"github.com/Neal-C/interpreter-in-go/object"
+	"github.com/Neal-C/interpreter-in-go/parser"
+	"strings"
+	"testing"
+)
+
+// The evaluator's whole suite also runs on the vm, see evaluator.TestSuiteOnVM.
+// The tests here cover what is specific to the vm: frames, the stack and calls
+// from builtins back into closures.
+
+type vmTestCase struct {
+	input    string
+	expected any
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`let fibonacci = fn(x) { if (x < 2) { return x; } fibonacci(x - 1) + fibonacci(x - 2) }; fibonacci(15);`, 610},
+		{`let wrapper = fn() {
+			let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1) };
+			countDown(10);
+		  };
+		  wrapper();`, 0},
+		{`let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+		  let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+		  isEven(10);`, true},
+		{`let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(20000);`, 200010000},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{`let newAdder = fn(a, b) { fn(c) { a + b + c } }; newAdder(1, 2)(8);`, 11},
+		{`let newClosure = fn(a, b) {
+			let one = fn() { a; };
+			let two = fn() { b; };
+			fn() { one() + two(); };
+		  };
+		  newClosure(9, 90)();`, 99},
+		{`let f = fn(a) { a }; f(1, 2, 3)`, 1},
+		{`let f = fn(a, b) { a }; f(1)`, "wrong number of arguments. got=1, want=2"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestManyLocals(t *testing.T) {
+	// as many locals as their operand holds, named xa, xb and so on as
+	// identifiers hold no digits
+	var body strings.Builder
+	names := make([]string, 256)
+	for i := range names {
+		names[i] = "x" + string(rune('a'+i/26)) + string(rune('a'+i%26))
+		fmt.Fprintf(&body, "let %s = %d; ", names[i], i)
+	}
+
+	tests := []vmTestCase{
+		{"fn() { " + body.String() + strings.Join(names, " + ") + " }()", 255 * 256 / 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinsCallingClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{`let offset = 10; reduce(map([1, 2, 3], fn(x) { x + offset }), fn(a, b) { a + b })`, 36},
+		{`map([[3, 1], [2]], fn(xs) { len(sort(xs, fn(a, b) { a - b })) })[0]`, 2},
+		{`let r = map([1, 2], fn(x) { x * 2 }); r[1] + 1`, 5},
+		{`map([1, 2], fn(x) { if (x > 1) { x + true } else { x } })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let xs = map([1], fn(x) { return x * 3; }); xs[0]`, 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{`missing`, "identifier not found: missing"},
+		{`if (false) { missing } else { 1 }`, 1},
+		{`let f = fn() { later }; let later = 5; f()`, 5},
+		{`1 / 0`, "division by zero: 1 / 0"},
+		{`1(2)`, "fn is not a function: INTEGER "},
+		{`let loop = fn(n) { loop(n + 1) }; loop(0)`, "stack overflow: more than 1048576 nested calls"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalsCarryOver(t *testing.T) {
+	globals := make([]object.Object, GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+
+	lines := []vmTestCase{
+		{`let a = 40;`, nil},
+		{`let add = fn(x) { x + a + len([1, 2]) };`, nil},
+		{`add(0)`, 42},
+	}
+
+	for _, line := range lines {
+		comp := compiler.NewWithState(symbolTable, constants, evaluator.Builtins())
+		if err := comp.Compile(parse(line.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobalsStore(bytecode, globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if line.expected != nil {
+			testExpectedObject(t, line.input, line.expected, machine.LastPoppedStackElem())
+		}
+	}
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	program := parse(`let fibonacci = fn(x) { if (x < 2) { return x; } fibonacci(x - 1) + fibonacci(x - 2) }; fibonacci(20);`)
+
+	for i := 0; i < b.N; i++ {
+		comp := compiler.New(evaluator.Builtins())
+		if err := comp.Compile(program); err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+
+		machine := New(comp.Bytecode())
+		if err := machine.Run(); err != nil {
+			b.Fatalf("vm error: %s", err)
+		}
+	}
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		comp := compiler.New(evaluator.Builtins())
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		machine := New(comp.Bytecode())
+		err := machine.Run()
+
+		result := machine.LastPoppedStackElem()
+		if err != nil {
+			result = errorObject(err)
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, result)
+	}
+}
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+func testExpectedObject(t *testing.T, input string, expected any, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := actual.(*object.Integer)
+		if !ok || integer.Value != int64(expected) {
+			t.Errorf("%s: object is not the integer %d, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case bool:
+		boolean, ok := actual.(*object.Boolean)
+		if !ok || boolean.Value != expected {
+			t.Errorf("%s: object is not the boolean %t, got=%T (%+v)", input, expected, actual, actual)
+		}
+	case string:
+		errObj, ok := actual.(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("%s: object is not the error %q, got=%T (%+v)", input, expected, actual, actual)
+		}
+	}
+}